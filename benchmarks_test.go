@@ -54,6 +54,34 @@ func benchmarkFromFile(b *testing.B, filename string) {
 			}
 		}
 	})
+	b.Run("copy-values", func(b *testing.B) {
+		pj := &ParsedJson{}
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Reset tape
+			var err error
+			pj, err = Parse(msg, pj, WithCopyKeys(false), WithCopyValues(true))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("copy-keys", func(b *testing.B) {
+		pj := &ParsedJson{}
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Reset tape
+			var err error
+			pj, err = Parse(msg, pj, WithCopyKeys(true), WithCopyValues(false))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
 	b.Run("nocopy-par", func(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			pj := &ParsedJson{}
@@ -77,6 +105,81 @@ func BenchmarkParseSmall(b *testing.B)  { benchmarkFromFile(b, "payload-small")
 func BenchmarkParseMedium(b *testing.B) { benchmarkFromFile(b, "payload-medium") }
 func BenchmarkParseLarge(b *testing.B)  { benchmarkFromFile(b, "payload-large") }
 
+// BenchmarkParseSmallSequence measures per-document overhead
+// when parsing many small documents in sequence.
+func BenchmarkParseSmallSequence(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	msg := loadCompressed(b, "payload-small")
+	b.Run("new", func(b *testing.B) {
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := Parse(msg, nil, WithCopyStrings(false))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("new-single-alloc", func(b *testing.B) {
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := Parse(msg, nil, WithCopyStrings(false), WithSingleAllocation(true))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reuse-tape", func(b *testing.B) {
+		pj, err := Parse(msg, nil, WithCopyStrings(false))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Only reuse tape and strings.
+			reuse := &ParsedJson{Tape: pj.Tape, Strings: pj.Strings}
+			pj, err = Parse(msg, reuse, WithCopyStrings(false))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reuse", func(b *testing.B) {
+		var pj *ParsedJson
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var err error
+			pj, err = Parse(msg, pj, WithCopyStrings(false))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reuse-nd", func(b *testing.B) {
+		var pj *ParsedJson
+		msg := []byte(demo_ndjson)
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var err error
+			pj, err = ParseND(msg, pj, WithCopyStrings(false))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func BenchmarkJsonParserLarge(b *testing.B) {
 	largeFixture := loadCompressed(b, "payload-large")
 
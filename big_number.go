@@ -0,0 +1,119 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// BigInt returns the integer value of the current number.
+// Integers that overflow 64 bits are exact when parsed with WithBigNumbers(true).
+// Floats are converted if they have an integral value.
+// If dst is nil a new big.Int is allocated, otherwise dst is set and returned.
+func (i *Iter) BigInt(dst *big.Int) (*big.Int, error) {
+	if dst == nil {
+		dst = new(big.Int)
+	}
+	switch i.t {
+	case TagInteger:
+		v, err := i.Int()
+		if err != nil {
+			return nil, err
+		}
+		return dst.SetInt64(v), nil
+	case TagUint:
+		v, err := i.Uint()
+		if err != nil {
+			return nil, err
+		}
+		return dst.SetUint64(v), nil
+	case TagFloat:
+		if i.cur&rawNumberBit != 0 && i.off < len(i.tape.Tape) {
+			b, err := i.tape.rawNumberAt(i.cur, i.tape.Tape[i.off])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := dst.SetString(string(b), 10); ok {
+				return dst, nil
+			}
+		}
+		f, err := i.BigFloat(nil)
+		if err != nil {
+			return nil, err
+		}
+		if !f.IsInt() {
+			return nil, fmt.Errorf("float value %v is not an integer", f)
+		}
+		f.Int(dst)
+		return dst, nil
+	}
+	return nil, errors.New("value is not a number")
+}
+
+// BigFloat returns the value of the current number.
+// Numbers stored as source text, with WithBigNumbers(true) or WithLazyNumbers(true), are converted from the source.
+// If dst is nil a new big.Float is allocated, otherwise dst is set and returned.
+// If dst has zero precision, it is set to a precision that represents all integers exactly.
+func (i *Iter) BigFloat(dst *big.Float) (*big.Float, error) {
+	if dst == nil {
+		dst = new(big.Float)
+	}
+	switch i.t {
+	case TagInteger:
+		v, err := i.Int()
+		if err != nil {
+			return nil, err
+		}
+		return dst.SetInt64(v), nil
+	case TagUint:
+		v, err := i.Uint()
+		if err != nil {
+			return nil, err
+		}
+		return dst.SetUint64(v), nil
+	case TagFloat:
+		if i.off >= len(i.tape.Tape) {
+			return nil, errors.New("corrupt input: expected float, but no more values on tape")
+		}
+		if i.cur&rawNumberBit != 0 {
+			b, err := i.tape.rawNumberAt(i.cur, i.tape.Tape[i.off])
+			if err != nil {
+				return nil, err
+			}
+			if dst.Prec() == 0 {
+				// Each digit needs less than 4 bits.
+				dst.SetPrec(uint(len(b))*4 + 64)
+			}
+			if _, _, err := dst.Parse(string(b), 10); err != nil {
+				return nil, numberError(b)
+			}
+			return dst, nil
+		}
+		v, err := i.Float()
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(v) {
+			return nil, errors.New("NaN cannot be converted")
+		}
+		return dst.SetFloat64(v), nil
+	}
+	return nil, errors.New("value is not a number")
+}
@@ -0,0 +1,156 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBigNumbers(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const u256 = "115792089237316195423570985008687907853269984665640564039457584007913129639935"
+	const input = `{"a":18446744073709551616,"b":-` + u256 + `,"c":12,"d":1.5e3,"e":0.25}`
+	tests := []struct {
+		key      string
+		wantInt  string
+		wantText string
+	}{
+		{key: "a", wantInt: "18446744073709551616", wantText: "18446744073709551616"},
+		{key: "b", wantInt: "-" + u256, wantText: "-" + u256},
+		{key: "c", wantInt: "12", wantText: "12"},
+		{key: "d", wantInt: "1500"},
+		{key: "e"},
+	}
+	pj, err := Parse([]byte(input), nil, WithBigNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			v := obj.FindKey(tt.key, nil)
+			if v == nil {
+				t.Fatal("key not found")
+			}
+			bi, err := v.Iter.BigInt(nil)
+			if tt.wantInt == "" {
+				if err == nil {
+					t.Fatalf("want error, got %v", bi)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if bi.String() != tt.wantInt {
+				t.Errorf("BigInt: want %s, got %s", tt.wantInt, bi)
+			}
+			bf, err := v.Iter.BigFloat(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantInt != "" {
+				want, _ := new(big.Int).SetString(tt.wantInt, 10)
+				if got, acc := bf.Int(nil); got.Cmp(want) != 0 || acc != big.Exact {
+					t.Errorf("BigFloat: want %s, got %s (%v)", tt.wantInt, got, acc)
+				}
+			}
+			if tt.wantText != "" {
+				b, err := v.Iter.NumberBytes()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tt.wantText {
+					t.Errorf("NumberBytes: want %s, got %s", tt.wantText, b)
+				}
+			}
+		})
+	}
+
+	// Float reads are unchanged.
+	v := obj.FindKey("a", nil)
+	if v == nil {
+		t.Fatal("key not found")
+	}
+	f, flags, err := v.Iter.FloatFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != 18446744073709551616 || !flags.Contains(FloatOverflowedInteger) {
+		t.Errorf("want float with overflow flag, got %v, %v", f, flags)
+	}
+
+	// Marshaling keeps the source.
+	out, err := root.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"a":18446744073709551616,"b":-`+u256+`,"c":12,"d":1500,"e":0.25}` {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// A scalar root.
+	pj, err = Parse([]byte(u256), nil, WithBigNumbers(true), WithAllowScalarRoot(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	i.AdvanceInto()
+	_, root, err = i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := root.BigInt(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bi.String() != u256 {
+		t.Errorf("want %s, got %s", u256, bi)
+	}
+
+	// Without the option the value is lossy.
+	pj, err = Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	i.AdvanceInto()
+	if _, root, err = i.Root(nil); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err = root.Object(nil); err != nil {
+		t.Fatal(err)
+	}
+	if v = obj.FindKey("b", nil); v == nil {
+		t.Fatal("key not found")
+	}
+	if bi, err = v.Iter.BigInt(nil); err != nil {
+		t.Fatal(err)
+	}
+	if bi.String() == "-"+u256 {
+		t.Error("want lossy value without WithBigNumbers")
+	}
+}
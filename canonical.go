@@ -0,0 +1,238 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// CanonicalHash writes the canonical form of the current value to h.
+// The canonical form has no whitespace and object keys sorted by their UTF-8 bytes.
+// Elements with duplicate keys are kept in document order.
+// Numbers are written by value, so `1`, `1.0` and `1e0` are identical, as are `0` and `-0.0`.
+// Floats are written like MarshalJSON, integers are written exactly.
+// Two documents that only differ in whitespace, key order and number notation
+// will therefore produce the same hash.
+// The canonical form is written in chunks and is never fully held in memory.
+// If no value is queued, the next value is used.
+// If the value is a root, its content is used.
+// The iterator is not advanced.
+func (i *Iter) CanonicalHash(h hash.Hash) error {
+	it := *i
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() == TypeRoot {
+		_, root, err := it.Root(nil)
+		if err != nil {
+			return err
+		}
+		it = *root
+	}
+	c := canonicalWriter{w: h}
+	if err := c.value(&it, 0); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+// MarshalJSONCanonical will append the canonical form of the current value to dst.
+// Like CanonicalHash, object keys are sorted by their UTF-8 bytes, nested objects included,
+// there is no whitespace and numbers are written by value.
+// Unlike CanonicalHash, only the last element of duplicate keys is kept,
+// so the output is valid for consumers that reject duplicate keys.
+// If no value is queued, the next value is used.
+// If the value is a root, its content is used.
+// The iterator is not advanced.
+func (i *Iter) MarshalJSONCanonical(dst []byte) ([]byte, error) {
+	it := *i
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() == TypeRoot {
+		_, root, err := it.Root(nil)
+		if err != nil {
+			return dst, err
+		}
+		it = *root
+	}
+	c := canonicalWriter{buf: dst, keepLast: true}
+	if err := c.value(&it, 0); err != nil {
+		return dst, err
+	}
+	return c.buf, nil
+}
+
+// canonicalFlushSize is the size at which buffered output is written.
+const canonicalFlushSize = 4 << 10
+
+// canonicalWriter writes the canonical form of values to w.
+// If w is nil, all output is kept in buf.
+type canonicalWriter struct {
+	w   io.Writer
+	buf []byte
+
+	// keepLast will only write the last element of duplicate keys.
+	keepLast bool
+
+	// elems contains scratch space for object elements for each depth.
+	elems [][]canonicalElem
+}
+
+type canonicalElem struct {
+	name []byte
+	iter Iter
+}
+
+func (c *canonicalWriter) flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	_, err := c.w.Write(c.buf)
+	c.buf = c.buf[:0]
+	return err
+}
+
+// value will write the value of i.
+func (c *canonicalWriter) value(i *Iter, depth int) error {
+	if c.w != nil && len(c.buf) >= canonicalFlushSize {
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+	if depth > maxdepth {
+		return errors.New("maximum depth exceeded")
+	}
+	switch i.t {
+	case TagString:
+		sb, err := i.StringBytes()
+		if err != nil {
+			return err
+		}
+		c.buf = append(c.buf, '"')
+		c.buf = escapeBytes(c.buf, sb)
+		c.buf = append(c.buf, '"')
+	case TagInteger:
+		v, err := i.Int()
+		if err != nil {
+			return err
+		}
+		c.buf = strconv.AppendInt(c.buf, v, 10)
+	case TagUint:
+		v, err := i.Uint()
+		if err != nil {
+			return err
+		}
+		c.buf = strconv.AppendUint(c.buf, v, 10)
+	case TagFloat:
+		v, err := i.Float()
+		if err != nil {
+			return err
+		}
+		if v == 0 {
+			// Write negative zero as 0.
+			v = 0
+		}
+		c.buf, err = appendFloat(c.buf, v)
+		if err != nil {
+			return err
+		}
+	case TagNull:
+		c.buf = append(c.buf, "null"...)
+	case TagBoolTrue:
+		c.buf = append(c.buf, "true"...)
+	case TagBoolFalse:
+		c.buf = append(c.buf, "false"...)
+	case TagArrayStart:
+		arr, err := i.Array(nil)
+		if err != nil {
+			return err
+		}
+		c.buf = append(c.buf, '[')
+		elems := arr.Iter()
+		var elem Iter
+		for n := 0; ; n++ {
+			t, err := elems.AdvanceIter(&elem)
+			if err != nil {
+				return err
+			}
+			if t == TypeNone {
+				break
+			}
+			if n > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			if err := c.value(&elem, depth+1); err != nil {
+				return err
+			}
+		}
+		c.buf = append(c.buf, ']')
+	case TagObjectStart:
+		obj, err := i.Object(nil)
+		if err != nil {
+			return err
+		}
+		for len(c.elems) <= depth {
+			c.elems = append(c.elems, nil)
+		}
+		elems := c.elems[depth][:0]
+		for {
+			var e canonicalElem
+			name, t, err := obj.NextElementBytes(&e.iter)
+			if err != nil {
+				return err
+			}
+			if t == TypeNone {
+				break
+			}
+			e.name = name
+			elems = append(elems, e)
+		}
+		c.elems[depth] = elems
+		sort.SliceStable(elems, func(a, b int) bool {
+			return bytes.Compare(elems[a].name, elems[b].name) < 0
+		})
+		c.buf = append(c.buf, '{')
+		first := true
+		for n := range elems {
+			if c.keepLast && n+1 < len(elems) && bytes.Equal(elems[n].name, elems[n+1].name) {
+				// The sort is stable, so a later duplicate follows.
+				continue
+			}
+			if !first {
+				c.buf = append(c.buf, ',')
+			}
+			first = false
+			c.buf = append(c.buf, '"')
+			c.buf = escapeBytes(c.buf, elems[n].name)
+			c.buf = append(c.buf, '"', ':')
+			if err := c.value(&elems[n].iter, depth+1); err != nil {
+				return err
+			}
+		}
+		c.buf = append(c.buf, '}')
+	default:
+		return fmt.Errorf("cannot write type %v", i.t.Type())
+	}
+	return nil
+}
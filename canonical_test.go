@@ -0,0 +1,159 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestIter_CanonicalHash(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	hashOf := func(t *testing.T, input string) []byte {
+		t.Helper()
+		pj, err := Parse([]byte(input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := sha256.New()
+		i := pj.Iter()
+		if err := i.CanonicalHash(h); err != nil {
+			t.Fatal(err)
+		}
+		return h.Sum(nil)
+	}
+
+	const canonical = `{"a":[1,2.5,"x\"y",null,true,false,{}],"b":{"c":100,"d":-0.001},"dup":1,"dup":2,"n":0,"u":18446744073709551615}`
+	want := sha256.Sum256([]byte(canonical))
+	same := []string{
+		canonical,
+		`{"u":18446744073709551615,"dup":1,"b":{"d":-1e-3,"c":100},"n":0,"dup":2,"a":[1,2.5,"x\"y",null,true,false,{}]}`,
+		`{
+			"b": { "c": 1.0e2, "d": -0.0010 },
+			"a": [ 1.0, 25e-1, "x\"y", null, true, false, { } ],
+			"n": -0.0, "dup": 1, "u": 18446744073709551615, "dup": 2
+		}`,
+	}
+	for _, input := range same {
+		if got := hashOf(t, input); !bytes.Equal(got, want[:]) {
+			t.Errorf("hash mismatch for %s", input)
+		}
+	}
+
+	for _, input := range []string{
+		`{"a":[2,1,2.5,"x\"y",null,true,false,{}],"b":{"c":100,"d":-0.001},"dup":1,"dup":2,"n":0,"u":18446744073709551615}`,
+		`{"a":[1,2.5,"x\"y",null,true,false,{}],"b":{"c":100,"d":-0.001},"dup":2,"dup":1,"n":0,"u":18446744073709551615}`,
+		`{"a":[1,2.5,"x\"y",null,true,false,[]],"b":{"c":100,"d":-0.001},"dup":1,"dup":2,"n":0,"u":18446744073709551615}`,
+		`{"a":[1,2.5,"x\"y",null,true,false,{}],"b":{"c":"100","d":-0.001},"dup":1,"dup":2,"n":0,"u":18446744073709551615}`,
+	} {
+		if got := hashOf(t, input); bytes.Equal(got, want[:]) {
+			t.Errorf("unexpected match for %s", input)
+		}
+	}
+
+	// Large documents are written in several chunks.
+	pj, err := Parse(loadCompressed(t, "twitter"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	c := canonicalWriter{w: &buf}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.value(root, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.flush(); err != nil {
+		t.Fatal(err)
+	}
+	canon, err := Parse(buf.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = canon.Iter()
+	if equal, err := i.EqualJSON(pj.Message, EqualOptions{}); err != nil || !equal {
+		t.Errorf("canonical form differs from input (err: %v)", err)
+	}
+	h := sha256.New()
+	i = pj.Iter()
+	if err := i.CanonicalHash(h); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h.Sum(nil), sha256.Sum256(buf.Bytes()); !bytes.Equal(got, want[:]) {
+		t.Error("hash does not match canonical form")
+	}
+}
+
+func TestIter_MarshalJSONCanonical(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input, want string
+	}{
+		{
+			input: `{ "b": {"z": 1.0e2, "a": [ {"y":-0.0,"x":25e-1} ]}, "a": "A", "dup": 1, "dup": 2 }`,
+			want:  `{"a":"A","b":{"a":[{"x":2.5,"y":0}],"z":100},"dup":2}`,
+		},
+		{
+			input: `[{"é":1,"z":2,"Z":3,"":4}, 18446744073709551615, -1e-3]`,
+			want:  `[{"":4,"Z":3,"z":2,"é":1},18446744073709551615,-0.001]`,
+		},
+		{
+			input: `{"a":{"k":1,"k":{"n":1,"m":2},"j":0}}`,
+			want:  `{"a":{"j":0,"k":{"m":2,"n":1}}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			pj, err := Parse([]byte(test.input), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			got, err := i.MarshalJSONCanonical([]byte("prefix:"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "prefix:" + test.want; string(got) != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		})
+	}
+
+	// Large documents are not flushed.
+	pj, err := Parse(loadCompressed(t, "twitter"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	got, err := i.MarshalJSONCanonical(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	if equal, err := i.EqualJSON(got, EqualOptions{}); err != nil || !equal {
+		t.Errorf("canonical form differs from input (err: %v)", err)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of the input.
+type Encoding uint8
+
+const (
+	// EncodingAuto will detect UTF-8 and UTF-16 input using a byte order mark.
+	// Input without a byte order mark is treated as UTF-8.
+	EncodingAuto Encoding = iota
+	// EncodingUTF8 is UTF-8 input. A leading byte order mark is removed.
+	EncodingUTF8
+	// EncodingUTF16LE is little endian UTF-16 input. A leading byte order mark is removed.
+	EncodingUTF16LE
+	// EncodingUTF16BE is big endian UTF-16 input. A leading byte order mark is removed.
+	EncodingUTF16BE
+	// EncodingLatin1 is ISO 8859-1 input.
+	EncodingLatin1
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingAuto:
+		return "auto"
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingUTF16LE:
+		return "UTF-16LE"
+	case EncodingUTF16BE:
+		return "UTF-16BE"
+	case EncodingLatin1:
+		return "Latin-1"
+	}
+	return fmt.Sprintf("Encoding(%d)", uint8(e))
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// ParseEncoding will parse input in the specified encoding.
+// Input that isn't UTF-8 is converted to UTF-8 before parsing.
+// The converted input is kept in a buffer, which is reused when reuse is supplied.
+// Invalid UTF-16 surrogates are replaced by the Unicode replacement character.
+// See Parse for details on parsing and reuse.
+func ParseEncoding(b []byte, enc Encoding, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	if enc == EncodingAuto {
+		switch {
+		case bytes.HasPrefix(b, bomUTF8):
+			enc = EncodingUTF8
+		case bytes.HasPrefix(b, bomUTF16LE):
+			enc = EncodingUTF16LE
+		case bytes.HasPrefix(b, bomUTF16BE):
+			enc = EncodingUTF16BE
+		default:
+			return Parse(b, reuse, opts...)
+		}
+	}
+	if enc == EncodingUTF8 {
+		return Parse(bytes.TrimPrefix(b, bomUTF8), reuse, opts...)
+	}
+	var buf []byte
+	if reuse != nil && reuse.internal != nil {
+		buf = reuse.internal.transcoded[:0]
+	}
+	var err error
+	buf, err = transcodeUTF8(buf, b, enc)
+	if err != nil {
+		return nil, err
+	}
+	pj, err := Parse(buf, reuse, opts...)
+	if err != nil {
+		return nil, err
+	}
+	pj.internal.transcoded = buf
+	return pj, nil
+}
+
+// transcodeUTF8 will append src in the specified encoding to dst as UTF-8.
+func transcodeUTF8(dst, src []byte, enc Encoding) ([]byte, error) {
+	switch enc {
+	case EncodingLatin1:
+		for _, c := range src {
+			if c < utf8.RuneSelf {
+				dst = append(dst, c)
+				continue
+			}
+			dst = utf8.AppendRune(dst, rune(c))
+		}
+		return dst, nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+	default:
+		return nil, fmt.Errorf("unknown encoding: %v", enc)
+	}
+	if len(src)%2 != 0 {
+		return nil, errors.New("odd length UTF-16 input")
+	}
+	read := func(b []byte) uint16 {
+		if enc == EncodingUTF16LE {
+			return uint16(b[0]) | uint16(b[1])<<8
+		}
+		return uint16(b[0])<<8 | uint16(b[1])
+	}
+	if len(src) >= 2 && read(src) == 0xfeff {
+		src = src[2:]
+	}
+	for len(src) >= 2 {
+		r := rune(read(src))
+		src = src[2:]
+		switch {
+		case r < utf8.RuneSelf:
+			dst = append(dst, byte(r))
+			continue
+		case utf16.IsSurrogate(r):
+			r2 := utf8.RuneError
+			if len(src) >= 2 {
+				r2 = rune(read(src))
+			}
+			if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+				r = dec
+				src = src[2:]
+			} else {
+				r = utf8.RuneError
+			}
+		}
+		dst = utf8.AppendRune(dst, r)
+	}
+	return dst, nil
+}
@@ -0,0 +1,89 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string, bigEndian, bom bool) []byte {
+	var dst []byte
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+	for _, u := range units {
+		if bigEndian {
+			dst = append(dst, byte(u>>8), byte(u))
+		} else {
+			dst = append(dst, byte(u), byte(u>>8))
+		}
+	}
+	return dst
+}
+
+func TestParseEncoding(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const doc = `{"name":"Zoë 😀","n":1}`
+	tests := []struct {
+		name  string
+		input []byte
+		enc   Encoding
+		want  string
+	}{
+		{name: "utf8", input: []byte(doc), enc: EncodingAuto, want: "Zoë 😀"},
+		{name: "utf8-bom", input: append([]byte{0xef, 0xbb, 0xbf}, doc...), enc: EncodingAuto, want: "Zoë 😀"},
+		{name: "utf8-bom-explicit", input: append([]byte{0xef, 0xbb, 0xbf}, doc...), enc: EncodingUTF8, want: "Zoë 😀"},
+		{name: "utf16le-bom", input: encodeUTF16(doc, false, true), enc: EncodingAuto, want: "Zoë 😀"},
+		{name: "utf16be-bom", input: encodeUTF16(doc, true, true), enc: EncodingAuto, want: "Zoë 😀"},
+		{name: "utf16le", input: encodeUTF16(doc, false, false), enc: EncodingUTF16LE, want: "Zoë 😀"},
+		{name: "utf16be", input: encodeUTF16(doc, true, true), enc: EncodingUTF16BE, want: "Zoë 😀"},
+		{name: "utf16-unpaired", input: []byte{'[', 0, '"', 0, 0x00, 0xd8, '"', 0, ']', 0}, enc: EncodingUTF16LE, want: "�"},
+		{name: "latin1", input: []byte("{\"name\":\"Zo\xeb \xa3\",\"n\":1}"), enc: EncodingLatin1, want: "Zoë £"},
+	}
+	var pj *ParsedJson
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var err error
+			pj, err = ParseEncoding(test.input, test.enc, pj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			v, err := i.Interface()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got interface{}
+			switch v := v.([]interface{})[0].(type) {
+			case map[string]interface{}:
+				got = v["name"]
+			case []interface{}:
+				got = v[0]
+			}
+			if got != test.want {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+	if _, err := ParseEncoding([]byte{'{', 0, '}'}, EncodingUTF16LE, nil); err == nil {
+		t.Error("want error for odd length input")
+	}
+}
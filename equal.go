@@ -0,0 +1,322 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// EqualOptions controls how values are compared by EqualJSON.
+type EqualOptions struct {
+	// IgnoreArrayOrder will consider arrays equal if they contain
+	// the same elements in any order.
+	IgnoreArrayOrder bool
+
+	// NumericEquality will compare integers and floats by value,
+	// so `1` and `1.0` are equal.
+	// When false integers and floats are considered different types.
+	NumericEquality bool
+}
+
+// EqualJSON returns whether the current value is structurally equal to the JSON in reference.
+// Whitespace and the order of object keys are ignored.
+// Objects with duplicate keys are equal if the same key/value pairs are present the same number of times.
+// Integers are compared exactly. Floats, and integers compared to floats, are compared as float64.
+// If no value is queued, the next value is compared.
+// If the value is a root, its content is compared.
+// The iterator is not advanced.
+func (i *Iter) EqualJSON(reference []byte, opts EqualOptions) (bool, error) {
+	ref, _, err := parseValue(reference, WithCopyStrings(false))
+	if err != nil {
+		return false, fmt.Errorf("parsing reference: %w", err)
+	}
+	it, err := equalValue(*i)
+	if err != nil {
+		return false, err
+	}
+	return valuesEqual(&it, &ref, opts)
+}
+
+// Equal returns whether the current value is semantically equal to the value of other.
+// The values may be from different ParsedJson.
+// Objects are equal if they have the same keys with equal values, in any order,
+// and arrays are equal if they have equal elements in the same order.
+// Strings are compared byte by byte.
+//
+// Numbers are compared by value, regardless of whether they are stored as int, uint or float:
+// Two integers (int or uint) are equal only if they have exactly the same value.
+// If either number is a float, both are converted to float64 and compared,
+// so `1` and `1.0` are equal, but integers above 2^53 may compare equal
+// to a float that is the nearest float64.
+//
+// If no value is queued, the next value is compared.
+// If a value is a root, its content is compared.
+// Neither iterator is advanced.
+func (i *Iter) Equal(other Iter) (bool, error) {
+	a, err := equalValue(*i)
+	if err != nil {
+		return false, err
+	}
+	b, err := equalValue(other)
+	if err != nil {
+		return false, err
+	}
+	return valuesEqual(&a, &b, EqualOptions{NumericEquality: true})
+}
+
+// equalValue returns the value of it that is compared.
+func equalValue(it Iter) (Iter, error) {
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() == TypeRoot {
+		var root Iter
+		if _, _, err := it.Root(&root); err != nil {
+			return it, err
+		}
+		it = root
+	}
+	return it, nil
+}
+
+// valuesEqual returns whether a and b are structurally equal.
+func valuesEqual(a, b *Iter, opts EqualOptions) (bool, error) {
+	ta, tb := a.Type(), b.Type()
+	if opts.NumericEquality && isNumberType(ta) && isNumberType(tb) {
+		return numbersEqual(a, b)
+	}
+	if ta != tb {
+		return false, nil
+	}
+	switch ta {
+	case TypeObject:
+		return objectsEqual(a, b, opts)
+	case TypeArray:
+		return arraysEqual(a, b, opts)
+	case TypeNone:
+		return true, nil
+	}
+	return scalarsEqual(a, b)
+}
+
+// isNumberType returns whether t is a numeric type.
+func isNumberType(t Type) bool {
+	return t == TypeInt || t == TypeUint || t == TypeFloat
+}
+
+// numbersEqual compares two numbers by value.
+func numbersEqual(a, b *Iter) (bool, error) {
+	ia, ua, fa, ka, err := a.Num()
+	if err != nil {
+		return false, err
+	}
+	ib, ub, fb, kb, err := b.Num()
+	if err != nil {
+		return false, err
+	}
+	if ka != NumberFloat && kb != NumberFloat {
+		// Uints are only used for values that do not fit in an int64.
+		return ka == kb && ia == ib && ua == ub, nil
+	}
+	toFloat := func(i int64, u uint64, f float64, k NumberKind) float64 {
+		switch k {
+		case NumberInt:
+			return float64(i)
+		case NumberUint:
+			return float64(u)
+		}
+		return f
+	}
+	return toFloat(ia, ua, fa, ka) == toFloat(ib, ub, fb, kb), nil
+}
+
+// objectsEqual compares the objects in a and b.
+// Elements are compared in lockstep while the keys are in the same order.
+// Only the remaining elements are compared by key if the order differs.
+func objectsEqual(a, b *Iter, opts EqualOptions) (bool, error) {
+	var objA, objB Object
+	if _, err := a.Object(&objA); err != nil {
+		return false, err
+	}
+	if _, err := b.Object(&objB); err != nil {
+		return false, err
+	}
+	var va, vb Iter
+	for {
+		restA, restB := objA, objB
+		nameA, ta, err := objA.NextElementBytes(&va)
+		if err != nil {
+			return false, err
+		}
+		nameB, tb, err := objB.NextElementBytes(&vb)
+		if err != nil {
+			return false, err
+		}
+		if ta == TypeNone || tb == TypeNone {
+			return ta == tb, nil
+		}
+		if bytes.Equal(nameA, nameB) {
+			equal, err := valuesEqual(&va, &vb, opts)
+			if err != nil {
+				return false, err
+			}
+			if equal {
+				continue
+			}
+		}
+		return objectsEqualByKey(&restA, &restB, opts)
+	}
+}
+
+// objectsEqualByKey compares the remaining elements of a and b by key.
+func objectsEqualByKey(a, b *Object, opts EqualOptions) (bool, error) {
+	elemsA, err := a.Parse(nil)
+	if err != nil {
+		return false, err
+	}
+	elemsB, err := b.Parse(nil)
+	if err != nil {
+		return false, err
+	}
+	if len(elemsA.Elements) != len(elemsB.Elements) {
+		return false, nil
+	}
+	// Index all occurrences of each key, so duplicate keys can be matched.
+	byName := make(map[string][]int, len(elemsB.Elements))
+	for n, e := range elemsB.Elements {
+		byName[e.Name] = append(byName[e.Name], n)
+	}
+	matched := make([]bool, len(elemsB.Elements))
+	for n := range elemsA.Elements {
+		ea := &elemsA.Elements[n]
+		found := false
+		for _, idx := range byName[ea.Name] {
+			if matched[idx] {
+				continue
+			}
+			equal, err := valuesEqual(&ea.Iter, &elemsB.Elements[idx].Iter, opts)
+			if err != nil {
+				return false, err
+			}
+			if equal {
+				matched[idx] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// arraysEqual compares the arrays in a and b.
+// Elements are compared in lockstep.
+// With IgnoreArrayOrder the remaining elements are matched in any order
+// after the first element that differs.
+func arraysEqual(a, b *Iter, opts EqualOptions) (bool, error) {
+	var arrA, arrB Array
+	if _, err := a.Array(&arrA); err != nil {
+		return false, err
+	}
+	if _, err := b.Array(&arrB); err != nil {
+		return false, err
+	}
+	ia, ib := arrA.Iter(), arrB.Iter()
+	var ea, eb Iter
+	for {
+		restA, restB := ia, ib
+		ta, err := ia.AdvanceIter(&ea)
+		if err != nil {
+			return false, err
+		}
+		tb, err := ib.AdvanceIter(&eb)
+		if err != nil {
+			return false, err
+		}
+		if ta == TypeNone || tb == TypeNone {
+			return ta == tb, nil
+		}
+		equal, err := valuesEqual(&ea, &eb, opts)
+		if err != nil {
+			return false, err
+		}
+		if equal {
+			continue
+		}
+		if !opts.IgnoreArrayOrder {
+			return false, nil
+		}
+		return arraysEqualUnordered(&restA, &restB, opts)
+	}
+}
+
+// arraysEqualUnordered returns whether the remaining elements
+// of a and b are equal in any order.
+func arraysEqualUnordered(a, b *Iter, opts EqualOptions) (bool, error) {
+	elemsA, err := remainingElems(a)
+	if err != nil {
+		return false, err
+	}
+	elemsB, err := remainingElems(b)
+	if err != nil {
+		return false, err
+	}
+	if len(elemsA) != len(elemsB) {
+		return false, nil
+	}
+	matched := make([]bool, len(elemsB))
+	for n := range elemsA {
+		found := false
+		for idx := range elemsB {
+			if matched[idx] {
+				continue
+			}
+			equal, err := valuesEqual(&elemsA[n], &elemsB[idx], opts)
+			if err != nil {
+				return false, err
+			}
+			if equal {
+				matched[idx] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// remainingElems returns an iterator for each remaining element of i.
+func remainingElems(i *Iter) ([]Iter, error) {
+	var elems []Iter
+	for {
+		var elem Iter
+		t, err := i.AdvanceIter(&elem)
+		if err != nil {
+			return nil, err
+		}
+		if t == TypeNone {
+			return elems, nil
+		}
+		elems = append(elems, elem)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"testing"
+)
+
+func TestIter_EqualJSON(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name      string
+		value     string
+		reference string
+		opts      EqualOptions
+		want      bool
+	}{
+		{name: "same", value: demo_json, reference: demo_json, want: true},
+		{name: "key-order", value: `{"a":1,"b":{"c":"x","d":null}}`, reference: ` { "b" : { "d":null, "c":"x" }, "a":1 }`, want: true},
+		{name: "value-differs", value: `{"a":1,"b":2}`, reference: `{"a":1,"b":3}`},
+		{name: "missing-key", value: `{"a":1,"b":2}`, reference: `{"a":1}`},
+		{name: "other-key", value: `{"a":1,"b":2}`, reference: `{"a":1,"c":2}`},
+		{name: "duplicate-keys", value: `{"a":1,"a":2}`, reference: `{"a":2,"a":1}`, want: true},
+		{name: "duplicate-keys-differ", value: `{"a":1,"a":1}`, reference: `{"a":1,"a":2}`},
+		{name: "array-order", value: `[1,2,3]`, reference: `[3,2,1]`},
+		{name: "array-order-ignored", value: `[1,[2],{"a":3},2]`, reference: `[{"a":3},2,[2],1]`, opts: EqualOptions{IgnoreArrayOrder: true}, want: true},
+		{name: "array-multiset", value: `[1,1,2]`, reference: `[1,2,2]`, opts: EqualOptions{IgnoreArrayOrder: true}},
+		{name: "array-length", value: `[1,2]`, reference: `[1,2,3]`},
+		{name: "int-float", value: `[1]`, reference: `[1.0]`},
+		{name: "int-float-numeric", value: `[1,-2,18446744073709551615]`, reference: `[1.0,-2e0,18446744073709551615]`, opts: EqualOptions{NumericEquality: true}, want: true},
+		{name: "int-numeric-differs", value: `[1]`, reference: `[1.5]`, opts: EqualOptions{NumericEquality: true}},
+		{name: "bool", value: `[true]`, reference: `[false]`},
+		{name: "string", value: `["aæ"]`, reference: `["aæ"]`, want: true},
+		{name: "null-string", value: `[null]`, reference: `["null"]`},
+		{name: "scalar-reference", value: `{"a":"x"}`, reference: `"x"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pj, err := Parse([]byte(test.value), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			got, err := i.EqualJSON([]byte(test.reference), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("want %v, got %v", test.want, got)
+			}
+			// Compare the other way.
+			pj2, err := Parse([]byte(test.reference), nil)
+			if err != nil {
+				// Scalar reference.
+				return
+			}
+			i2 := pj2.Iter()
+			got, err = i2.EqualJSON([]byte(test.value), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("reversed: want %v, got %v", test.want, got)
+			}
+		})
+	}
+
+	// Compare a subtree.
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	elem, err := i.FindElement(nil, "Image", "Thumbnail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal, err := elem.Iter.EqualJSON([]byte(`{"Width":100,"Height":125,"Url":"http://www.example.com/image/481989943"}`), EqualOptions{})
+	if err != nil || !equal {
+		t.Errorf("want equal, got %v (err: %v)", equal, err)
+	}
+	elem, err = i.FindElement(nil, "Image", "IDs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal, err = elem.Iter.EqualJSON([]byte(`[943, 116, 234, 38793]`), EqualOptions{IgnoreArrayOrder: true})
+	if err != nil || !equal {
+		t.Errorf("want equal, got %v (err: %v)", equal, err)
+	}
+	if _, err := elem.Iter.EqualJSON([]byte(`[1,`), EqualOptions{}); err == nil {
+		t.Error("want error for invalid reference")
+	}
+}
+
+func TestIter_Equal(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: `{"a":1,"b":[1,"x",null]}`, b: `{"b":[1.0,"x",null],"a":1e0}`, want: true},
+		{a: `{"a":{"c":true,"d":[]}}`, b: `{"a":{"d":[],"c":true}}`, want: true},
+		{a: `[1,2]`, b: `[2,1]`},
+		{a: `[-1,18446744073709551615]`, b: `[-1.0,18446744073709551615]`, want: true},
+		{a: `[9223372036854775807]`, b: `[9223372036854775806]`},
+		{a: `[1]`, b: `[1.5]`},
+		{a: `["1"]`, b: `[1]`},
+		{a: `{"a":1}`, b: `{"a":1,"b":2}`},
+		{a: `[{}]`, b: `[[]]`},
+		{a: `{"a":1,"b":2,"c":{"d":3,"e":4}}`, b: `{"a":1,"c":{"e":4,"d":3},"b":2}`, want: true},
+		{a: `{"a":1,"b":2,"c":3}`, b: `{"a":1,"c":3,"d":2}`},
+		{a: `{"a":1,"a":2,"b":3}`, b: `{"a":2,"a":1,"b":3}`, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.a+test.b, func(t *testing.T) {
+			pjA, err := Parse([]byte(test.a), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pjB, err := Parse([]byte(test.b), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, b := pjA.Iter(), pjB.Iter()
+			got, err := a.Equal(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("want %v, got %v", test.want, got)
+			}
+			got, err = b.Equal(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("reversed: want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestIter_EqualAllocs(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	ref := loadCompressed(t, "twitter")
+	pjA, err := Parse(ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pjB, err := Parse(ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := pjA.Iter(), pjB.Iter()
+	var equal bool
+	allocs := testing.AllocsPerRun(10, func() {
+		equal, err = a.Equal(b)
+	})
+	if err != nil || !equal {
+		t.Fatalf("want equal, got %v (err: %v)", equal, err)
+	}
+	if allocs > 0 {
+		t.Errorf("want no allocations, got %v", allocs)
+	}
+}
@@ -206,17 +206,7 @@ func populateTagParams(bidReqBody []byte) (*TagParams, error) {
 			if err != nil {
 				continue
 			}
-			userObjPropsIter := &simdjson.Iter{}
-			for {
-				userPropName, userPropType, userPropErr := userObj.NextElementBytes(userObjPropsIter)
-				if userPropType == simdjson.TypeNone || userPropErr != nil {
-					break
-				}
-				switch string(userPropName) {
-				case "id":
-					userId, _ = userObjPropsIter.String()
-				}
-			}
+			userId, _ = userObj.GetString("id")
 		case "app":
 			appObj := &simdjson.Object{}
 			_, err = l1PropsIter.Object(appObj)
@@ -0,0 +1,192 @@
+//go:build !noasm && !appengine && gc
+// +build !noasm,!appengine,gc
+
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+// extractPool contains parsers that can be reused by ExtractField.
+var extractPool sync.Pool
+
+// ExtractField will return the value of a single top-level key in the object in data.
+// This is intended for extracting a single value, like a type discriminator,
+// from large documents without parsing the complete document.
+//
+// The structure of the document is only found until the value of key is complete,
+// and only the value is parsed into a tape.
+// The returned ParsedJson only contains the extracted value,
+// so the rest of the document cannot be accessed and is not validated.
+// The returned Iter has the value queued.
+// If the object contains the key more than once the first value is returned.
+// ErrPathNotFound is returned if the key cannot be found.
+func ExtractField(data []byte, key string) (Iter, *ParsedJson, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return Iter{}, nil, ErrEmptyInput
+	}
+	if data[0] != '{' {
+		return Iter{}, nil, errors.New("extract field: input is not an object")
+	}
+	if !SupportedCPU() {
+		return Iter{}, nil, errors.New("Host CPU does not meet target specs")
+	}
+	pj, _ := extractPool.Get().(*internalParsedJson)
+	if pj == nil {
+		pj = &internalParsedJson{indexChans: make(chan indexChan, indexSlots-2)}
+	}
+	defer func() {
+		pj.Message = nil
+		pj.done = nil
+		extractPool.Put(pj)
+	}()
+	pj.Message = data
+	pj.ndjson = 0
+	pj.aborted = false
+	pj.indexesChan = indexChan{}
+	pj.buffersOffset = ^uint64(0)
+
+	// Closing stop will end stage 1 early.
+	stop := make(chan struct{})
+	pj.done = stop
+	stage1 := make(chan bool, 1)
+	go func() {
+		stage1 <- pj.findStructuralIndices()
+	}()
+	start, end, found, err := pj.findField(key)
+	if found {
+		close(stop)
+	}
+	if pj.indexesChan.index != -1 {
+		// Consume until stage 1 is done.
+		for idx := range pj.indexChans {
+			if idx.index == -1 {
+				break
+			}
+		}
+	}
+	if !<-stage1 && !found {
+		return Iter{}, nil, errors.New("Failed to find all structural indices for stage 1")
+	}
+	if err != nil {
+		return Iter{}, nil, err
+	}
+	if !found {
+		return Iter{}, nil, ErrPathNotFound
+	}
+	return parseValue(pj.Message[start:end])
+}
+
+// findField will read structural indexes of the root object until the value of key is found.
+// The start and end offsets of the value in the message is returned.
+// The value may contain whitespace at the end.
+func (pj *internalParsedJson) findField(key string) (start, end uint64, found bool, err error) {
+	buf := pj.Message
+	idx := ^uint64(0)
+	next := func() bool {
+		var done bool
+		done, idx = updateChar(pj, idx)
+		return !done && idx < uint64(len(buf))
+	}
+	errStructure := errors.New("extract field: invalid object structure")
+	if !next() || buf[idx] != '{' || !next() {
+		return 0, 0, false, errStructure
+	}
+	if buf[idx] == '}' {
+		return 0, 0, false, nil
+	}
+	for {
+		if buf[idx] != '"' {
+			return 0, 0, false, errStructure
+		}
+		keyStart := idx
+		if !next() || buf[idx] != ':' {
+			return 0, 0, false, errStructure
+		}
+		match, err := keyEquals(buf[keyStart:idx], key)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if !next() {
+			return 0, 0, false, errStructure
+		}
+		start = idx
+		if c := buf[idx]; c == '{' || c == '[' {
+			for depth := 1; depth > 0; {
+				if !next() {
+					return 0, 0, false, errStructure
+				}
+				switch buf[idx] {
+				case '{', '[':
+					depth++
+				case '}', ']':
+					depth--
+				}
+			}
+			end = idx + 1
+			if !next() {
+				return 0, 0, false, errStructure
+			}
+		} else {
+			// Scalars end at the next structural character.
+			if !next() {
+				return 0, 0, false, errStructure
+			}
+			end = idx
+		}
+		switch buf[idx] {
+		case ',':
+			if match {
+				return start, end, true, nil
+			}
+			if !next() {
+				return 0, 0, false, errStructure
+			}
+		case '}':
+			return start, end, match, nil
+		default:
+			return 0, 0, false, errStructure
+		}
+	}
+}
+
+// keyEquals returns whether the quoted key in b equals key.
+// b must start with the opening quote and may contain whitespace after the closing quote.
+func keyEquals(b []byte, key string) (bool, error) {
+	q := bytes.LastIndexByte(b, '"')
+	if q <= 0 {
+		return false, errors.New("extract field: invalid key")
+	}
+	raw := b[1:q]
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw) == key, nil
+	}
+	i, _, err := parseValue(b[:q+1], WithCopyStrings(false))
+	if err != nil {
+		return false, err
+	}
+	s, err := i.String()
+	if err != nil {
+		return false, err
+	}
+	return s == key, nil
+}
@@ -0,0 +1,135 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtractField(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = ` {"type" : "event", "n":-1.5e3 ,"obj":{"a":[1,{"b":"}]"}]},"arr":[[],{}], "esc\"aped":true, "abc":null, "type":"dupe", "last":"x"} `
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "type", want: `"event"`},
+		{key: "n", want: `-1500`},
+		{key: "obj", want: `{"a":[1,{"b":"}]"}]}`},
+		{key: "arr", want: `[[],{}]`},
+		{key: `esc"aped`, want: `true`},
+		{key: "abc", want: `null`},
+		{key: "last", want: `"x"`},
+	}
+	for _, test := range tests {
+		i, pj, err := ExtractField([]byte(input), test.key)
+		if err != nil {
+			t.Errorf("%s: %v", test.key, err)
+			continue
+		}
+		if pj == nil {
+			t.Errorf("%s: no ParsedJson returned", test.key)
+		}
+		got, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: want %s, got %s", test.key, test.want, got)
+		}
+	}
+
+	for _, key := range []string{"missing", "a", "b", ""} {
+		if _, _, err := ExtractField([]byte(input), key); !errors.Is(err, ErrPathNotFound) {
+			t.Errorf("%s: want ErrPathNotFound, got %v", key, err)
+		}
+	}
+	if _, _, err := ExtractField([]byte(`{}`), "a"); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("empty object: want ErrPathNotFound, got %v", err)
+	}
+
+	for _, in := range []string{``, `[1]`, `{"a" 1}`, `{"a":1 "b":2}`, `{"a":}`, `{"a":{"b":1}`, `{"a":tru}`} {
+		if _, _, err := ExtractField([]byte(in), "a"); err == nil {
+			t.Errorf("%s: want error", in)
+		}
+	}
+
+	// The rest of a big document is not read.
+	var big bytes.Buffer
+	big.WriteString(`{"type":"big","data":[`)
+	for big.Len() < 10<<20 {
+		big.WriteString(`{"key":"value","n":[1,2,3]},`)
+	}
+	big.WriteString(`1], "trailer": "end"}`)
+	i, _, err := ExtractField(big.Bytes(), "type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := i.String(); s != "big" {
+		t.Errorf("want big, got %q", s)
+	}
+	i, _, err = ExtractField(big.Bytes(), "trailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := i.String(); s != "end" {
+		t.Errorf("want end, got %q", s)
+	}
+	if _, _, err := ExtractField(big.Bytes(), "missing"); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("want ErrPathNotFound, got %v", err)
+	}
+	if _, _, err := ExtractField([]byte(strings.TrimSuffix(big.String(), "}")), "missing"); err == nil || errors.Is(err, ErrPathNotFound) {
+		t.Errorf("want structure error, got %v", err)
+	}
+}
+
+func BenchmarkExtractField(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	tw := loadCompressed(b, "twitter")
+	msg := make([]byte, 0, len(tw)+100)
+	msg = append(msg, `{"type":"tweets","data":`...)
+	msg = append(msg, tw...)
+	msg = append(msg, '}')
+	b.Run("extract", func(b *testing.B) {
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := ExtractField(msg, "type"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parse", func(b *testing.B) {
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		var pj *ParsedJson
+		for i := 0; i < b.N; i++ {
+			var err error
+			pj, err = Parse(msg, pj)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
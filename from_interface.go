@@ -0,0 +1,154 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// FromInterface builds a ParsedJson containing a single root with the value v.
+// v can be a tree of map[string]interface{}, []interface{}, string, bool, nil
+// and Go integer and float types, like the values returned by Iter.Interface.
+// Object keys are written in sorted order.
+// Like when parsing, unsigned values that fit in an int64 are stored as integers.
+// NaN and infinite floats are rejected, since they cannot be represented in JSON.
+// The returned ParsedJson does not reference v.
+// If dst is nil a new ParsedJson is allocated, otherwise its buffers are reused.
+func FromInterface(v interface{}, dst *ParsedJson) (*ParsedJson, error) {
+	if dst == nil {
+		dst = &ParsedJson{}
+	}
+	dst.Tape = dst.Tape[:0]
+	if dst.Strings == nil {
+		dst.Strings = &TStrings{}
+	}
+	dst.Strings.B = dst.Strings.B[:0]
+	dst.Message = nil
+	dst.rawStrings = nil
+	dst.spans = nil
+
+	dst.write_tape(0, byte(TagRoot))
+	if err := dst.appendInterface(v, 0); err != nil {
+		return nil, err
+	}
+	dst.write_tape(0, byte(TagRoot))
+	dst.annotate_previousloc(0, dst.get_current_loc())
+	return dst, nil
+}
+
+// appendInterface will append the value v to the tape.
+func (pj *ParsedJson) appendInterface(v interface{}, depth int) error {
+	switch v := v.(type) {
+	case nil:
+		pj.write_tape(0, byte(TagNull))
+	case bool:
+		if v {
+			pj.write_tape(0, byte(TagBoolTrue))
+		} else {
+			pj.write_tape(0, byte(TagBoolFalse))
+		}
+	case string:
+		pj.writeTapeString(v)
+	case float64:
+		return pj.writeTapeFloat(v)
+	case float32:
+		return pj.writeTapeFloat(float64(v))
+	case int:
+		pj.write_tape_s64(int64(v))
+	case int8:
+		pj.write_tape_s64(int64(v))
+	case int16:
+		pj.write_tape_s64(int64(v))
+	case int32:
+		pj.write_tape_s64(int64(v))
+	case int64:
+		pj.write_tape_s64(v)
+	case uint:
+		pj.writeTapeUint(uint64(v))
+	case uint8:
+		pj.writeTapeUint(uint64(v))
+	case uint16:
+		pj.writeTapeUint(uint64(v))
+	case uint32:
+		pj.writeTapeUint(uint64(v))
+	case uint64:
+		pj.writeTapeUint(v)
+	case map[string]interface{}:
+		if depth >= maxdepth {
+			return errors.New("maximum depth exceeded")
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		start := pj.get_current_loc()
+		pj.write_tape(0, byte(TagObjectStart))
+		for _, k := range keys {
+			pj.writeTapeString(k)
+			if err := pj.appendInterface(v[k], depth+1); err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+		}
+		pj.write_tape(start, byte(TagObjectEnd))
+		pj.annotate_previousloc(start, pj.get_current_loc())
+	case []interface{}:
+		if depth >= maxdepth {
+			return errors.New("maximum depth exceeded")
+		}
+		start := pj.get_current_loc()
+		pj.write_tape(0, byte(TagArrayStart))
+		for n, elem := range v {
+			if err := pj.appendInterface(elem, depth+1); err != nil {
+				return fmt.Errorf("index %d: %w", n, err)
+			}
+		}
+		pj.write_tape(start, byte(TagArrayEnd))
+		pj.annotate_previousloc(start, pj.get_current_loc())
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+// writeTapeString will append s to the string buffer and tape.
+func (pj *ParsedJson) writeTapeString(s string) {
+	pj.write_tape(STRINGBUFBIT|uint64(len(pj.Strings.B)), byte(TagString))
+	pj.Tape = append(pj.Tape, uint64(len(s)))
+	pj.Strings.B = append(pj.Strings.B, s...)
+}
+
+// writeTapeFloat will append f to the tape.
+func (pj *ParsedJson) writeTapeFloat(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("unsupported float value %v", f)
+	}
+	pj.write_tape_double(f)
+	return nil
+}
+
+// writeTapeUint will append u to the tape.
+func (pj *ParsedJson) writeTapeUint(u uint64) {
+	if u <= math.MaxInt64 {
+		pj.write_tape_s64(int64(u))
+		return
+	}
+	pj.writeTapeTagVal(TagUint, u)
+}
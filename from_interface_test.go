@@ -0,0 +1,114 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromInterface(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "null", value: nil, want: `null`},
+		{name: "string", value: "a\"æ", want: `"a\"æ"`},
+		{name: "ints", value: []interface{}{-1, int8(2), int16(3), int32(4), int64(5)}, want: `[-1,2,3,4,5]`},
+		{name: "uints", value: []interface{}{uint(1), uint8(2), uint16(3), uint32(4), uint64(math.MaxUint64)}, want: `[1,2,3,4,18446744073709551615]`},
+		{name: "floats", value: []interface{}{1.5, float32(0.25), -1e300}, want: `[1.5,0.25,-1e+300]`},
+		{name: "object", value: map[string]interface{}{"b": true, "a": []interface{}{}, "c": map[string]interface{}{}}, want: `{"a":[],"b":true,"c":{}}`},
+		{name: "nested", value: []interface{}{map[string]interface{}{"x": []interface{}{false, nil, "y"}}}, want: `[{"x":[false,null,"y"]}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pj, err := FromInterface(test.value, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			got, err := i.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("want %s, got %s", test.want, got)
+			}
+		})
+	}
+
+	for _, v := range []interface{}{
+		math.NaN(),
+		[]interface{}{math.Inf(1)},
+		map[string]interface{}{"a": struct{}{}},
+		[]string{"a"},
+	} {
+		if _, err := FromInterface(v, nil); err == nil {
+			t.Errorf("%#v: want error", v)
+		}
+	}
+	var deep interface{} = "x"
+	for n := 0; n <= maxdepth; n++ {
+		deep = []interface{}{deep}
+	}
+	if _, err := FromInterface(deep, nil); err == nil || !strings.Contains(err.Error(), "maximum depth") {
+		t.Errorf("want depth error, got %v", err)
+	}
+}
+
+func TestFromInterfaceRoundTrip(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	want, err := i.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rebuild into a reused destination and serialize.
+	var dst ParsedJson
+	got, err := FromInterface(want.([]interface{})[0], &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSerializer()
+	got, err = s.Deserialize(s.Serialize(nil, *got), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = got.Iter()
+	gotV, err := i.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, gotV) {
+		t.Errorf("mismatch:\nwant: %#v\ngot : %#v", want, gotV)
+	}
+	i = got.Iter()
+	equal, err := i.EqualJSON([]byte(demo_json), EqualOptions{})
+	if err != nil || !equal {
+		t.Errorf("want equal, got %v (err: %v)", equal, err)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"go/token"
 	"io"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -406,3 +407,95 @@ func parseCorpusValue(line []byte) ([]byte, error) {
 	}
 	return nil, fmt.Errorf("expected []byte")
 }
+
+// FuzzInterface will check that Iter.Interface matches a recursive conversion.
+func FuzzInterface(f *testing.F) {
+	if !SupportedCPU() {
+		f.SkipNow()
+	}
+	addBytesFromTarZst(f, "testdata/fuzz/corpus.tar.zst", testing.Short())
+	addBytesFromTarZst(f, "testdata/fuzz/go-corpus.tar.zst", testing.Short())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pj, err := Parse(data, nil)
+		if err != nil {
+			pj, err = ParseND(data, pj)
+			if err != nil {
+				// Don't continue
+				t.SkipNow()
+			}
+		}
+		i := pj.Iter()
+		got, gotErr := i.Interface()
+		i = pj.Iter()
+		want, wantErr := interfaceRecursive(&i)
+		if (gotErr != nil) != (wantErr != nil) {
+			t.Fatalf("error mismatch: got %v, want %v", gotErr, wantErr)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("output mismatch:\ngot : %#v\nwant: %#v", got, want)
+		}
+	})
+}
+
+// interfaceRecursive is a recursive reference implementation of Iter.Interface.
+func interfaceRecursive(i *Iter) (interface{}, error) {
+	switch i.Type() {
+	case TypeRoot:
+		var dst []interface{}
+		var tmp Iter
+		for {
+			typ, obj, err := i.Root(&tmp)
+			if err != nil {
+				return nil, err
+			}
+			if typ == TypeNone {
+				break
+			}
+			elem, err := interfaceRecursive(obj)
+			if err != nil {
+				return nil, err
+			}
+			dst = append(dst, elem)
+			if i.Advance() != TypeRoot {
+				break
+			}
+		}
+		return dst, nil
+	case TypeArray:
+		arr, err := i.Array(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst := make([]interface{}, 0)
+		it := arr.Iter()
+		for it.Advance() != TypeNone {
+			v, err := interfaceRecursive(&it)
+			if err != nil {
+				return nil, err
+			}
+			dst = append(dst, v)
+		}
+		return dst, nil
+	case TypeObject:
+		obj, err := i.Object(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst := make(map[string]interface{})
+		var tmp Iter
+		for {
+			name, t, err := obj.NextElement(&tmp)
+			if err != nil {
+				return nil, err
+			}
+			if t == TypeNone {
+				return dst, nil
+			}
+			dst[name], err = interfaceRecursive(&tmp)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return i.Interface()
+}
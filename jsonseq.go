@@ -0,0 +1,79 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import "io"
+
+// recordSeparator starts each record in a JSON text sequence.
+const recordSeparator = 0x1e
+
+// ParseJSONSeq will parse a JSON text sequence as defined in RFC 7464 (application/json-seq).
+// Each record starts with a record separator (0x1E) and is usually followed by a line feed.
+// Each record must contain a single object or array, which is stored as a separate root like ParseND.
+// Line feeds within records are treated as whitespace, so records can be pretty printed.
+// Empty records are skipped.
+//
+// The input is converted to newline delimited JSON before parsing.
+// The converted input is kept in a buffer, which is reused when reuse is supplied.
+// See ParseND for details on parsing and reuse.
+func ParseJSONSeq(b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	var buf []byte
+	if reuse != nil && reuse.internal != nil {
+		buf = reuse.internal.transcoded[:0]
+	}
+	buf = append(buf, b...)
+	seqToNDJSON(buf)
+	pj, err := ParseND(buf, reuse, opts...)
+	if err != nil {
+		return nil, err
+	}
+	pj.internal.transcoded = buf
+	return pj, nil
+}
+
+// ParseJSONSeqStream will parse a JSON text sequence from r like ParseJSONSeq,
+// and return the parsed JSON to the supplied result channel.
+// See ParseNDStream for how results are returned and how reuse is handled.
+func ParseJSONSeqStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson, opts ...ParserOption) {
+	ParseNDStream(seqReader{r: r}, res, reuse, opts...)
+}
+
+// seqToNDJSON converts a JSON text sequence to NDJSON in place.
+// Line feeds are replaced by spaces, and record separators by line feeds.
+// Neither can appear unescaped within JSON strings,
+// so line feeds in valid records are always whitespace.
+func seqToNDJSON(b []byte) {
+	for i, c := range b {
+		switch c {
+		case '\n':
+			b[i] = ' '
+		case recordSeparator:
+			b[i] = '\n'
+		}
+	}
+}
+
+// seqReader converts a JSON text sequence to NDJSON while reading.
+type seqReader struct {
+	r io.Reader
+}
+
+func (s seqReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	seqToNDJSON(p[:n])
+	return n, err
+}
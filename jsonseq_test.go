@@ -0,0 +1,108 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseJSONSeq(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const rs = "\x1e"
+	tests := []struct {
+		name  string
+		input string
+		want  string // Roots marshaled separated by newlines. Empty means error.
+	}{
+		{name: "lf", input: rs + `{"a":1}` + "\n" + rs + `[1,2]` + "\n", want: "{\"a\":1}\n[1,2]"},
+		{name: "no-lf", input: rs + `{"a":1}` + rs + `[1,2]`, want: "{\"a\":1}\n[1,2]"},
+		{name: "pretty", input: rs + "{\n  \"a\": [\n    1\n  ]\n}\n" + rs + "{\"b\":\"x\\ny\"}\n", want: "{\"a\":[1]}\n{\"b\":\"x\\ny\"}"},
+		{name: "empty-records", input: rs + rs + "\n" + rs + `{}` + "\n" + rs, want: "{}"},
+		{name: "multiple-values", input: rs + "{}\n{}\n"},
+		{name: "invalid", input: rs + `{"a":}` + "\n"},
+		{name: "empty", input: rs + "\n"},
+	}
+	var reuse *ParsedJson
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pj, err := ParseJSONSeq([]byte(test.input), reuse)
+			if test.want == "" {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			reuse = pj
+			i := pj.Iter()
+			got, err := i.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseJSONSeqStream(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	var sb strings.Builder
+	var want strings.Builder
+	for i := 0; i < 1000; i++ {
+		sb.WriteString("\x1e{\n\"n\": ")
+		sb.WriteString(strings.Repeat("1", 1+i%10))
+		sb.WriteString("\n}\n")
+		if i > 0 {
+			want.WriteByte('\n')
+		}
+		want.WriteString(`{"n":` + strings.Repeat("1", 1+i%10) + `}`)
+	}
+	res := make(chan Stream, 10)
+	ParseJSONSeqStream(iotest.HalfReader(strings.NewReader(sb.String())), res, nil)
+	var got []byte
+	for r := range res {
+		if r.Error != nil {
+			if r.Error != io.EOF {
+				t.Fatal(r.Error)
+			}
+			break
+		}
+		if len(got) > 0 {
+			got = append(got, '\n')
+		}
+		i := r.Value.Iter()
+		var err error
+		got, err = i.MarshalJSONBuffer(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(got) != want.String() {
+		t.Errorf("want %.200s, got %.200s", want.String(), got)
+	}
+}
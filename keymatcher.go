@@ -0,0 +1,112 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+// KeyMatcher matches object keys against a fixed set of keys.
+// It is intended for use with Object.NextElementBytes when many
+// different keys are extracted from the same object.
+// A KeyMatcher is safe for concurrent use.
+type KeyMatcher struct {
+	keys []string
+	// table contains the index+1 of the key in each slot, 0 for empty.
+	table []uint32
+	seed  uint32
+	mask  uint32
+	// full will hash all bytes of the key instead of a sample.
+	full bool
+}
+
+// NewKeyMatcher returns a matcher for the supplied keys.
+// Match will return the index of the key in keys.
+// If a key is supplied more than once, the first index is returned.
+func NewKeyMatcher(keys ...string) *KeyMatcher {
+	m := KeyMatcher{keys: keys}
+	if len(keys) == 0 {
+		return &m
+	}
+	size := 4
+	for size < len(keys)*2 {
+		size <<= 1
+	}
+	// Find a table size and seed without collisions.
+	// Start by only hashing the length and a few bytes of each key,
+	// and hash the full key if that isn't enough to tell keys apart.
+	for tries := 0; ; tries++ {
+		m.table = make([]uint32, size)
+		m.mask = uint32(size - 1)
+		m.full = tries >= 2
+		for seed := uint32(0); seed < 64; seed++ {
+			m.seed = seed
+			if m.fill() {
+				return &m
+			}
+		}
+		size <<= 1
+	}
+}
+
+// fill the table with the current seed.
+// Returns false if two different keys hash to the same slot.
+func (m *KeyMatcher) fill() bool {
+	for i := range m.table {
+		m.table[i] = 0
+	}
+	for i, key := range m.keys {
+		slot := m.hash([]byte(key)) & m.mask
+		if idx := m.table[slot]; idx != 0 {
+			if m.keys[idx-1] == key {
+				continue
+			}
+			return false
+		}
+		m.table[slot] = uint32(i + 1)
+	}
+	return true
+}
+
+// hash returns the hash of the key.
+func (m *KeyMatcher) hash(key []byte) uint32 {
+	if m.full {
+		// FNV-1a
+		h := uint32(2166136261) ^ m.seed
+		for _, c := range key {
+			h ^= uint32(c)
+			h *= 16777619
+		}
+		return h
+	}
+	v := uint32(len(key))
+	if n := len(key); n > 0 {
+		v |= uint32(key[0])<<8 | uint32(key[n/2])<<16 | uint32(key[n-1])<<24
+	}
+	v = (v ^ m.seed) * 0x9e3779b1
+	return v ^ v>>16
+}
+
+// Match returns the index of key in the keys supplied to NewKeyMatcher.
+// -1 is returned if the key is not matched.
+// Match does not allocate.
+func (m *KeyMatcher) Match(key []byte) int {
+	if len(m.table) == 0 {
+		return -1
+	}
+	idx := m.table[m.hash(key)&m.mask]
+	if idx == 0 || m.keys[idx-1] != string(key) {
+		return -1
+	}
+	return int(idx - 1)
+}
@@ -0,0 +1,160 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"fmt"
+	"log"
+	"testing"
+)
+
+var testBidRequestKeys = []string{
+	"id", "imp", "site", "app", "device", "user", "test", "at", "tmax", "wseat",
+	"bseat", "allimps", "cur", "wlang", "bcat", "badv", "bapp", "source", "regs", "ext",
+}
+
+func TestKeyMatcher(t *testing.T) {
+	m := NewKeyMatcher(testBidRequestKeys...)
+	for i, key := range testBidRequestKeys {
+		if got := m.Match([]byte(key)); got != i {
+			t.Errorf("%s: want %d, got %d", key, i, got)
+		}
+	}
+	for _, key := range []string{"", "i", "idx", "IMP", "tmax ", "extra"} {
+		if got := m.Match([]byte(key)); got != -1 {
+			t.Errorf("%q: want -1, got %d", key, got)
+		}
+	}
+	dup := NewKeyMatcher("a", "b", "a")
+	if got := dup.Match([]byte("a")); got != 0 {
+		t.Errorf("duplicate: want 0, got %d", got)
+	}
+	// Keys that only differ in bytes not sampled by the fast hash.
+	similar := NewKeyMatcher("abcde", "axcde", "abcye")
+	for i, key := range []string{"abcde", "axcde", "abcye"} {
+		if got := similar.Match([]byte(key)); got != i {
+			t.Errorf("similar %s: want %d, got %d", key, i, got)
+		}
+	}
+	empty := NewKeyMatcher()
+	if got := empty.Match([]byte("a")); got != -1 {
+		t.Errorf("empty: want -1, got %d", got)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Match([]byte("bcat"))
+	})
+	if allocs != 0 {
+		t.Errorf("want 0 allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkKeyMatcher(b *testing.B) {
+	keys := make([][]byte, len(testBidRequestKeys))
+	for i, k := range testBidRequestKeys {
+		keys[i] = []byte(k)
+	}
+	b.Run("matcher", func(b *testing.B) {
+		m := NewKeyMatcher(testBidRequestKeys...)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, k := range keys {
+				if m.Match(k) < 0 {
+					b.Fatal("not found")
+				}
+			}
+		}
+	})
+	b.Run("map", func(b *testing.B) {
+		m := make(map[string]int, len(testBidRequestKeys))
+		for i, k := range testBidRequestKeys {
+			m[k] = i
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, k := range keys {
+				if _, ok := m[string(k)]; !ok {
+					b.Fatal("not found")
+				}
+			}
+		}
+	})
+	b.Run("compare", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, k := range keys {
+				found := false
+				for _, want := range testBidRequestKeys {
+					if string(k) == want {
+						found = true
+						break
+					}
+				}
+				if !found {
+					b.Fatal("not found")
+				}
+			}
+		}
+	})
+}
+
+func ExampleKeyMatcher() {
+	if !SupportedCPU() {
+		// Fake it
+		fmt.Println("width: 800\nheight: 600")
+		return
+	}
+	const (
+		keyWidth = iota
+		keyHeight
+	)
+	m := NewKeyMatcher("Width", "Height")
+
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	i := pj.Iter()
+	elem, err := i.FindElement(nil, "Image")
+	if err != nil {
+		log.Fatal(err)
+	}
+	obj, err := elem.Iter.Object(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var value Iter
+	for {
+		name, t, err := obj.NextElementBytes(&value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if t == TypeNone {
+			break
+		}
+		switch m.Match(name) {
+		case keyWidth:
+			v, _ := value.Int()
+			fmt.Println("width:", v)
+		case keyHeight:
+			v, _ := value.Int()
+			fmt.Println("height:", v)
+		}
+	}
+	// Output:
+	// width: 800
+	// height: 600
+}
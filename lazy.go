@@ -0,0 +1,56 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import "sync"
+
+// LazyParsedJson is a document where only the structural indexes have been found.
+// The tape is built on first access.
+// It is returned by ParseLazy.
+// A LazyParsedJson is safe for concurrent use.
+type LazyParsedJson struct {
+	pj *internalParsedJson
+
+	// Structural indexes found by stage 1.
+	indexes [][indexSize]uint32
+	lengths []int
+
+	once   sync.Once
+	parsed *ParsedJson
+	err    error
+}
+
+// Materialize will build the tape if it hasn't been built yet and return the parsed JSON.
+// Errors found while building the tape are returned here, and
+// will be returned on all subsequent calls.
+func (l *LazyParsedJson) Materialize() (*ParsedJson, error) {
+	l.once.Do(func() {
+		l.parsed, l.err = l.materialize()
+		// Indexes are no longer needed.
+		l.indexes, l.lengths = nil, nil
+	})
+	return l.parsed, l.err
+}
+
+// Iter will build the tape if it hasn't been built yet and return an iterator.
+func (l *LazyParsedJson) Iter() (Iter, error) {
+	pj, err := l.Materialize()
+	if err != nil {
+		return Iter{}, err
+	}
+	return pj.Iter(), nil
+}
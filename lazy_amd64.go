@@ -0,0 +1,111 @@
+//go:build !noasm && !appengine && gc
+// +build !noasm,!appengine,gc
+
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ParseLazy will find the structure of an object or array in b,
+// but defer building the tape until the document is accessed.
+// This saves work when most documents are discarded after inspection.
+//
+// Errors found when finding the structure, like unterminated strings,
+// are returned immediately. Other errors, like invalid values,
+// are returned when calling Materialize or Iter on the returned value.
+// b must not be modified until the document has been materialized,
+// and while the parsed JSON is in use.
+// WithStrictRFC8259 is not supported.
+func ParseLazy(b []byte, opts ...ParserOption) (*LazyParsedJson, error) {
+	pj, err := newInternalParsedJson(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	if pj.strict {
+		return nil, errors.New("strict parsing is not supported by ParseLazy")
+	}
+	if err := pj.checkInputPadding(b); err != nil {
+		return nil, err
+	}
+	pj.Message = bytes.TrimSpace(b)
+	if len(pj.Message) == 0 {
+		return nil, ErrEmptyInput
+	}
+	pj.ndjson = 0
+	pj.indexChans = make(chan indexChan, indexSlots-2)
+	pj.buffersOffset = ^uint64(0)
+
+	// Keep a copy of all indexes.
+	l := &LazyParsedJson{pj: pj}
+	done := make(chan struct{})
+	go l.collectIndexes(done)
+	ok := pj.findStructuralIndices()
+	<-done
+	if !ok {
+		return nil, errors.New("Failed to find all structural indices for stage 1")
+	}
+	return l, nil
+}
+
+// collectIndexes will keep a copy of the indexes sent by stage 1,
+// until the end of stage 1 is sent. done is closed when finished.
+func (l *LazyParsedJson) collectIndexes(done chan<- struct{}) {
+	defer close(done)
+	for idx := range l.pj.indexChans {
+		if idx.index == -1 {
+			return
+		}
+		l.indexes = append(l.indexes, *idx.indexes)
+		l.lengths = append(l.lengths, idx.length)
+	}
+}
+
+// materialize runs stage 2 using the stored indexes.
+func (l *LazyParsedJson) materialize() (*ParsedJson, error) {
+	pj := l.pj
+	pj.initialize(len(pj.Message))
+	pj.stage2Err = nil
+	go func() {
+		for n := range l.indexes {
+			pj.indexChans <- indexChan{length: l.lengths[n], indexes: &l.indexes[n]}
+		}
+		pj.indexChans <- indexChan{index: -1}
+	}()
+	if ok, done := pj.unifiedMachine(); !ok {
+		if !done {
+			for idx := range pj.indexChans {
+				if idx.index == -1 {
+					break
+				}
+			}
+		}
+		return nil, pj.errStage2()
+	}
+	pj.finish()
+	if pj.duplicateKeys == DuplicateKeysReject {
+		if err := checkDuplicateKeys(&pj.ParsedJson); err != nil {
+			return nil, err
+		}
+	}
+	parsed := &pj.ParsedJson
+	parsed.internal = pj
+	return parsed, nil
+}
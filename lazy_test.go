@@ -0,0 +1,119 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestParseLazy(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := loadCompressed(t, tt.name)
+			pj, err := Parse(ref, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			want, err := i.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lazy, err := ParseLazy(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Materialize concurrently.
+			var wg sync.WaitGroup
+			for n := 0; n < 4; n++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					i, err := lazy.Iter()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					got, err := i.MarshalJSON()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if !bytes.Equal(got, want) {
+						t.Error("output mismatch")
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+
+	// Stage 1 errors are returned immediately.
+	if _, err := ParseLazy([]byte(`{"a":"b}`)); err == nil {
+		t.Error("want error for unterminated string")
+	}
+	// Other errors are returned when materializing.
+	lazy, err := ParseLazy([]byte(`{"a":tru}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; n++ {
+		if _, err := lazy.Materialize(); err == nil {
+			t.Error("want error for invalid value")
+		}
+	}
+	lazy, err = ParseLazy([]byte(`{"a":1,"a":2}`), WithDuplicateKeys(DuplicateKeysReject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lazy.Materialize(); err == nil {
+		t.Error("want error for duplicate key")
+	}
+	if _, err := ParseLazy([]byte(`{}`), WithStrictRFC8259(true)); err == nil {
+		t.Error("want error for strict parsing")
+	}
+}
+
+func BenchmarkParseLazy(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	ref := loadCompressed(b, "twitter")
+	b.Run("parse", func(b *testing.B) {
+		b.SetBytes(int64(len(ref)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Parse(ref, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("lazy", func(b *testing.B) {
+		b.SetBytes(int64(len(ref)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := ParseLazy(ref); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
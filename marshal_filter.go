@@ -0,0 +1,225 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import "strconv"
+
+// MarshalJSONFiltered will marshal the current value and append it to dst,
+// omitting object members and array elements for which keep returns false.
+// keep is called with the path of each object member and array element,
+// formatted as in ForEachScalar, and an iterator with the value.
+// Objects and arrays that are omitted are not descended into.
+// If no value is queued, the next value is read.
+// Root values are marshaled as their content, and if more roots follow
+// they are all marshaled separated by newlines.
+// The tape is not modified.
+func (i *Iter) MarshalJSONFiltered(dst []byte, keep func(path string, i Iter) bool) ([]byte, error) {
+	m := filteredMarshaler{keep: keep, opts: defaultMarshalOptions()}
+	return m.marshalRoots(dst, i)
+}
+
+// MarshalJSONReplace will marshal the current value and append it to dst,
+// allowing object members and array elements to be replaced.
+// replace is called with the path of each object member and array element,
+// formatted as in ForEachScalar, and an iterator with the value.
+// If replaced is true newJSON is written instead of the value,
+// and objects and arrays are not descended into.
+// newJSON is not validated, so it must be a valid JSON value.
+// This can for example be used to mask sensitive values in the output.
+// If no value is queued, the next value is read.
+// Root values are handled as in MarshalJSONFiltered.
+// The tape is not modified.
+func (i *Iter) MarshalJSONReplace(dst []byte, replace func(path string, i Iter) (newJSON []byte, replaced bool)) ([]byte, error) {
+	m := filteredMarshaler{replace: replace, opts: defaultMarshalOptions()}
+	return m.marshalRoots(dst, i)
+}
+
+// MarshalJSONOrdered will marshal the object and append it to dst,
+// with the keys in order written first, followed by the remaining keys in document order.
+// Keys in order that are not present in the object are skipped.
+// If a key is repeated in the object, each occurrence in order writes the next occurrence.
+// The object will not be advanced.
+func (o *Object) MarshalJSONOrdered(dst []byte, order []string) ([]byte, error) {
+	obj := *o
+	elems, err := obj.Parse(nil)
+	if err != nil {
+		return nil, err
+	}
+	// Index all occurrences of each key, so repeated keys are written in document order.
+	byName := make(map[string][]int, len(elems.Elements))
+	for n, e := range elems.Elements {
+		byName[e.Name] = append(byName[e.Name], n)
+	}
+	m := filteredMarshaler{opts: defaultMarshalOptions()}
+	written := make([]bool, len(elems.Elements))
+	dst = append(dst, '{')
+	first := true
+	write := func(n int) error {
+		e := &elems.Elements[n]
+		written[n] = true
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(dst, '"')
+		dst = escapeBytes(dst, []byte(e.Name))
+		dst = append(dst, '"', ':')
+		dst, err = m.marshal(dst, &e.Iter)
+		return err
+	}
+	for _, key := range order {
+		idx := byName[key]
+		if len(idx) == 0 {
+			continue
+		}
+		byName[key] = idx[1:]
+		if err := write(idx[0]); err != nil {
+			return nil, err
+		}
+	}
+	for n := range elems.Elements {
+		if written[n] {
+			continue
+		}
+		if err := write(n); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// marshalRoots will marshal the value of i,
+// or all remaining roots separated by newlines if i is at a root.
+func (m *filteredMarshaler) marshalRoots(dst []byte, i *Iter) ([]byte, error) {
+	it := *i
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() != TypeRoot {
+		return m.marshal(dst, &it)
+	}
+	var tmp Iter
+	for n := 0; it.Type() == TypeRoot; n++ {
+		if n > 0 {
+			dst = append(dst, '\n')
+		}
+		_, root, err := it.Root(&tmp)
+		if err != nil {
+			return nil, err
+		}
+		dst, err = m.marshal(dst, root)
+		if err != nil {
+			return nil, err
+		}
+		it.Advance()
+	}
+	return dst, nil
+}
+
+// filteredMarshaler contains the state for MarshalJSONFiltered and MarshalJSONReplace.
+// keep and replace are optional.
+type filteredMarshaler struct {
+	keep    func(path string, i Iter) bool
+	replace func(path string, i Iter) ([]byte, bool)
+	opts    marshalOptions
+	path    []byte
+}
+
+// marshal will append the value of i to dst.
+func (m *filteredMarshaler) marshal(dst []byte, i *Iter) ([]byte, error) {
+	var tmp Iter
+	pathLen := len(m.path)
+	switch i.Type() {
+	case TypeObject:
+		obj, err := i.Object(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, '{')
+		first := true
+		for {
+			name, t, err := obj.NextElementBytes(&tmp)
+			if err != nil {
+				return nil, err
+			}
+			if t == TypeNone {
+				break
+			}
+			m.path = m.appendPath(m.path[:pathLen], name)
+			if m.keep != nil && !m.keep(string(m.path), tmp) {
+				continue
+			}
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			dst = append(dst, '"')
+			dst = escapeBytes(dst, name)
+			dst = append(dst, '"', ':')
+			if dst, err = m.marshalMember(dst, &tmp); err != nil {
+				return nil, err
+			}
+		}
+		m.path = m.path[:pathLen]
+		return append(dst, '}'), nil
+	case TypeArray:
+		arr, err := i.Array(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, '[')
+		first := true
+		tmp = arr.Iter()
+		for n := 0; tmp.Advance() != TypeNone; n++ {
+			m.path = m.appendPath(m.path[:pathLen], nil)
+			m.path = strconv.AppendInt(m.path, int64(n), 10)
+			if m.keep != nil && !m.keep(string(m.path), tmp) {
+				continue
+			}
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			if dst, err = m.marshalMember(dst, &tmp); err != nil {
+				return nil, err
+			}
+		}
+		m.path = m.path[:pathLen]
+		return append(dst, ']'), nil
+	}
+	return i.appendScalar(dst, m.opts)
+}
+
+// marshalMember will append the value of the object member or array element i to dst,
+// or its replacement if the value is replaced.
+// m.path must contain the path of the value.
+func (m *filteredMarshaler) marshalMember(dst []byte, i *Iter) ([]byte, error) {
+	if m.replace != nil {
+		if b, ok := m.replace(string(m.path), *i); ok {
+			return append(dst, b...), nil
+		}
+	}
+	return m.marshal(dst, i)
+}
+
+// appendPath adds a path separator and name to dst.
+func (m *filteredMarshaler) appendPath(dst, name []byte) []byte {
+	if len(dst) > 0 {
+		dst = append(dst, '/')
+	}
+	return append(dst, name...)
+}
@@ -0,0 +1,248 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIter_MarshalJSONFiltered(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"user":{"name":"a","password":"x","tokens":[{"password":"y","id":1},2]},"password":"z","list":[1,"drop",3,"drop"],"f":1.5,"b":true,"n":null}`
+	tests := []struct {
+		name string
+		keep func(path string, i Iter) bool
+		want string
+	}{
+		{
+			name: "all",
+			keep: func(string, Iter) bool { return true },
+			want: input,
+		},
+		{
+			name: "none",
+			keep: func(string, Iter) bool { return false },
+			want: `{}`,
+		},
+		{
+			name: "password",
+			keep: func(path string, i Iter) bool {
+				return path != "password" && !strings.HasSuffix(path, "/password")
+			},
+			want: `{"user":{"name":"a","tokens":[{"id":1},2]},"list":[1,"drop",3,"drop"],"f":1.5,"b":true,"n":null}`,
+		},
+		{
+			name: "values",
+			keep: func(path string, i Iter) bool {
+				s, err := i.String()
+				return err != nil || s != "drop"
+			},
+			want: `{"user":{"name":"a","password":"x","tokens":[{"password":"y","id":1},2]},"password":"z","list":[1,3],"f":1.5,"b":true,"n":null}`,
+		},
+		{
+			name: "first-element",
+			keep: func(path string, i Iter) bool {
+				return !strings.HasSuffix(path, "/0")
+			},
+			want: `{"user":{"name":"a","password":"x","tokens":[2]},"password":"z","list":["drop",3,"drop"],"f":1.5,"b":true,"n":null}`,
+		},
+	}
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := pj.Iter()
+			got, err := i.MarshalJSONFiltered(nil, test.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("want %s, got %s", test.want, got)
+			}
+		})
+	}
+
+	// All roots are written.
+	pj, err = ParseND([]byte(demo_ndjson), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	got, err := i.MarshalJSONFiltered([]byte("prefix:"), func(path string, i Iter) bool {
+		return path != "Image/Thumbnail"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "prefix:" + strings.Replace(demo_ndjson, `"Thumbnail":{"Url":"http://www.example.com/image/481989943","Height":125,"Width":100},`, "", -1)
+	if string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// Lazy numbers are written as they appeared in the input.
+	pj, err = Parse([]byte(`{"a":1.50,"b":1e2,"c":"drop"}`), nil, WithLazyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	got, err = i.MarshalJSONFiltered(nil, func(path string, i Iter) bool {
+		return path != "c"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":1.50,"b":1e2}`; string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestIter_MarshalJSONReplace(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"user":{"name":"a","card":"4111111111111111","tokens":[{"card":"1234"},2]},"card":{"number":"5555"},"list":[1,2,3],"n":null}`
+	tests := []struct {
+		name    string
+		replace func(path string, i Iter) ([]byte, bool)
+		want    string
+	}{
+		{
+			name:    "none",
+			replace: func(string, Iter) ([]byte, bool) { return nil, false },
+			want:    input,
+		},
+		{
+			name: "mask-strings",
+			replace: func(path string, i Iter) ([]byte, bool) {
+				if !strings.HasSuffix(path, "card") || i.Type() != TypeString {
+					return nil, false
+				}
+				return []byte(`"***"`), true
+			},
+			want: `{"user":{"name":"a","card":"***","tokens":[{"card":"***"},2]},"card":{"number":"5555"},"list":[1,2,3],"n":null}`,
+		},
+		{
+			name: "subtree",
+			replace: func(path string, i Iter) ([]byte, bool) {
+				if path == "card" || path == "list" {
+					return []byte(`null`), true
+				}
+				if path == "user/tokens/0" {
+					return []byte(`{}`), true
+				}
+				return nil, false
+			},
+			want: `{"user":{"name":"a","card":"4111111111111111","tokens":[{},2]},"card":null,"list":null,"n":null}`,
+		},
+		{
+			name: "array-elements",
+			replace: func(path string, i Iter) ([]byte, bool) {
+				if strings.HasPrefix(path, "list/") {
+					v, _ := i.Int()
+					return []byte(strings.Repeat("9", int(v))), true
+				}
+				return nil, false
+			},
+			want: `{"user":{"name":"a","card":"4111111111111111","tokens":[{"card":"1234"},2]},"card":{"number":"5555"},"list":[9,99,999],"n":null}`,
+		},
+	}
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := pj.Iter()
+			got, err := i.MarshalJSONReplace(nil, test.replace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("want %s, got %s", test.want, got)
+			}
+		})
+	}
+
+	// All roots are written.
+	pj, err = ParseND([]byte(demo_ndjson), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	got, err := i.MarshalJSONReplace([]byte("prefix:"), func(path string, i Iter) ([]byte, bool) {
+		return []byte(`"hidden"`), path == "Image/Thumbnail/Url"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "prefix:" + strings.Replace(demo_ndjson, `"http://www.example.com/image/481989943"`, `"hidden"`, -1)
+	if string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestObject_MarshalJSONOrdered(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"c":1,"a":{"y":[1,{"z":null}],"x":"s"},"d":"q\"","b":true,"c":2}`
+	tests := []struct {
+		name  string
+		order []string
+		want  string
+	}{
+		{name: "none", want: `{"c":1,"a":{"y":[1,{"z":null}],"x":"s"},"d":"q\"","b":true,"c":2}`},
+		{name: "ordered", order: []string{"a", "b"}, want: `{"a":{"y":[1,{"z":null}],"x":"s"},"b":true,"c":1,"d":"q\"","c":2}`},
+		{name: "missing", order: []string{"missing", "d"}, want: `{"d":"q\"","c":1,"a":{"y":[1,{"z":null}],"x":"s"},"b":true,"c":2}`},
+		{name: "repeated", order: []string{"c", "b", "c", "c"}, want: `{"c":1,"b":true,"c":2,"a":{"y":[1,{"z":null}],"x":"s"},"d":"q\""}`},
+	}
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := pj.Iter()
+			i.Advance()
+			_, root, err := i.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj, err := root.Object(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := obj.MarshalJSONOrdered([]byte("prefix:"), test.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "prefix:"+test.want {
+				t.Errorf("want %s, got %s", test.want, got)
+			}
+			// The object must not be advanced.
+			var tmp Iter
+			if name, _, err := obj.NextElement(&tmp); err != nil || name != "c" {
+				t.Errorf("object was advanced: %q, %v", name, err)
+			}
+		})
+	}
+}
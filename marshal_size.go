@@ -0,0 +1,130 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+// MarshaledSize returns the exact number of bytes MarshalJSON will produce
+// for the current value, without marshaling it.
+// If no value is queued, the next value is read.
+// Root values are measured as their content, and if more roots follow
+// their size is included with a newline separating each.
+// The iterator will not be advanced.
+func (i *Iter) MarshaledSize() (int, error) {
+	it := *i
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() != TypeRoot {
+		return marshaledSize(&it)
+	}
+	var tmp Iter
+	size := 0
+	for n := 0; it.Type() == TypeRoot; n++ {
+		if n > 0 {
+			size++
+		}
+		_, root, err := it.Root(&tmp)
+		if err != nil {
+			return 0, err
+		}
+		s, err := marshaledSize(root)
+		if err != nil {
+			return 0, err
+		}
+		size += s
+		it.Advance()
+	}
+	return size, nil
+}
+
+// marshaledSize returns the marshaled size of the value of i.
+func marshaledSize(i *Iter) (int, error) {
+	var tmp Iter
+	switch i.Type() {
+	case TypeObject:
+		obj, err := i.Object(nil)
+		if err != nil {
+			return 0, err
+		}
+		// Braces
+		size := 2
+		for n := 0; ; n++ {
+			name, t, err := obj.NextElementBytes(&tmp)
+			if err != nil {
+				return 0, err
+			}
+			if t == TypeNone {
+				return size, nil
+			}
+			if n > 0 {
+				size++
+			}
+			// Quotes and colon.
+			size += escapedLen(name) + 3
+			s, err := marshaledSize(&tmp)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+	case TypeArray:
+		arr, err := i.Array(nil)
+		if err != nil {
+			return 0, err
+		}
+		size := 2
+		tmp = arr.Iter()
+		for n := 0; tmp.Advance() != TypeNone; n++ {
+			if n > 0 {
+				size++
+			}
+			s, err := marshaledSize(&tmp)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+		return size, nil
+	case TypeString:
+		// Measure without copying the string.
+		sb, err := i.StringBytes()
+		if err != nil {
+			return 0, err
+		}
+		return escapedLen(sb) + 2, nil
+	}
+	var buf [32]byte
+	b, err := i.appendScalar(buf[:0], defaultMarshalOptions())
+	return len(b), err
+}
+
+// escapedLen returns the length of src when escaped by escapeBytes.
+func escapedLen(src []byte) int {
+	n := len(src)
+	for _, s := range src {
+		if !shouldEscape[s] {
+			continue
+		}
+		switch s {
+		case '\b', '\f', '\n', '\r', '"', '\t', '\\':
+			n++
+		default:
+			// \u00XX
+			n += 5
+		}
+	}
+	return n
+}
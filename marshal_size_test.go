@@ -0,0 +1,94 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"testing"
+)
+
+func TestIter_MarshaledSize(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	check := func(t *testing.T, i Iter) {
+		t.Helper()
+		size, err := i.MarshaledSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != len(out) {
+			t.Errorf("want size %d, got %d", len(out), size)
+		}
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse(loadCompressed(t, tt.name), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, pj.Iter())
+		})
+	}
+	t.Run("ndjson", func(t *testing.T) {
+		pj, err := ParseND([]byte(demo_ndjson), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, pj.Iter())
+	})
+	t.Run("escapes", func(t *testing.T) {
+		pj, err := Parse([]byte(`{"k\u0001\n":["\"\\\/\b\f\r\t\u001fé ",-1.5e-10,1e300,18446744073709551615,-9223372036854775808,true,false,null,{},[]]}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, pj.Iter())
+	})
+	t.Run("lazy-numbers", func(t *testing.T) {
+		const input = `[1.50,1e2,-0.0,2.5E+10,123456789.123456789000,18446744073709551616]`
+		pj, err := Parse([]byte(input), nil, WithLazyNumbers(true), WithBigNumbers(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		check(t, i)
+		size, err := i.MarshaledSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != len(input) {
+			t.Errorf("want size %d, got %d", len(input), size)
+		}
+	})
+	t.Run("value", func(t *testing.T) {
+		pj, err := Parse([]byte(demo_json), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		for _, path := range [][]string{{"Image"}, {"Image", "Thumbnail"}, {"Image", "IDs"}, {"Image", "Title"}} {
+			elem, err := i.FindElement(nil, path...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, elem.Iter)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"fmt"
+	"io"
+)
+
+// GroupNDStream will read all newline delimited JSON records from r
+// and group them by the value at keyPath.
+// The path is resolved using FindElement, and the value is converted to
+// the group key using StringCvt, so numbers are formatted and null becomes "null".
+// Each record is returned as a separate ParsedJson in input order.
+// An error is returned if a record doesn't contain the key,
+// or if the key is an object or array.
+//
+// All records are kept in memory, so callers should make sure the input size is bounded.
+// Use GroupNDStreamFunc to process records without retaining them.
+func GroupNDStream(r io.Reader, keyPath []string, opts ...ParserOption) (map[string][]*ParsedJson, error) {
+	groups := make(map[string][]*ParsedJson)
+	err := groupND(r, keyPath, func(key string, pj *ParsedJson) error {
+		groups[key] = append(groups[key], pj.Clone(nil))
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GroupNDStreamFunc will read newline delimited JSON records from r
+// and call fn with the group key and the content of each record.
+// Keys are read as described in GroupNDStream.
+// Records are parsed one at a time using ForEachNDJSON,
+// so record is only valid until fn returns.
+// If fn returns an error, reading stops and the error is returned.
+func GroupNDStreamFunc(r io.Reader, keyPath []string, fn func(key string, record Iter) error, opts ...ParserOption) error {
+	return groupND(r, keyPath, func(key string, pj *ParsedJson) error {
+		i := pj.Iter()
+		i.Advance()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			return err
+		}
+		return fn(key, *root)
+	}, opts...)
+}
+
+// groupND calls fn with each record in r and its group key.
+func groupND(r io.Reader, keyPath []string, fn func(key string, pj *ParsedJson) error, opts ...ParserOption) error {
+	if len(keyPath) == 0 {
+		return ErrPathNotFound
+	}
+	var elem Element
+	n := 0
+	return ForEachNDJSON(r, func(pj *ParsedJson) error {
+		n++
+		i := pj.Iter()
+		if _, err := i.FindElement(&elem, keyPath...); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if elem.Type == TypeObject || elem.Type == TypeArray {
+			return fmt.Errorf("record %d: cannot group by %v", n, elem.Type)
+		}
+		key, err := elem.Iter.StringCvt()
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		return fn(key, pj)
+	}, opts...)
+}
@@ -0,0 +1,95 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupNDStream(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"user":{"id":"a"},"n":1}
+{"user":{"id":2},"n":2}
+
+{"user":{"id":"a"},"n":3}
+{"user":{"id":null},"n":4}
+{"user":{"id":2},"n":5}
+`
+	groups, err := GroupNDStream(strings.NewReader(input), []string{"user", "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for key, records := range groups {
+		for _, pj := range records {
+			i := pj.Iter()
+			b, err := i.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[key] = append(got[key], string(b))
+		}
+	}
+	want := map[string][]string{
+		"a":    {`{"user":{"id":"a"},"n":1}`, `{"user":{"id":"a"},"n":3}`},
+		"2":    {`{"user":{"id":2},"n":2}`, `{"user":{"id":2},"n":5}`},
+		"null": {`{"user":{"id":null},"n":4}`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// Streaming, summing n per group.
+	sums := make(map[string]int64)
+	err = GroupNDStreamFunc(strings.NewReader(input), []string{"user", "id"}, func(key string, record Iter) error {
+		elem, err := record.FindElement(nil, "n")
+		if err != nil {
+			return err
+		}
+		n, err := elem.Iter.Int()
+		sums[key] += n
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int64{"a": 4, "2": 7, "null": 4}; !reflect.DeepEqual(sums, want) {
+		t.Errorf("want %v, got %v", want, sums)
+	}
+
+	errStop := errors.New("stop")
+	err = GroupNDStreamFunc(strings.NewReader(input), []string{"n"}, func(key string, record Iter) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("want errStop, got %v", err)
+	}
+	if _, err := GroupNDStream(strings.NewReader(input), []string{"user", "missing"}); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("want ErrPathNotFound, got %v", err)
+	}
+	if _, err := GroupNDStream(strings.NewReader(input), []string{"user"}); err == nil {
+		t.Error("want error for object key")
+	}
+	if _, err := GroupNDStream(strings.NewReader("{\"a\":1}\n{"), []string{"a"}); err == nil {
+		t.Error("want parse error")
+	}
+}
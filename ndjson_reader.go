@@ -0,0 +1,67 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// ForEachNDJSON will read newline delimited JSON from r and call fn with each line parsed.
+// Lines are parsed one at a time on the calling goroutine, and the same ParsedJson is
+// reused for all lines, so pj and any values from it are only valid until fn returns.
+// Empty lines are skipped.
+//
+// If a line cannot be parsed a *LineError is returned.
+// If fn returns an error, reading stops and the error is returned.
+// Read errors, except io.EOF, are returned as is.
+//
+// For higher throughput on large inputs, see ParseNDStream.
+func ForEachNDJSON(r io.Reader, fn func(pj *ParsedJson) error, opts ...ParserOption) error {
+	br := bufio.NewReaderSize(r, 64<<10)
+	var line []byte
+	var pj *ParsedJson
+	for n := 1; ; n++ {
+		line = line[:0]
+		var err error
+		for {
+			var b []byte
+			b, err = br.ReadSlice('\n')
+			line = append(line, b...)
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			parsed, perr := Parse(line, pj, opts...)
+			if perr != nil {
+				return &LineError{Line: n, Err: perr}
+			}
+			pj = parsed
+			if ferr := fn(pj); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestForEachNDJSON(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	long := `{"long":"` + strings.Repeat("x", 200<<10) + `"}`
+	input := "\n" + demo_ndjson + "\r\n\n  \n" + long + "\n[1,2]"
+	var want []string
+	for _, line := range strings.Split(input, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			want = append(want, line)
+		}
+	}
+
+	var got []string
+	var last *ParsedJson
+	err := ForEachNDJSON(iotest.HalfReader(strings.NewReader(input)), func(pj *ParsedJson) error {
+		if last != nil && pj != last {
+			t.Error("ParsedJson was not reused")
+		}
+		last = pj
+		i := pj.Iter()
+		b, err := i.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		got = append(got, string(b))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d lines, got %d", len(want), len(got))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("line %d: want %.100s, got %.100s", i, want[i], got[i])
+		}
+	}
+
+	// Parse errors contain the line number.
+	err = ForEachNDJSON(strings.NewReader("{}\n\n{\"a\":}\n{}"), func(pj *ParsedJson) error { return nil })
+	var lErr *LineError
+	if !errors.As(err, &lErr) || lErr.Line != 3 {
+		t.Errorf("want error on line 3, got %v", err)
+	}
+
+	// Callback errors stop reading.
+	errStop := errors.New("stop")
+	calls := 0
+	err = ForEachNDJSON(strings.NewReader(demo_ndjson), func(pj *ParsedJson) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Errorf("want stop after 1 call, got %v after %d", err, calls)
+	}
+
+	// Read errors are returned.
+	errRead := errors.New("read")
+	err = ForEachNDJSON(iotest.ErrReader(errRead), func(pj *ParsedJson) error { return nil })
+	if err != errRead {
+		t.Errorf("want read error, got %v", err)
+	}
+
+	// Options are applied.
+	err = ForEachNDJSON(bytes.NewReader([]byte(`{"a":1,"a":2}`)), func(pj *ParsedJson) error { return nil }, WithDuplicateKeys(DuplicateKeysReject))
+	if err == nil {
+		t.Error("want duplicate key error")
+	}
+}
+
+func BenchmarkForEachNDJSON(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	ndjson := loadFile("testdata/parking-citations.json.zst")
+	b.SetBytes(int64(len(ndjson)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := ForEachNDJSON(bytes.NewReader(ndjson), func(pj *ParsedJson) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
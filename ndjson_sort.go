@@ -0,0 +1,66 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// SortNDStream will read all newline delimited JSON records from r,
+// sort them and write them to w as NDJSON.
+// less is called with two records and must report whether a should be written before b.
+// Each record is supplied with the value queued, so the sort key can be read using
+// for example FindElement.
+// The sort is stable, so records that compare equal keep their input order.
+//
+// All input is read into memory and parsed before any output is written,
+// so memory usage is at least twice the size of the input.
+// Callers should make sure the input size is bounded.
+func SortNDStream(r io.Reader, w io.Writer, less func(a, b Iter) bool, opts ...ParserOption) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	pj, err := ParseND(b, nil, opts...)
+	if err != nil {
+		return err
+	}
+	var records []Iter
+	it := pj.Iter()
+	for it.Advance() == TypeRoot {
+		_, root, err := it.Root(nil)
+		if err != nil {
+			return err
+		}
+		records = append(records, *root)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return less(records[i], records[j])
+	})
+	nw := NewNDJSONWriter(w)
+	for i := range records {
+		if err := nw.WriteIter(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nw.Flush()
+}
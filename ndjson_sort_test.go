@@ -0,0 +1,71 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSortNDStream(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"id":3,"name":"c"}
+{"id":1,"name":"a"}
+
+{"name":"no id"}
+{"id":2,"name":"b1"}
+{"id":2,"name":"b2"}
+`
+	id := func(i Iter) int64 {
+		e, err := i.FindElement(nil, "id")
+		if err != nil {
+			return -1
+		}
+		v, err := e.Iter.Int()
+		if err != nil {
+			return -1
+		}
+		return v
+	}
+	var out bytes.Buffer
+	err := SortNDStream(strings.NewReader(input), &out, func(a, b Iter) bool {
+		return id(a) < id(b)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"name":"no id"}
+{"id":1,"name":"a"}
+{"id":2,"name":"b1"}
+{"id":2,"name":"b2"}
+{"id":3,"name":"c"}
+`
+	if out.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if err := SortNDStream(strings.NewReader("\n \n"), &out, nil); err != nil || out.Len() != 0 {
+		t.Errorf("empty input: got %q, %v", out.String(), err)
+	}
+	if err := SortNDStream(strings.NewReader("{}\n{"), &out, nil); err == nil {
+		t.Error("want parse error")
+	}
+}
@@ -17,6 +17,7 @@
 package simdjson
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -241,7 +242,7 @@ func verifyDemoNdjson(pj internalParsedJson, t *testing.T, object int) {
 		//}
 		//fmt.Printf("{%s, 0x%x},\n", c, tp&0xffffffffffffff)
 		expected := tc.expected[ii].val | (uint64(tc.expected[ii].c) << 56)
-		if !pj.copyStrings && tp != expected {
+		if !pj.copyKeys && !pj.copyValues && tp != expected {
 			t.Errorf("verifyDemoNdjson(%d): got: %016x want: %016x", ii, tp, expected)
 		}
 	}
@@ -526,3 +527,81 @@ func BenchmarkNdjsonWarmCountStarWithWhere(b *testing.B) {
 		}
 	})
 }
+
+func TestNdjsonInternStrings(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	ndjson := loadFile("testdata/parking-citations.json.zst")
+	pj, err := ParseND(ndjson, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interned, err := ParseND(ndjson, nil, WithInternStrings(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("strings: %d -> %d bytes", len(pj.Strings.B), len(interned.Strings.B))
+	if len(interned.Strings.B) >= len(pj.Strings.B)/2 {
+		t.Errorf("want interned strings to be less than half the size, got %d -> %d bytes", len(pj.Strings.B), len(interned.Strings.B))
+	}
+	i, j := pj.Iter(), interned.Iter()
+	want, err := i.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := j.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Error("interned output does not match")
+	}
+
+	// Modifying an interned string must not affect other occurrences.
+	interned, err = ParseND([]byte("{\"a\":\"x\"}\n{\"a\":\"x\"}"), interned, WithInternStrings(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j = interned.Iter()
+	elem, err := j.FindElement(nil, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := elem.Iter.SetString("y"); err != nil {
+		t.Fatal(err)
+	}
+	j = interned.Iter()
+	got, err = j.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":\"y\"}\n{\"a\":\"x\"}"; string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func BenchmarkNdjsonInternStrings(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	ndjson := loadFile("testdata/parking-citations.json.zst")
+	for _, intern := range []bool{false, true} {
+		b.Run(fmt.Sprint("intern=", intern), func(b *testing.B) {
+			pj, err := ParseND(ndjson, nil, WithInternStrings(intern))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(ndjson)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pj, err = ParseND(ndjson, pj, WithInternStrings(intern))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(pj.Strings.B)), "string-bytes")
+		})
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bufio"
+	"io"
+)
+
+// NDJSONWriter writes parsed JSON as newline delimited JSON.
+// Output is buffered, so Flush must be called when done writing.
+// If an error occurs writing, no more data will be accepted and
+// all subsequent writes and Flush will return the error.
+// An NDJSONWriter is not safe for concurrent use.
+type NDJSONWriter struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+// NewNDJSONWriter returns a writer that writes to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: bufio.NewWriter(w)}
+}
+
+// Write will append all documents in pj to the output.
+// Each document is written as compact JSON followed by a newline.
+// If pj was parsed with ParseND, each line will be written.
+func (w *NDJSONWriter) Write(pj *ParsedJson) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(pj.Tape) == 0 {
+		return nil
+	}
+	i := pj.Iter()
+	return w.WriteIter(&i)
+}
+
+// WriteIter will append the remaining scope of the iterator to the output,
+// followed by a newline.
+// The iterator will be advanced to the end of the scope.
+func (w *NDJSONWriter) WriteIter(i *Iter) error {
+	if w.err != nil {
+		return w.err
+	}
+	buf, err := i.MarshalJSONBuffer(w.buf[:0])
+	if err != nil {
+		// Marshal errors are not sticky, since nothing was written.
+		return err
+	}
+	w.buf = append(buf, '\n')
+	_, w.err = w.w.Write(w.buf)
+	return w.err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *NDJSONWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.w.Flush()
+	return w.err
+}
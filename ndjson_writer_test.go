@@ -0,0 +1,103 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failWriter struct{ err error }
+
+func (f failWriter) Write(p []byte) (int, error) {
+	return 0, f.err
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := ParseND([]byte(demo_ndjson), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mutate a value before writing.
+	i := single.Iter()
+	elem, err := i.FindElement(nil, "Image", "Width")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := elem.Iter.SetInt(1024); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	w := NewNDJSONWriter(&out)
+	if err := w.Write(pj); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(single); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Error("output was not buffered")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := demo_ndjson + "\n" + strings.Replace(demo_json, "800", "1024", 1) + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	// The output can be parsed back.
+	if _, err := ParseND(out.Bytes(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes reuse the internal buffer.
+	out.Reset()
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := w.Write(pj); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("want 0 allocations, got %v", allocs)
+	}
+
+	// Errors are returned and kept.
+	errWrite := errors.New("write failed")
+	w = NewNDJSONWriter(failWriter{err: errWrite})
+	for n := 0; n < 1000; n++ {
+		if err = w.Write(pj); err != nil {
+			break
+		}
+	}
+	if err := w.Flush(); err != errWrite {
+		t.Errorf("want %v, got %v", errWrite, err)
+	}
+	if err := w.Write(single); err != errWrite {
+		t.Errorf("want %v, got %v", errWrite, err)
+	}
+}
@@ -1,5 +1,7 @@
 package simdjson
 
+import "fmt"
+
 // ParserOption is a parser option.
 type ParserOption func(pj *internalParsedJson) error
 
@@ -10,9 +12,382 @@ type ParserOption func(pj *internalParsedJson) error
 // strings (not just those transformed anyway for unicode escape characters) into the separate
 // Strings buffer (at the expense of using more memory and less performance).
 // Default: true - strings are copied.
+// Use WithCopyKeys and WithCopyValues to control keys and values separately.
 func WithCopyStrings(b bool) ParserOption {
 	return func(pj *internalParsedJson) error {
-		pj.copyStrings = b
+		pj.copyKeys = b
+		pj.copyValues = b
+		return nil
+	}
+}
+
+// WithCopyKeys will control whether object keys are copied, see WithCopyStrings.
+// Keys that are not copied reference the input, which is safe if keys are only
+// used while the input is unchanged, for example when extracting values.
+// Keys containing escaped characters are always copied.
+// Default: true - keys are copied.
+func WithCopyKeys(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.copyKeys = b
+		return nil
+	}
+}
+
+// WithCopyValues will control whether string values are copied, see WithCopyStrings.
+// String values containing escaped characters are always copied.
+// Default: true - values are copied.
+func WithCopyValues(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.copyValues = b
+		return nil
+	}
+}
+
+// WithPreserveEscapes will record the input location of strings copied to the string buffer,
+// so Iter.Raw can return strings exactly as they appeared in the input,
+// including the original escape sequences.
+// Strings that are not copied can always be returned as they appeared.
+// The input must not be modified while the parsed JSON is in use.
+// Default: false.
+func WithPreserveEscapes(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.preserveEscapes = b
+		return nil
+	}
+}
+
+// WithInternStrings will store identical strings only once in the string buffer.
+// This reduces memory usage for input with many repeated keys or values,
+// for example NDJSON where every line has the same keys.
+// Only strings that are copied to the string buffer are deduplicated,
+// see WithCopyStrings.
+// Interning is disabled when WithPreserveEscapes is used.
+// Default: false.
+func WithInternStrings(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.internStrings = b
+		return nil
+	}
+}
+
+// WithSkipBadLines will make ParseNDStream skip lines that cannot be parsed.
+// Each skipped line is reported on the stream as a *LineError,
+// after which the stream continues with the following lines.
+// Parse and ParseND are not affected by this option.
+// Default: false - the stream is aborted on the first error.
+func WithSkipBadLines(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.skipBadLines = b
+		return nil
+	}
+}
+
+// LineError is returned on a stream when a single line could not be parsed
+// and the stream was set to skip bad lines.
+// It does not terminate the stream.
+type LineError struct {
+	// Line is the 1-based line number in the input stream.
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying parse error.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// WithStrictRFC8259 enables strict RFC 8259 conformance checks.
+// When enabled Parse will accept any value at the top level and
+// the input must be valid UTF-8 with only JSON whitespace around the value.
+// ParseND will validate UTF-8 for all lines, but still requires objects or arrays.
+// Escape sequences are always fully validated.
+// Duplicate keys are allowed by RFC 8259 and can be rejected using WithDuplicateKeys.
+// This option also sets WithValidateUTF8, which can be supplied after it to override UTF-8 validation.
+// Default: false.
+func WithStrictRFC8259(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.strict = b
+		pj.validateUTF8 = b
+		return nil
+	}
+}
+
+// WithValidateUTF8 controls whether Parse and ParseND check that the input is valid UTF-8.
+// When disabled, invalid UTF-8 in strings is accepted and returned unchanged by StringBytes and MarshalJSON,
+// while the structure of the input is still fully validated.
+// WithStrictRFC8259 enables validation, so supply WithValidateUTF8(false) after it
+// to accept invalid UTF-8 with otherwise strict parsing.
+//
+// Validation is not enabled by default, since Parse and ParseND have never validated UTF-8
+// outside strict mode, and enabling it would reject input that is accepted today.
+// Default: false, unless WithStrictRFC8259 is enabled.
+func WithValidateUTF8(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.validateUTF8 = b
+		return nil
+	}
+}
+
+// WithAllowScalarRoot will make Parse accept a single string, number, bool or null as the top level value,
+// as permitted by RFC 8259, without enabling the other checks of WithStrictRFC8259.
+// The root will contain the value, which is returned by Iter.Root.
+// ParseND still requires objects or arrays.
+// Default: false.
+func WithAllowScalarRoot(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.allowScalarRoot = b
+		return nil
+	}
+}
+
+// WithLeadingPlus allows numbers to start with a plus sign, like `+1` or `+1.5e3`.
+// Leading plus signs are not allowed by RFC 8259 and are rejected by default.
+// A plus sign directly following the exponent marker, like `1e+3`, is always allowed.
+// This option has no effect when WithStrictRFC8259 is enabled.
+// Default: false.
+func WithLeadingPlus(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.leadingPlus = b
+		return nil
+	}
+}
+
+// WithSingleQuoteStrings allows strings and object keys to be delimited by single quotes,
+// like `{'key': 'value'}`, as accepted by JSON5.
+// Inside single quoted strings, double quotes do not need to be escaped
+// and single quotes can be escaped as `\'`.
+// Single quoted strings are returned like any other string.
+// Inputs containing single quotes are converted to standard JSON before parsing,
+// so strings and error offsets refer to the converted input, which is kept until the ParsedJson is reused.
+// This option has no effect when WithStrictRFC8259 is enabled.
+// Default: false.
+func WithSingleQuoteStrings(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.singleQuotes = b
+		return nil
+	}
+}
+
+// WithCollectWarnings will collect non-fatal problems found in the input,
+// which can be retrieved with ParsedJson.Warnings after parsing.
+// See WarningCode for the problems that are reported.
+// Parsing will be slower, since the source location of objects and arrays must be recorded
+// and objects are checked for duplicate keys after parsing.
+// Default: false.
+func WithCollectWarnings(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.collectWarnings = b
+		return nil
+	}
+}
+
+// RequiredPadding is the number of bytes that may be read past the end
+// of the input when parsing strings.
+const RequiredPadding = 64
+
+// WithInputPadding promises that the input has at least n bytes of capacity after its length,
+// so cap(b)-len(b) >= n, and that these bytes can safely be read.
+// The content of the padding is never used.
+// When n is at least RequiredPadding strings close to the end of the input
+// can be parsed directly without first copying the remainder of the input to a padded buffer.
+// Parsing will return an error if the input does not have the promised capacity.
+// Default: 0.
+func WithInputPadding(n int) ParserOption {
+	return func(pj *internalParsedJson) error {
+		if n < 0 {
+			return fmt.Errorf("invalid input padding: %d", n)
+		}
+		pj.inputPadding = n
+		return nil
+	}
+}
+
+// WithSingleAllocation will allocate the tape and the string buffer
+// from one backing allocation when they must be (re)allocated.
+// This reduces the number of objects the garbage collector must track per parse,
+// which can be beneficial when parsing many small documents without reusing the output.
+// If either grows beyond the initial estimate during parsing, it is reallocated separately.
+// Default: false.
+func WithSingleAllocation(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.singleAlloc = b
+		return nil
+	}
+}
+
+// WithLazyNumbers will store numbers with a fraction or an exponent as their source text
+// and only convert them to float64 when they are read.
+// This reduces parsing time for documents with many floats that are mostly not read,
+// and marshaling will output the number exactly as it appeared in the input.
+// Integers are still converted while parsing, since they are cheap to convert and
+// their type depends on their value.
+//
+// The syntax of numbers is validated while parsing, but numbers outside the float64 range,
+// like 1e400, are only reported when the value is read.
+// The input must not be modified while the parsed JSON is in use.
+// Default: false.
+func WithLazyNumbers(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.lazyNumbers = b
+		return nil
+	}
+}
+
+// WithBigNumbers will store integers that overflow both int64 and uint64 as their source text,
+// so their exact value can be read with Iter.BigInt, Iter.BigFloat or Iter.NumberBytes,
+// and marshaling will output them exactly as they appeared in the input.
+// Reading them with Iter.Float returns the nearest float64 with FloatOverflowedInteger set, like without this option.
+// Integers outside the float64 range are still rejected.
+// Serializing stores the float64 value.
+// The input must not be modified while the parsed JSON is in use.
+// Default: false.
+func WithBigNumbers(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.bigNumbers = b
+		return nil
+	}
+}
+
+// WithSourceSpans will record the location in the input of all objects and arrays,
+// so their size can be read with Iter.SpanBytes.
+// This adds a small overhead for each object and array.
+// The input must not be modified while the parsed JSON is in use.
+// Default: false.
+func WithSourceSpans(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.sourceSpans = b
+		return nil
+	}
+}
+
+// WithMaxElements will abort parsing with ErrTooManyElements
+// when the tape exceeds n entries.
+// Scalars, object keys and the start and end of objects and arrays use one entry each,
+// except strings and numbers which use two.
+// Each root adds two entries.
+// This limits the memory and time spent on untrusted input, for example documents
+// containing millions of empty objects.
+// The limit applies to all values in NDJSON input.
+// Default: 0, no limit.
+func WithMaxElements(n int) ParserOption {
+	return func(pj *internalParsedJson) error {
+		if n < 0 {
+			return fmt.Errorf("max elements must not be negative: %d", n)
+		}
+		pj.maxElements = n
+		return nil
+	}
+}
+
+// WithRejectEmptyKeys will make parsing fail with an *EmptyError
+// when an object contains a key that is an empty string, like `{"":1}`.
+// Default: false, empty keys are allowed.
+func WithRejectEmptyKeys(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.rejectEmptyKeys = b
+		return nil
+	}
+}
+
+// WithRejectEmptyContainers will make parsing fail with an *EmptyError
+// when an object or array has no elements, like `{}` or `[]`.
+// This includes the top level value.
+// Default: false, empty objects and arrays are allowed.
+func WithRejectEmptyContainers(b bool) ParserOption {
+	return func(pj *internalParsedJson) error {
+		pj.rejectEmptyContainers = b
+		return nil
+	}
+}
+
+// DuplicateKeys is the policy for objects containing duplicate keys.
+type DuplicateKeys uint8
+
+const (
+	// DuplicateKeysAllow will allow duplicate keys in objects.
+	// Lookups will return the first matching key.
+	DuplicateKeysAllow DuplicateKeys = iota
+	// DuplicateKeysReject will return an error if an object contains a duplicate key.
+	DuplicateKeysReject
+)
+
+// WithDuplicateKeys sets the policy for duplicate keys in objects.
+// Rejecting duplicate keys requires an additional pass over the parsed values.
+// Default: DuplicateKeysAllow.
+func WithDuplicateKeys(policy DuplicateKeys) ParserOption {
+	return func(pj *internalParsedJson) error {
+		switch policy {
+		case DuplicateKeysAllow, DuplicateKeysReject:
+		default:
+			return fmt.Errorf("unknown duplicate key policy: %d", policy)
+		}
+		pj.duplicateKeys = policy
+		return nil
+	}
+}
+
+// MarshalOption is an option for marshaling values to JSON.
+type MarshalOption func(o *marshalOptions) error
+
+type marshalOptions struct {
+	integralFloatsAsInt bool
+
+	// largeIntsAsStrings will output integers with a magnitude above largeIntThreshold as strings.
+	largeIntsAsStrings bool
+	largeIntThreshold  uint64
+
+	// indent is set when output is indented with prefix and indent.
+	indent         bool
+	prefix, indStr string
+}
+
+func defaultMarshalOptions() marshalOptions {
+	return marshalOptions{
+		integralFloatsAsInt: true,
+	}
+}
+
+// RenderIntegralFloatsAsInt controls how floats without a fractional part are marshaled.
+// When true a float value of 42 will be output as `42`, when false it will be output as `42.0`.
+// Values that are output with an exponent, like `1e+31`, are not affected.
+// Integer values are never affected.
+// Default: true.
+func RenderIntegralFloatsAsInt(b bool) MarshalOption {
+	return func(o *marshalOptions) error {
+		o.integralFloatsAsInt = b
+		return nil
+	}
+}
+
+// LargeIntsAsStrings will output integer values with a magnitude above threshold as strings.
+// For example with a threshold of 1<<53 the value 9007199254740993 will be output as `"9007199254740993"`.
+// This can be used when output is consumed by JavaScript, where integers above 2^53 lose precision.
+// Floats are not affected.
+// Default: disabled.
+func LargeIntsAsStrings(threshold int64) MarshalOption {
+	return func(o *marshalOptions) error {
+		if threshold < 0 {
+			return fmt.Errorf("large int threshold must not be negative: %d", threshold)
+		}
+		o.largeIntsAsStrings = true
+		o.largeIntThreshold = uint64(threshold)
+		return nil
+	}
+}
+
+// MarshalIndent will output indented JSON like encoding/json.MarshalIndent.
+// Each element in an object or array begins on a new line beginning with prefix
+// followed by one or more copies of indent according to the nesting depth.
+// Empty objects and arrays are output as `{}` and `[]`.
+// Default: disabled.
+func MarshalIndent(prefix, indent string) MarshalOption {
+	return func(o *marshalOptions) error {
+		o.indent = true
+		o.prefix, o.indStr = prefix, indent
 		return nil
 	}
 }
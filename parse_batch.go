@@ -0,0 +1,57 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParseBatch will parse multiple independent documents concurrently.
+// Each document is parsed as with Parse using the supplied options.
+// At most concurrency documents are parsed at the same time.
+// If concurrency is <= 0, GOMAXPROCS is used.
+// The returned slices have the same length as docs, and for each document
+// either the parsed JSON or the error is set.
+func ParseBatch(docs [][]byte, concurrency int, opts ...ParserOption) ([]*ParsedJson, []error) {
+	res := make([]*ParsedJson, len(docs))
+	errs := make([]error, len(docs))
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	if concurrency > len(docs) {
+		concurrency = len(docs)
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(docs) {
+					return
+				}
+				res[i], errs[i] = Parse(docs[i], nil, opts...)
+			}
+		}()
+	}
+	wg.Wait()
+	return res, errs
+}
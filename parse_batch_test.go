@@ -0,0 +1,65 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseBatch(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	var docs [][]byte
+	for i := 0; i < 100; i++ {
+		if i%10 == 3 {
+			docs = append(docs, []byte(`{"invalid":`))
+			continue
+		}
+		docs = append(docs, []byte(fmt.Sprintf(`{"id":%d}`, i)))
+	}
+	for _, conc := range []int{0, 1, 4, 1000} {
+		res, errs := ParseBatch(docs, conc)
+		if len(res) != len(docs) || len(errs) != len(docs) {
+			t.Fatalf("want %d results, got %d, %d", len(docs), len(res), len(errs))
+		}
+		for i := range docs {
+			if i%10 == 3 {
+				if errs[i] == nil || res[i] != nil {
+					t.Errorf("%d: want error, got %v", i, errs[i])
+				}
+				continue
+			}
+			if errs[i] != nil {
+				t.Fatalf("%d: %v", i, errs[i])
+			}
+			it := res[i].Iter()
+			elem, err := it.FindElement(nil, "id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, _ := elem.Iter.Int(); v != int64(i) {
+				t.Errorf("%d: got id %d", i, v)
+			}
+		}
+	}
+	res, errs := ParseBatch(nil, 4)
+	if len(res) != 0 || len(errs) != 0 {
+		t.Errorf("want empty results")
+	}
+}
@@ -22,21 +22,26 @@ package simdjson
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"sync"
+	"unsafe"
 )
 
 func (pj *internalParsedJson) initialize(size int) {
 	// Estimate the tape size to be about 15% of the length of the JSON message
 	avgTapeSize := size * 15 / 100
+	stringsSize := size / 10
+	if stringsSize < 128 {
+		stringsSize = 128 // always allocate at least 128 for the string buffer
+	}
+	if pj.singleAlloc && (cap(pj.Tape) < avgTapeSize || pj.Strings == nil || cap(pj.Strings.B) < stringsSize) {
+		pj.allocArena(avgTapeSize, stringsSize)
+	}
 	if cap(pj.Tape) < avgTapeSize {
 		pj.Tape = make([]uint64, 0, avgTapeSize)
 	}
 	pj.Tape = pj.Tape[:0]
 
-	stringsSize := size / 10
-	if stringsSize < 128 {
-		stringsSize = 128 // always allocate at least 128 for the string buffer
-	}
 	if pj.Strings != nil && cap(pj.Strings.B) >= stringsSize {
 		pj.Strings.B = pj.Strings.B[:0]
 	} else {
@@ -47,12 +52,71 @@ func (pj *internalParsedJson) initialize(size int) {
 	}
 	pj.containingScopeOffset = pj.containingScopeOffset[:0]
 	pj.indexesChan = indexChan{}
+	if pj.preserveEscapes {
+		pj.rawStrings = pj.rawStrings[:0]
+	} else {
+		pj.rawStrings = nil
+	}
+	pj.trackContainers = pj.sourceSpans || pj.collectWarnings
+	if pj.trackContainers {
+		pj.spans = pj.spans[:0]
+		pj.spanStack = pj.spanStack[:0]
+	} else {
+		pj.spans = nil
+	}
+	pj.warnedDepth = false
+	if pj.collectWarnings {
+		pj.warnings = pj.warnings[:0]
+	} else {
+		pj.warnings = nil
+	}
+	pj.messagePadding = 0
+	if pj.inputPadding > 0 && cap(pj.Message)-len(pj.Message) >= pj.inputPadding {
+		pj.messagePadding = pj.inputPadding
+	}
+	pj.intern = nil
+	if pj.internStrings && !pj.preserveEscapes {
+		if pj.internTable == nil {
+			pj.internTable = &internTable{}
+		} else {
+			*pj.internTable = internTable{}
+		}
+		pj.intern = pj.internTable
+	}
+}
+
+// allocArena will allocate the tape and the string buffer from a single allocation.
+// The tape is placed first and is capped, so appending to it cannot overwrite strings.
+func (pj *internalParsedJson) allocArena(tapeSize, stringsSize int) {
+	words := (stringsSize + 7) / 8
+	arena := make([]uint64, tapeSize+words)
+	pj.Tape = arena[:0:tapeSize]
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&arena[tapeSize])), words*8)
+	if pj.Strings == nil {
+		pj.Strings = &TStrings{}
+	}
+	pj.Strings.B = b[:0]
 }
 
-func (pj *internalParsedJson) parseMessage(msg []byte, ndjson bool) (err error) {
+// checkInputPadding returns an error if b does not have the padding promised by WithInputPadding.
+func (pj *internalParsedJson) checkInputPadding(b []byte) error {
+	if avail := cap(b) - len(b); avail < pj.inputPadding {
+		return fmt.Errorf("input padding of %d bytes requested, but input only has %d bytes capacity after end", pj.inputPadding, avail)
+	}
+	return nil
+}
+
+func (pj *internalParsedJson) parseMessage(msg []byte, ndjson bool) error {
+	if pj.singleQuotes && !pj.strict && bytes.IndexByte(msg, '\'') >= 0 {
+		pj.singleQuoted = convertSingleQuotes(pj.singleQuoted[:0], msg)
+		msg = pj.singleQuoted
+	}
 	// Cache message so we can point directly to strings
 	// TODO: Find out why TestVerifyTape/instruments fails without bytes.TrimSpace
 	pj.Message = bytes.TrimSpace(msg)
+	if len(pj.Message) == 0 {
+		return ErrEmptyInput
+	}
 	pj.initialize(len(pj.Message))
 
 	if ndjson {
@@ -68,17 +132,18 @@ func (pj *internalParsedJson) parseMessage(msg []byte, ndjson bool) (err error)
 		pj.indexChans = make(chan indexChan, indexSlots-2)
 	}
 	pj.buffersOffset = ^uint64(0)
-
-	var errStage1 error
+	pj.stage2Err = nil
 
 	// Do long inputs async
 	if len(pj.Message) > 8<<10 {
+		// Declared here, so the closure doesn't cause allocations for short inputs.
+		var errStage1, errStage2 error
 		var wg sync.WaitGroup
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			if ok, done := pj.unifiedMachine(); !ok {
-				err = errors.New("Bad parsing while executing stage 2")
+				errStage2 = pj.errStage2()
 				// Keep consuming...
 				if !done {
 					for idx := range pj.indexChans {
@@ -93,35 +158,114 @@ func (pj *internalParsedJson) parseMessage(msg []byte, ndjson bool) (err error)
 			errStage1 = errors.New("Failed to find all structural indices for stage 1")
 		}
 		wg.Wait()
-	} else {
-		if !pj.findStructuralIndices() {
-			// drain the channel until empty
-			for idx := range pj.indexChans {
-				if idx.index == -1 {
-					break
-				}
+		if errStage1 != nil {
+			return errStage1
+		}
+		if errStage2 == nil {
+			pj.finish()
+		}
+		return errStage2
+	}
+
+	if !pj.findStructuralIndices() {
+		// drain the channel until empty
+		for idx := range pj.indexChans {
+			if idx.index == -1 {
+				break
 			}
-			return errors.New("Failed to find all structural indices for stage 1")
 		}
-		if ok, _ := pj.unifiedMachine(); !ok {
-			// drain the channel until empty
-			for {
-				select {
-				case idx := <-pj.indexChans:
-					if idx.index == -1 {
-						return errors.New("Bad parsing while executing stage 2")
-					}
-					// Already drained.
-				default:
-					return errors.New("Bad parsing while executing stage 2")
+		return errors.New("Failed to find all structural indices for stage 1")
+	}
+	if ok, _ := pj.unifiedMachine(); !ok {
+		// drain the channel until empty
+		for {
+			select {
+			case idx := <-pj.indexChans:
+				if idx.index == -1 {
+					return pj.errStage2()
 				}
+				// Already drained.
+			default:
+				return pj.errStage2()
 			}
 		}
-		return nil
 	}
+	pj.finish()
+	return nil
+}
+
+// finish is called when parsing has succeeded.
+func (pj *internalParsedJson) finish() {
+	if pj.collectWarnings {
+		warnDuplicateKeys(&pj.ParsedJson)
+	}
+	if !pj.sourceSpans {
+		// Only recorded for warnings.
+		pj.spans = nil
+	}
+}
+
+// errStage2 returns the error for a failed stage 2.
+// The position is calculated from the offset recorded by unifiedMachine.
+func (pj *internalParsedJson) errStage2() error {
+	return newParseError(pj.Message, pj.failOffset, "Bad parsing while executing stage 2", pj.stage2Err)
+}
 
-	if errStage1 != nil {
-		return errStage1
+// cancelled returns whether parsing has been cancelled.
+func (pj *internalParsedJson) cancelled() bool {
+	if pj.done == nil {
+		return false
+	}
+	select {
+	case <-pj.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// discardIndexes removes all indexes queued for stage 2.
+func (pj *internalParsedJson) discardIndexes() {
+	for {
+		select {
+		case <-pj.indexChans:
+		default:
+			return
+		}
+	}
+}
+
+// parseMessageStrict will parse a single value with strict RFC 8259 checks.
+// UTF-8 is validated by the caller.
+func (pj *internalParsedJson) parseMessageStrict(msg []byte) error {
+	msg = trimJSONSpace(msg)
+	if len(bytes.TrimSpace(msg)) != len(msg) {
+		return errors.New("invalid whitespace around value")
+	}
+	return pj.parseMessageScalar(msg)
+}
+
+// parseMessageScalar will parse a single value, which may be a scalar.
+// Whitespace around the value must have been removed.
+func (pj *internalParsedJson) parseMessageScalar(msg []byte) error {
+	if len(msg) == 0 || msg[0] == '{' || msg[0] == '[' {
+		return pj.parseMessage(msg, false)
+	}
+	// Parse scalars as a single element array.
+	wrapped := make([]byte, 0, len(msg)+2)
+	wrapped = append(wrapped, '[')
+	wrapped = append(wrapped, msg...)
+	wrapped = append(wrapped, ']')
+	if err := pj.parseMessage(wrapped, false); err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) && perr.Offset > 0 {
+			// Remove the added bracket from the position.
+			perr.Offset--
+			if perr.Line == 1 {
+				perr.Col--
+			}
+		}
+		return err
 	}
-	return
+	return pj.unwrapScalarRoot()
 }
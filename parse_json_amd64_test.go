@@ -347,9 +347,9 @@ type atofTest struct {
 }
 
 var atoftests = []atofTest{
-	{"", "0", strconv.ErrSyntax}, /* fails for simdjson */
-	{"1", "1", nil},              /* parsed as int for simdjson */
-	{"+1", "1", nil},             /* parsed as int for simdjson */
+	{"", "0", strconv.ErrSyntax},            /* fails for simdjson */
+	{"1", "1", nil},                         /* parsed as int for simdjson */
+	{"+1", "1", errors.New("invalid json")}, /* leading plus not allowed */
 
 	{"1x", "0", strconv.ErrSyntax},
 	{"1.1.", "0", strconv.ErrSyntax},
@@ -370,9 +370,9 @@ var atoftests = []atofTest{
 	{"625e-3", "0.625", nil},
 
 	// zeros (several test cases for zero have been moved up because they are detected as ints)
-	{"+0e0", "0", nil},
-	{"+0e-0", "0", nil},
-	{"+0e+0", "0", nil},
+	{"+0e0", "0", errors.New("invalid json")}, /* leading plus not allowed */
+	{"+0e-0", "0", errors.New("invalid json")},
+	{"+0e+0", "0", errors.New("invalid json")},
 	{"0e+01234567890123456789", "0", nil},
 	{"0.00e-01234567890123456789", "0", nil},
 	{"-0e+01234567890123456789", "-0", nil},
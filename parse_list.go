@@ -0,0 +1,83 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+)
+
+// ParseList will parse a list of records encoded either as a single JSON array
+// or as newline delimited JSON, and call fn with each record.
+//
+// If the first non-whitespace character is '[' the input is first parsed as a single value,
+// and if that succeeds fn is called with each element of the array.
+// Otherwise, or if the input contains more than one value, it is parsed with ParseND
+// and fn is called with each line.
+// This means a single line of NDJSON containing an array is treated as a list of its elements,
+// while multiple lines of arrays are returned as one record per line.
+// If input starting with '[' cannot be parsed either way, the error from parsing it as a single value is returned.
+// Empty input contains no records and fn is not called.
+//
+// Records are only valid until ParseList returns.
+// If fn returns an error, iteration stops and the error is returned.
+func ParseList(data []byte, fn func(i Iter) error, opts ...ParserOption) error {
+	trimmed := trimJSONSpace(data)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	var firstErr error
+	if trimmed[0] == '[' {
+		pj, err := Parse(data, nil, opts...)
+		if err == nil {
+			return forEachElement(pj, fn)
+		}
+		firstErr = err
+	}
+	pj, err := ParseND(data, nil, opts...)
+	if err != nil {
+		if firstErr != nil {
+			return firstErr
+		}
+		return err
+	}
+	return pj.ForEach(fn)
+}
+
+// forEachElement calls fn with each element of the array in the single root of pj.
+func forEachElement(pj *ParsedJson, fn func(i Iter) error) error {
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		return err
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		return errors.New("list is not an array")
+	}
+	elems := arr.Iter()
+	var elem Iter
+	for {
+		t, err := elems.AdvanceIter(&elem)
+		if err != nil || t == TypeNone {
+			return err
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "array", input: ` [{"a":1}, {"a":2},` + "\n" + `{"a":3}] `, want: []string{`{"a":1}`, `{"a":2}`, `{"a":3}`}},
+		{name: "ndjson", input: "{\"a\":1}\n{\"a\":2}\n\n{\"a\":3}\n", want: []string{`{"a":1}`, `{"a":2}`, `{"a":3}`}},
+		{name: "single-object", input: `{"a":1}`, want: []string{`{"a":1}`}},
+		{name: "mixed-array", input: `[1,"b",[2],null]`, want: []string{`1`, `"b"`, `[2]`, `null`}},
+		{name: "ndjson-arrays", input: "[1,2]\n[3]\n", want: []string{`[1,2]`, `[3]`}},
+		{name: "empty-array", input: `[]`},
+		{name: "empty", input: " \n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			err := ParseList([]byte(test.input), func(i Iter) error {
+				b, err := i.MarshalJSON()
+				got = append(got, string(b))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want %v, got %v", test.want, got)
+			}
+		})
+	}
+
+	for _, input := range []string{`[1,2`, "{\"a\":1}\n{\"a\":", `[1]x`} {
+		if err := ParseList([]byte(input), func(i Iter) error { return nil }); err == nil {
+			t.Errorf("%s: want error", input)
+		}
+	}
+
+	// Errors from the callback stop iteration.
+	errStop := errors.New("stop")
+	for _, input := range []string{`[1,2,3]`, "[1]\n[2]\n[3]"} {
+		n := 0
+		err := ParseList([]byte(input), func(i Iter) error {
+			n++
+			return errStop
+		})
+		if err != errStop || n != 1 {
+			t.Errorf("%s: want stop after 1, got %d (err: %v)", input, n, err)
+		}
+	}
+}
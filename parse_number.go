@@ -17,7 +17,9 @@
 package simdjson
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"math"
 	"reflect"
 	"strconv"
@@ -31,6 +33,7 @@ const (
 	isEOVFlag
 	isDigitFlag
 	isMustHaveDigitNext
+	isPlusFlag
 )
 
 var isNumberRune = [256]uint8{
@@ -45,7 +48,7 @@ var isNumberRune = [256]uint8{
 	'8':  isPartOfNumberFlag | isDigitFlag,
 	'9':  isPartOfNumberFlag | isDigitFlag,
 	'.':  isPartOfNumberFlag | isFloatOnlyFlag | isMustHaveDigitNext,
-	'+':  isPartOfNumberFlag,
+	'+':  isPartOfNumberFlag | isPlusFlag | isMustHaveDigitNext,
 	'-':  isPartOfNumberFlag | isMinusFlag | isMustHaveDigitNext,
 	'e':  isPartOfNumberFlag | isFloatOnlyFlag,
 	'E':  isPartOfNumberFlag | isFloatOnlyFlag,
@@ -75,10 +78,14 @@ func parseNumber(buf []byte) (id, val uint64) {
 			break
 		}
 		if t&isMustHaveDigitNext > 0 {
-			// A period and minus must be followed by a digit
+			// A period, minus and plus must be followed by a digit
 			if len(buf) < i+2 || isNumberRune[buf[i+1]]&isDigitFlag == 0 {
 				return 0, 0
 			}
+			// A plus is only allowed directly after the exponent marker.
+			if t&isPlusFlag != 0 && (i == 0 || buf[i-1]|0x20 != 'e') {
+				return 0, 0
+			}
 		}
 		found |= t
 		pos = i + 1
@@ -134,6 +141,154 @@ func parseNumber(buf []byte) (id, val uint64) {
 	return 0, 0
 }
 
+// lazyFloatLen returns the length of the float at the start of buf,
+// if it can be stored without conversion by WithLazyNumbers.
+// The number is validated according to the JSON grammar and must be followed
+// by a separator or the end of buf.
+// 0 is returned for integers, invalid numbers and numbers that could be out of range,
+// which must be converted by parseNumber.
+func lazyFloatLen(buf []byte) int {
+	// Numbers with at most maxLen characters and a two digit exponent are within float64 range.
+	const maxLen = 100
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	i := 0
+	if i < len(buf) && buf[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(buf) && buf[i] == '0':
+		i++
+	case i < len(buf) && isDigit(buf[i]):
+		for i < len(buf) && isDigit(buf[i]) {
+			i++
+		}
+	default:
+		return 0
+	}
+	isFloat := false
+	if i < len(buf) && buf[i] == '.' {
+		i++
+		start := i
+		for i < len(buf) && isDigit(buf[i]) {
+			i++
+		}
+		if i == start {
+			return 0
+		}
+		isFloat = true
+	}
+	if i < len(buf) && buf[i]|0x20 == 'e' {
+		i++
+		if i < len(buf) && (buf[i] == '+' || buf[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(buf) && isDigit(buf[i]) {
+			i++
+		}
+		if i == start || i-start > 2 {
+			return 0
+		}
+		isFloat = true
+	}
+	if !isFloat || i > maxLen || (i < len(buf) && isNumberRune[buf[i]] != isEOVFlag) {
+		return 0
+	}
+	return i
+}
+
+// NumberErrorCode describes why a number could not be parsed.
+type NumberErrorCode uint8
+
+const (
+	// NumberErrSyntax is returned for numbers that are malformed in other ways,
+	// for example a missing digit after a period or minus.
+	NumberErrSyntax NumberErrorCode = iota
+	// NumberErrLeadingZero is returned for numbers with a leading zero, like `013`.
+	NumberErrLeadingZero
+	// NumberErrExponent is returned for numbers with an invalid exponent, like `1e` or `1e+-2`.
+	NumberErrExponent
+	// NumberErrRange is returned for numbers that cannot be represented as a float64, like `1e400`.
+	NumberErrRange
+	// NumberErrTrailing is returned for numbers followed by invalid characters, like `12x`.
+	NumberErrTrailing
+)
+
+func (c NumberErrorCode) String() string {
+	switch c {
+	case NumberErrSyntax:
+		return "invalid syntax"
+	case NumberErrLeadingZero:
+		return "leading zero"
+	case NumberErrExponent:
+		return "invalid exponent"
+	case NumberErrRange:
+		return "value out of range"
+	case NumberErrTrailing:
+		return "invalid trailing characters"
+	}
+	return "(invalid)"
+}
+
+// NumberError is returned when a number in the input cannot be parsed.
+type NumberError struct {
+	// Number contains the number as it appeared in the input,
+	// including any trailing invalid characters.
+	Number string
+	Code   NumberErrorCode
+}
+
+func (e *NumberError) Error() string {
+	return fmt.Sprintf("invalid number %q: %v", e.Number, e.Code)
+}
+
+// numberError returns why the number starting in buf could not be parsed.
+// It should only be called when parseNumber failed.
+func numberError(buf []byte) *NumberError {
+	n := 0
+	for n < len(buf) && isNumberRune[buf[n]]&isPartOfNumberFlag != 0 {
+		n++
+	}
+	tok := buf[:n]
+	if n < len(buf) && isNumberRune[buf[n]] == 0 {
+		// Include the invalid characters up to the end of the value.
+		end := n
+		for end < len(buf) && isNumberRune[buf[end]]&isEOVFlag == 0 && end-n < 32 {
+			end++
+		}
+		return &NumberError{Number: string(buf[:end]), Code: NumberErrTrailing}
+	}
+	e := &NumberError{Number: string(tok), Code: NumberErrSyntax}
+	mantissa, exp := tok, []byte(nil)
+	if i := bytes.IndexAny(tok, "eE"); i >= 0 {
+		mantissa, exp = tok[:i], tok[i+1:]
+		if len(exp) > 0 && (exp[0] == '+' || exp[0] == '-') {
+			exp = exp[1:]
+		}
+		if len(exp) == 0 {
+			e.Code = NumberErrExponent
+			return e
+		}
+		for _, c := range exp {
+			if isNumberRune[c]&isDigitFlag == 0 {
+				e.Code = NumberErrExponent
+				return e
+			}
+		}
+	}
+	if len(mantissa) > 0 && (mantissa[0] == '-' || mantissa[0] == '+') {
+		mantissa = mantissa[1:]
+	}
+	if len(mantissa) > 1 && mantissa[0] == '0' && isNumberRune[mantissa[1]]&isDigitFlag != 0 {
+		e.Code = NumberErrLeadingZero
+		return e
+	}
+	if _, err := strconv.ParseFloat(string(tok), 64); errors.Is(err, strconv.ErrRange) {
+		e.Code = NumberErrRange
+	}
+	return e
+}
+
 // unsafeBytesToString should only be used when we have control of b.
 func unsafeBytesToString(b []byte) (s string) {
 	var length = len(b)
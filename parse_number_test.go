@@ -17,6 +17,7 @@
 package simdjson
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -115,6 +116,16 @@ func TestNumberIsValid(t *testing.T) {
 		"1.",
 		"01",
 		"1.e1",
+		"+1",
+		"+0",
+		"+1.5",
+		"+",
+		"-+1",
+		"+-1",
+		"1+2",
+		"1.5+2",
+		"1e++2",
+		"1+e2",
 	}
 
 	for _, test := range invalidTests {
@@ -128,6 +139,98 @@ func TestNumberIsValid(t *testing.T) {
 	}
 }
 
+func TestNumberError(t *testing.T) {
+	tests := []struct {
+		in   string
+		want NumberErrorCode
+		num  string
+	}{
+		{in: "013,", want: NumberErrLeadingZero, num: "013"},
+		{in: "-04]", want: NumberErrLeadingZero, num: "-04"},
+		{in: "00.5}", want: NumberErrLeadingZero, num: "00.5"},
+		{in: "1e", want: NumberErrExponent, num: "1e"},
+		{in: "1E+,", want: NumberErrExponent, num: "1E+"},
+		{in: "1e+-2", want: NumberErrExponent, num: "1e+-2"},
+		{in: "2e5.5", want: NumberErrExponent, num: "2e5.5"},
+		{in: "1e400", want: NumberErrRange, num: "1e400"},
+		{in: "-1e400", want: NumberErrRange, num: "-1e400"},
+		{in: "12x, 1", want: NumberErrTrailing, num: "12x"},
+		{in: "1.5abc}", want: NumberErrTrailing, num: "1.5abc"},
+		{in: "-", want: NumberErrSyntax, num: "-"},
+		{in: "1.", want: NumberErrSyntax, num: "1."},
+		{in: "1.2.3", want: NumberErrSyntax, num: "1.2.3"},
+	}
+	for _, test := range tests {
+		if tag, _ := parseNumber([]byte(test.in)); tag != 0 {
+			t.Errorf("%s: want parse error", test.in)
+			continue
+		}
+		err := numberError([]byte(test.in))
+		if err.Code != test.want || err.Number != test.num {
+			t.Errorf("%s: want %v for %q, got %v for %q", test.in, test.want, test.num, err.Code, err.Number)
+		}
+	}
+}
+
+func TestParseLeadingPlus(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		in      string
+		lenient string // Expected output with WithLeadingPlus(true). Empty means error.
+	}{
+		{in: `[+1]`, lenient: `[1]`},
+		{in: `{"a":+1.5}`, lenient: `{"a":1.5}`},
+		{in: `[+0,+2e+2]`, lenient: `[0,200]`},
+		{in: `[+18446744073709551615]`, lenient: `[18446744073709551615]`},
+		{in: `[+]`},
+		{in: `[+-1]`},
+		{in: `[++1]`},
+		{in: `[+01]`},
+		{in: `[+.5]`},
+		{in: `[+ 1]`},
+	}
+	for _, test := range tests {
+		// Rejected by default and in strict mode.
+		if _, err := Parse([]byte(test.in), nil); err == nil {
+			t.Errorf("%s: want error by default", test.in)
+		}
+		if _, err := Parse([]byte(test.in), nil, WithLeadingPlus(true), WithStrictRFC8259(true)); err == nil {
+			t.Errorf("%s: want error in strict mode", test.in)
+		}
+		pj, err := Parse([]byte(test.in), nil, WithLeadingPlus(true))
+		if test.lenient == "" {
+			if err == nil {
+				t.Errorf("%s: want error with leading plus allowed", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.in, err)
+			continue
+		}
+		i := pj.Iter()
+		got, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.lenient {
+			t.Errorf("%s: want %s, got %s", test.in, test.lenient, got)
+		}
+	}
+
+	// The exponent may always have a plus sign.
+	if _, err := Parse([]byte(`[1e+2,-1.5E+2]`), nil, WithStrictRFC8259(true)); err != nil {
+		t.Error(err)
+	}
+	_, err := Parse([]byte(`[+01]`), nil, WithLeadingPlus(true))
+	var nErr *NumberError
+	if !errors.As(err, &nErr) || nErr.Code != NumberErrLeadingZero || nErr.Number != "+01" {
+		t.Errorf("want leading zero error, got %v", err)
+	}
+}
+
 func closeEnough(d1, d2 float64) (ce bool) {
 	return math.Abs((d1-d2)/(0.5*(d1+d2))) < 1e-20
 }
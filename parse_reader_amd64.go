@@ -0,0 +1,156 @@
+//go:build !noasm && !appengine && gc
+// +build !noasm,!appengine,gc
+
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// parseReadSize is the number of bytes ParseReader grows its buffer by,
+// and the number of bytes collected before stage 1 is run.
+const parseReadSize = 32 << 10
+
+// jsonSpace contains the whitespace trimmed by ParseReader.
+const jsonSpace = " \t\n\v\f\r"
+
+// ParseReader will read r until io.EOF and parse the content as a single object or array like Parse.
+//
+// Stage 1 is run on the input while it is read, carrying its state across blocks,
+// so structural indexes have been found when reading completes.
+// The tape is then built by stage 2.
+// The input is read into a buffer that is owned by the returned ParsedJson
+// and is reused when reuse is supplied, so only one copy of the input is kept.
+// Until stage 2 has run, the indexes use 4 bytes for each structural character.
+// When the size of r is known, because r has a Len method like bytes.Reader
+// or is an *os.File, the buffer is allocated with the final size before reading.
+//
+// Strict parsing, scalar roots and single quoted strings are not supported.
+// Use WithCopyStrings(false) to also avoid keeping a copy of the strings.
+// See Parse for details on parsing and reuse.
+func ParseReader(r io.Reader, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	var buf []byte
+	if reuse != nil && reuse.internal != nil {
+		buf = reuse.internal.transcoded[:0]
+	}
+	pj, err := newInternalParsedJson(reuse, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case pj.strict:
+		return nil, errors.New("strict parsing is not supported by ParseReader")
+	case pj.allowScalarRoot:
+		return nil, errors.New("scalar roots are not supported by ParseReader")
+	case pj.singleQuotes:
+		return nil, errors.New("single quoted strings are not supported by ParseReader")
+	}
+	pj.inputPadding = RequiredPadding
+	pj.ndjson = 0
+	if pj.indexChans == nil {
+		pj.indexChans = make(chan indexChan, indexSlots-2)
+	}
+	pj.buffersOffset = ^uint64(0)
+
+	const minRead = 512
+	if size := readerSize(r); size >= 0 && cap(buf) < size+minRead+RequiredPadding {
+		// Leave room for reading io.EOF without growing.
+		buf = make([]byte, 0, size+minRead+RequiredPadding)
+	}
+
+	// Keep a copy of all indexes.
+	l := &LazyParsedJson{pj: pj}
+	done := make(chan struct{})
+	go l.collectIndexes(done)
+	stop := func() {
+		pj.indexChans <- indexChan{index: -1}
+		<-done
+	}
+
+	s := newStage1(0)
+	// start is the offset of the first non-whitespace byte, or -1.
+	// end is the offset after the last non-whitespace byte.
+	// Input before scanned has been processed by stage 1.
+	start, end, scanned := -1, 0, 0
+	for {
+		if cap(buf)-len(buf) < minRead+RequiredPadding {
+			buf = append(buf[:cap(buf)], make([]byte, parseReadSize+RequiredPadding)...)[:len(buf)]
+		}
+		n, err := r.Read(buf[len(buf) : cap(buf)-RequiredPadding])
+		if read := buf[len(buf) : len(buf)+n]; len(bytes.TrimLeft(read, jsonSpace)) > 0 {
+			if start < 0 {
+				start = len(buf) + n - len(bytes.TrimLeft(read, jsonSpace))
+				scanned = start
+			}
+			end = len(buf) + len(bytes.TrimRight(read, jsonSpace))
+		}
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		// Stop stage 1 before trailing whitespace, so the final call can check the end of the input.
+		if start >= 0 && end-scanned >= parseReadSize {
+			processed, ok := pj.findIndexes(&s, buf[scanned:end], false)
+			scanned += processed
+			if !ok {
+				stop()
+				return nil, errors.New("Failed to find all structural indices for stage 1")
+			}
+		}
+	}
+	if start < 0 {
+		stop()
+		return nil, ErrEmptyInput
+	}
+	_, ok := pj.findIndexes(&s, buf[scanned:end], true)
+	stop()
+	if !ok || s.indexTotal == 0 {
+		return nil, errors.New("Failed to find all structural indices for stage 1")
+	}
+
+	pj.Message = buf[start:end]
+	pj.transcoded = buf
+	if pj.validateUTF8 && !utf8.Valid(pj.Message) {
+		return nil, errInvalidUTF8
+	}
+	return l.materialize()
+}
+
+// readerSize returns the number of bytes left in r, or -1 if unknown.
+func readerSize(r io.Reader) int {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return v.Len()
+	case *os.File:
+		if st, err := v.Stat(); err == nil && st.Mode().IsRegular() {
+			if off, err := v.Seek(0, io.SeekCurrent); err == nil && off <= st.Size() {
+				return int(st.Size() - off)
+			}
+		}
+	}
+	return -1
+}
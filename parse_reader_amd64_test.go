@@ -0,0 +1,160 @@
+//go:build !noasm && !appengine && gc
+// +build !noasm,!appengine,gc
+
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseReader(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := loadCompressed(t, "twitter")
+	want, err := Parse(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIter := want.Iter()
+	wantJSON, err := wantIter.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "twitter.json")
+	if err := os.WriteFile(path, input, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var reuse *ParsedJson
+	check := func(t *testing.T, pj *ParsedJson) {
+		t.Helper()
+		i := pj.Iter()
+		got, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, wantJSON) {
+			t.Error("output mismatch")
+		}
+		if n := cap(pj.Message) - len(pj.Message); n < RequiredPadding {
+			t.Errorf("want at least %d bytes padding, got %d", RequiredPadding, n)
+		}
+		reuse = pj
+	}
+	t.Run("sized", func(t *testing.T) {
+		pj, err := ParseReader(bytes.NewReader(input), reuse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cap(pj.internal.transcoded) > len(input)+1024 {
+			t.Errorf("buffer grew while reading: cap %d, input %d", cap(pj.internal.transcoded), len(input))
+		}
+		check(t, pj)
+	})
+	t.Run("file", func(t *testing.T) {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		pj, err := ParseReader(f, reuse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, pj)
+	})
+	t.Run("unsized", func(t *testing.T) {
+		pj, err := ParseReader(iotest.HalfReader(bytes.NewReader(input)), nil, WithCopyStrings(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, pj)
+	})
+	t.Run("read-error", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		if _, err := ParseReader(iotest.ErrReader(errRead), nil); !errors.Is(err, errRead) {
+			t.Errorf("want read error, got %v", err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ParseReader(bytes.NewReader([]byte(`{"a":}`)), nil); err == nil {
+			t.Error("want error")
+		}
+	})
+}
+
+func TestParseReaderParse(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	check := func(t *testing.T, in []byte) {
+		t.Helper()
+		want, wantErr := Parse(in, nil)
+		got, gotErr := ParseReader(iotest.HalfReader(bytes.NewReader(in)), nil)
+		if (gotErr == nil) != (wantErr == nil) {
+			if len(in) > 100 {
+				in = in[:100]
+			}
+			t.Fatalf("%q: want error %v, got %v", in, wantErr, gotErr)
+		}
+		if wantErr != nil {
+			return
+		}
+		if !reflect.DeepEqual(got.Tape, want.Tape) || !bytes.Equal(got.Strings.B, want.Strings.B) {
+			t.Error("tape mismatch")
+		}
+	}
+	rng := rand.New(rand.NewSource(0))
+	const chars = "{}[]:,\" \\0123456789\x01\n"
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := loadCompressed(t, tt.name)
+			check(t, ref)
+			// Trailing whitespace spanning blocks.
+			check(t, append(append([]byte{}, ref...), bytes.Repeat([]byte(" \n"), parseReadSize)...))
+			// Change single bytes, which may span blocks.
+			for i := 0; i < 10; i++ {
+				b := append([]byte{}, ref...)
+				b[rng.Intn(len(b))] = chars[rng.Intn(len(chars))]
+				check(t, b)
+				check(t, b[:rng.Intn(len(b))])
+			}
+		})
+	}
+	long := `"` + strings.Repeat("a\\n", parseReadSize) + `"`
+	for _, in := range []string{`[1,2,]`, `{"a":}`, `  [1]  `, `[] x`, `["a"b]`, `{}`, `[` + long + `]`, `[` + long + `,` + long[1:],
+		strings.Repeat(" ", 3*parseReadSize) + `{"a":` + long + `}` + strings.Repeat("\t", parseReadSize+1)} {
+		name := in
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		t.Run(name, func(t *testing.T) {
+			check(t, []byte(in))
+		})
+	}
+}
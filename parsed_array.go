@@ -25,6 +25,7 @@ import (
 // Array represents a JSON array.
 // There are methods that allows to get full arrays if the value type is the same.
 // Otherwise an iterator can be retrieved.
+// Elements are always returned in index order.
 type Array struct {
 	tape ParsedJson
 	off  int
@@ -42,6 +43,19 @@ func (a *Array) Iter() Iter {
 	return i
 }
 
+// Len returns the number of elements in the array.
+// Only the top level of the array is visited, nested objects and arrays
+// are skipped without reading their content.
+// Deleted elements are not counted.
+func (a *Array) Len() int {
+	i := a.Iter()
+	n := 0
+	for i.Advance() != TypeNone {
+		n++
+	}
+	return n
+}
+
 // ForEach calls the provided function for every element.
 func (a *Array) ForEach(fn func(i Iter)) {
 	i := a.Iter()
@@ -77,6 +91,84 @@ func (a *Array) DeleteElems(fn func(i Iter) bool) {
 	return
 }
 
+// ReplaceAt will replace the element at index with the value of value.
+// value can be from another ParsedJson. If value is a root, its content is used.
+// The new value must fit within the tape entries of the existing element,
+// otherwise ErrReplaceTooLarge is returned and the array is unchanged.
+// Unused entries are filled with nops, like SetNull does for objects and arrays.
+// Strings are copied to the string buffer of the array.
+func (a *Array) ReplaceAt(index int, value *Iter) error {
+	if index < 0 {
+		return fmt.Errorf("index %d out of range", index)
+	}
+	i := a.Iter()
+	for n := 0; n <= index; n++ {
+		if i.Advance() == TypeNone {
+			return fmt.Errorf("index %d out of range", index)
+		}
+	}
+	start, end, err := i.valueSpan()
+	if err != nil {
+		return err
+	}
+	return i.tape.replaceValue(start, end, value)
+}
+
+// Append will add the JSON value in raw as the last element of the array.
+// The tape of the ParsedJson the array was created from is reallocated,
+// so only a and the ParsedJson are updated and other iterators, objects and arrays
+// from the same ParsedJson must be recreated before they are used.
+func (a *Array) Append(raw []byte) error {
+	tmp, err := encodeRawJSON(raw)
+	if err != nil {
+		return err
+	}
+	arrEnd := len(a.tape.Tape) - 1
+	if arrEnd < a.off || Tag(a.tape.Tape[arrEnd]>>JSONTAGOFFSET) != TagArrayEnd {
+		return errors.New("corrupt input: array end not found")
+	}
+	_, err = a.tape.spliceValue(arrEnd, arrEnd, tmp)
+	return err
+}
+
+// Index sets dst to the element at index n and returns its type.
+// The first element has index 0.
+// Nested objects and arrays before the element are skipped without reading their content.
+// If n is out of range TypeNone and an error is returned.
+// The array is not modified, so Index can be called repeatedly.
+func (a *Array) Index(n int, dst *Iter) (Type, error) {
+	if dst == nil {
+		return TypeNone, errors.New("Index: nil destination")
+	}
+	t, err := a.elementAt(dst, n)
+	if err == ErrPathNotFound {
+		return TypeNone, fmt.Errorf("index %d out of range", n)
+	}
+	return t, err
+}
+
+// elementAt sets dst to the array element at idx.
+// ErrPathNotFound is returned if idx is out of range.
+func (a *Array) elementAt(dst *Iter, idx int) (Type, error) {
+	if idx < 0 {
+		return TypeNone, ErrPathNotFound
+	}
+	it := a.Iter()
+	for ; idx > 0; idx-- {
+		if it.Advance() == TypeNone {
+			return TypeNone, ErrPathNotFound
+		}
+	}
+	t, err := it.AdvanceIter(dst)
+	if err != nil {
+		return TypeNone, err
+	}
+	if t == TypeNone {
+		return TypeNone, ErrPathNotFound
+	}
+	return t, nil
+}
+
 // FirstType will return the type of the first element.
 // If there are no elements, TypeNone is returned.
 func (a *Array) FirstType() Type {
@@ -159,7 +251,11 @@ readArray:
 			if len(a.tape.Tape) <= a.off {
 				return nil, errors.New("corrupt input: expected float, but no more values")
 			}
-			dst = append(dst, math.Float64frombits(a.tape.Tape[a.off]))
+			val, _, err := a.tape.floatAt(a.tape.Tape[a.off-1]&JSONVALUEMASK, a.tape.Tape[a.off])
+			if err != nil {
+				return nil, err
+			}
+			dst = append(dst, val)
 		case TagInteger:
 			if len(a.tape.Tape) <= a.off {
 				return nil, errors.New("corrupt input: expected integer, but no more values")
@@ -198,7 +294,10 @@ readArray:
 			if len(a.tape.Tape) <= a.off {
 				return nil, errors.New("corrupt input: expected float, but no more values")
 			}
-			val := math.Float64frombits(a.tape.Tape[a.off])
+			val, _, err := a.tape.floatAt(a.tape.Tape[a.off-1]&JSONVALUEMASK, a.tape.Tape[a.off])
+			if err != nil {
+				return nil, err
+			}
 			if val > math.MaxInt64 {
 				return nil, errors.New("float value overflows int64")
 			}
@@ -221,7 +320,7 @@ readArray:
 				return nil, errors.New("unsigned integer value overflows int64")
 			}
 
-			dst = append(dst)
+			dst = append(dst, int64(val))
 		case TagArrayEnd:
 			break readArray
 		default:
@@ -250,7 +349,10 @@ readArray:
 			if len(a.tape.Tape) <= a.off {
 				return nil, errors.New("corrupt input: expected float, but no more values")
 			}
-			val := math.Float64frombits(a.tape.Tape[a.off])
+			val, _, err := a.tape.floatAt(a.tape.Tape[a.off-1]&JSONVALUEMASK, a.tape.Tape[a.off])
+			if err != nil {
+				return nil, err
+			}
 			if val > math.MaxInt64 {
 				return nil, errors.New("float value overflows uint64")
 			}
@@ -343,3 +445,287 @@ func (a *Array) AsStringCvt() ([]string, error) {
 		}
 	}
 }
+
+// SumFloat returns the sum of all values in the array.
+// Integers are converted to float before being added.
+// An error is returned if the array contains non-number values.
+// The array is not advanced.
+func (a *Array) SumFloat() (float64, error) {
+	var sum float64
+	i := a.Iter()
+	for {
+		switch i.Advance() {
+		case TypeNone:
+			return sum, nil
+		case TypeFloat, TypeInt, TypeUint:
+			v, err := i.Float()
+			if err != nil {
+				return 0, err
+			}
+			sum += v
+		default:
+			return 0, fmt.Errorf("unable to sum type %v", i.Type())
+		}
+	}
+}
+
+// MinMaxFloat returns the smallest and largest value in the array.
+// Integers are converted to float before being compared.
+// An error is returned if the array is empty or contains non-number values.
+// The array is not advanced.
+func (a *Array) MinMaxFloat() (min, max float64, err error) {
+	i := a.Iter()
+	for n := 0; ; n++ {
+		switch i.Advance() {
+		case TypeNone:
+			if n == 0 {
+				return 0, 0, errors.New("array is empty")
+			}
+			return min, max, nil
+		case TypeFloat, TypeInt, TypeUint:
+			v, err := i.Float()
+			if err != nil {
+				return 0, 0, err
+			}
+			if n == 0 || v < min {
+				min = v
+			}
+			if n == 0 || v > max {
+				max = v
+			}
+		default:
+			return 0, 0, fmt.Errorf("unable to compare type %v", i.Type())
+		}
+	}
+}
+
+// SumInt returns the sum of all integer values in the array.
+// If the sum cannot be represented as an int64, overflow is true and sum is 0.
+// An error is returned if the array contains non-integer values, including floats.
+// The array is not advanced.
+func (a *Array) SumInt() (sum int64, overflow bool, err error) {
+	i := a.Iter()
+	for {
+		switch i.Advance() {
+		case TypeNone:
+			return sum, false, nil
+		case TypeInt:
+			v, err := i.Int()
+			if err != nil {
+				return 0, false, err
+			}
+			s := sum + v
+			if (v > 0 && s < sum) || (v < 0 && s > sum) {
+				return 0, true, nil
+			}
+			sum = s
+		case TypeUint:
+			v, err := i.Uint()
+			if err != nil {
+				return 0, false, err
+			}
+			if sum < 0 {
+				// Cancel out the negative sum first.
+				neg := uint64(-sum)
+				if v < neg {
+					sum += int64(v)
+					continue
+				}
+				v -= neg
+				sum = 0
+			}
+			if v > math.MaxInt64-uint64(sum) {
+				return 0, true, nil
+			}
+			sum += int64(v)
+		default:
+			return 0, false, fmt.Errorf("unable to sum type %v as integer", i.Type())
+		}
+	}
+}
+
+// Floats returns an iterator over the array values as float64.
+// Integers are converted to float, like AsFloat.
+// Arrays that only contain floats are read without the overhead of an Iter.
+// The array is not advanced.
+func (a *Array) Floats() FloatIter {
+	return FloatIter{typedIter: typedIter{tape: a.tape, off: a.off}}
+}
+
+// Ints returns an iterator over the array values as int64.
+// Uints/Floats are converted to int64 if they fit within the range, like AsInteger.
+// Arrays that only contain integers are read without the overhead of an Iter.
+// The array is not advanced.
+func (a *Array) Ints() IntIter {
+	return IntIter{typedIter: typedIter{tape: a.tape, off: a.off}}
+}
+
+// Strings returns an iterator over the array values as strings.
+// No conversion is done.
+// The array is not advanced.
+func (a *Array) Strings() StringIter {
+	return StringIter{typedIter: typedIter{tape: a.tape, off: a.off}}
+}
+
+// FloatIter iterates the values of an array as float64.
+// Use Array.Floats to create an iterator.
+type FloatIter struct {
+	typedIter
+}
+
+// Next returns the next value of the array.
+// When there are no more values or an error occurred false is returned.
+// Check Err to tell the two apart.
+func (f *FloatIter) Next() (float64, bool) {
+	// Fast path for floats that are not stored lazily.
+	if f.off+1 < len(f.tape.Tape) && f.tape.Tape[f.off] == uint64(TagFloat)<<JSONTAGOFFSET {
+		f.off += 2
+		return math.Float64frombits(f.tape.Tape[f.off-1]), true
+	}
+	tag, payload, val, ok := f.next()
+	if !ok {
+		return 0, false
+	}
+	switch tag {
+	case TagFloat:
+		v, _, err := f.tape.floatAt(payload, val)
+		if err != nil {
+			f.err = err
+			return 0, false
+		}
+		return v, true
+	case TagInteger:
+		return float64(int64(val)), true
+	case TagUint:
+		return float64(val), true
+	}
+	f.err = fmt.Errorf("unable to convert type %v to float", tag.Type())
+	return 0, false
+}
+
+// IntIter iterates the values of an array as int64.
+// Use Array.Ints to create an iterator.
+type IntIter struct {
+	typedIter
+}
+
+// Next returns the next value of the array.
+// When there are no more values or an error occurred false is returned.
+// Check Err to tell the two apart.
+func (n *IntIter) Next() (int64, bool) {
+	if n.off+1 < len(n.tape.Tape) && n.tape.Tape[n.off] == uint64(TagInteger)<<JSONTAGOFFSET {
+		n.off += 2
+		return int64(n.tape.Tape[n.off-1]), true
+	}
+	tag, payload, val, ok := n.next()
+	if !ok {
+		return 0, false
+	}
+	switch tag {
+	case TagInteger:
+		return int64(val), true
+	case TagUint:
+		if val > math.MaxInt64 {
+			n.err = errors.New("unsigned integer value overflows int64")
+			return 0, false
+		}
+		return int64(val), true
+	case TagFloat:
+		v, _, err := n.tape.floatAt(payload, val)
+		if err != nil {
+			n.err = err
+			return 0, false
+		}
+		if v > math.MaxInt64 {
+			n.err = errors.New("float value overflows int64")
+			return 0, false
+		}
+		if v < math.MinInt64 {
+			n.err = errors.New("float value underflows int64")
+			return 0, false
+		}
+		return int64(v), true
+	}
+	n.err = fmt.Errorf("unable to convert type %v to integer", tag.Type())
+	return 0, false
+}
+
+// StringIter iterates the values of an array as strings.
+// Use Array.Strings to create an iterator.
+type StringIter struct {
+	typedIter
+}
+
+// Next returns the next value of the array.
+// When there are no more values or an error occurred false is returned.
+// Check Err to tell the two apart.
+func (s *StringIter) Next() (string, bool) {
+	b, ok := s.NextBytes()
+	return string(b), ok
+}
+
+// NextBytes returns the next value of the array as bytes.
+// The returned slice references the parsed data and should not be modified.
+// When there are no more values or an error occurred false is returned.
+func (s *StringIter) NextBytes() ([]byte, bool) {
+	tag, payload, val, ok := s.next()
+	if !ok {
+		return nil, false
+	}
+	if tag != TagString {
+		s.err = fmt.Errorf("element in array is not string, but %v", tag.Type())
+		return nil, false
+	}
+	b, err := s.tape.stringByteAt(payload, val)
+	if err != nil {
+		s.err = err
+		return nil, false
+	}
+	return b, true
+}
+
+// typedIter contains the state shared by the typed array iterators.
+type typedIter struct {
+	tape ParsedJson
+	off  int
+	err  error
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (t *typedIter) Err() error {
+	return t.err
+}
+
+// next returns the tag, payload and value entry of the next element and skips it.
+// Elements without a value entry are returned without being skipped.
+// ok is false at the end of the array or if an error occurred.
+func (t *typedIter) next() (tag Tag, payload, val uint64, ok bool) {
+	for t.err == nil {
+		if t.off >= len(t.tape.Tape) {
+			t.err = errors.New("corrupt input: array not terminated")
+			break
+		}
+		entry := t.tape.Tape[t.off]
+		tag, payload = Tag(entry>>JSONTAGOFFSET), entry&JSONVALUEMASK
+		switch tag {
+		case TagArrayEnd:
+			return tag, 0, 0, false
+		case TagNop:
+			if payload == 0 {
+				t.err = errors.New("corrupt input: zero length nop")
+				break
+			}
+			t.off += int(payload)
+		case TagString, TagInteger, TagUint, TagFloat:
+			if t.off+1 >= len(t.tape.Tape) {
+				t.err = fmt.Errorf("corrupt input: expected %v, but no more values", tag.Type())
+				break
+			}
+			t.off += 2
+			return tag, payload, t.tape.Tape[t.off-1], true
+		default:
+			return tag, payload, 0, true
+		}
+	}
+	return tag, 0, 0, false
+}
@@ -0,0 +1,254 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// ChangeKind is the kind of change found by Diff.
+type ChangeKind uint8
+
+const (
+	// ChangeAdd is a value that is only present in the new document.
+	ChangeAdd ChangeKind = iota + 1
+	// ChangeRemove is a value that is only present in the old document.
+	ChangeRemove
+	// ChangeModify is a value that is present in both documents, but with a different value.
+	ChangeModify
+)
+
+// String returns the change kind as a string.
+func (c ChangeKind) String() string {
+	switch c {
+	case ChangeAdd:
+		return "add"
+	case ChangeRemove:
+		return "remove"
+	case ChangeModify:
+		return "modify"
+	}
+	return "(invalid)"
+}
+
+// Change is a single difference between two documents.
+type Change struct {
+	// Path to the value.
+	// Object keys are added as is and array indexes as decimal numbers.
+	Path []string
+	// Kind of change.
+	Kind ChangeKind
+	// Old value. Type is TypeNone for ChangeAdd.
+	Old Iter
+	// New value. Type is TypeNone for ChangeRemove.
+	New Iter
+}
+
+// Diff returns the changes between the old document a and the new document b.
+// Objects are compared by key and arrays by index.
+// When a value changes type, or a scalar changes value, a single ChangeModify is returned,
+// otherwise objects and arrays are compared recursively.
+// Integers and floats are considered different types.
+// If either document contains more than one root element (NDJSON),
+// roots are compared in order and the path is prefixed by the root index.
+// Changes are returned in document order of a, followed by additions from b.
+func Diff(a, b *ParsedJson) ([]Change, error) {
+	rootsA, err := diffRoots(a)
+	if err != nil {
+		return nil, err
+	}
+	rootsB, err := diffRoots(b)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	if len(rootsA) == 1 && len(rootsB) == 1 {
+		return diffValues(changes, nil, &rootsA[0], &rootsB[0])
+	}
+	for n := 0; n < len(rootsA) || n < len(rootsB); n++ {
+		path := []string{strconv.Itoa(n)}
+		switch {
+		case n >= len(rootsB):
+			changes = append(changes, Change{Path: path, Kind: ChangeRemove, Old: rootsA[n]})
+		case n >= len(rootsA):
+			changes = append(changes, Change{Path: path, Kind: ChangeAdd, New: rootsB[n]})
+		default:
+			changes, err = diffValues(changes, path, &rootsA[n], &rootsB[n])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// diffRoots returns an iterator for the content of each root.
+func diffRoots(pj *ParsedJson) ([]Iter, error) {
+	var roots []Iter
+	i := pj.Iter()
+	var elem Iter
+	for {
+		t, err := i.AdvanceIter(&elem)
+		if err != nil {
+			return nil, err
+		}
+		if t != TypeRoot {
+			return roots, nil
+		}
+		var content Iter
+		if _, _, err := elem.Root(&content); err != nil {
+			return nil, err
+		}
+		roots = append(roots, content)
+	}
+}
+
+// diffPath returns path with key appended without modifying path.
+func diffPath(path []string, key string) []string {
+	return append(path[:len(path):len(path)], key)
+}
+
+func diffValues(changes []Change, path []string, a, b *Iter) ([]Change, error) {
+	if a.t != b.t && (a.Type() != TypeBool || b.Type() != TypeBool) {
+		return append(changes, Change{Path: path, Kind: ChangeModify, Old: *a, New: *b}), nil
+	}
+	switch a.Type() {
+	case TypeObject:
+		return diffObjects(changes, path, a, b)
+	case TypeArray:
+		return diffArrays(changes, path, a, b)
+	}
+	equal, err := scalarsEqual(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if !equal {
+		changes = append(changes, Change{Path: path, Kind: ChangeModify, Old: *a, New: *b})
+	}
+	return changes, nil
+}
+
+// scalarsEqual returns whether a and b, which must have the same tag, contain the same value.
+func scalarsEqual(a, b *Iter) (bool, error) {
+	switch a.t {
+	case TagString:
+		sa, err := a.StringBytes()
+		if err != nil {
+			return false, err
+		}
+		sb, err := b.StringBytes()
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(sa, sb), nil
+	case TagInteger, TagUint, TagFloat:
+		if a.off >= len(a.tape.Tape) || b.off >= len(b.tape.Tape) {
+			return false, fmt.Errorf("corrupt input: expected %v, but no more values on tape", a.t.Type())
+		}
+		if a.t == TagFloat {
+			fa, _ := a.Float()
+			fb, _ := b.Float()
+			return fa == fb, nil
+		}
+		return a.tape.Tape[a.off] == b.tape.Tape[b.off], nil
+	case TagBoolTrue, TagBoolFalse, TagNull:
+		return a.t == b.t, nil
+	}
+	return false, fmt.Errorf("cannot compare type %v", a.t.Type())
+}
+
+func diffObjects(changes []Change, path []string, a, b *Iter) ([]Change, error) {
+	objA, err := a.Object(nil)
+	if err != nil {
+		return nil, err
+	}
+	objB, err := b.Object(nil)
+	if err != nil {
+		return nil, err
+	}
+	elemsB, err := objB.Parse(nil)
+	if err != nil {
+		return nil, err
+	}
+	seen := make([]bool, len(elemsB.Elements))
+	var elem Iter
+	for {
+		name, t, err := objA.NextElement(&elem)
+		if err != nil {
+			return nil, err
+		}
+		if t == TypeNone {
+			break
+		}
+		idx, ok := elemsB.Index[name]
+		if !ok {
+			changes = append(changes, Change{Path: diffPath(path, name), Kind: ChangeRemove, Old: elem})
+			continue
+		}
+		seen[idx] = true
+		changes, err = diffValues(changes, diffPath(path, name), &elem, &elemsB.Elements[idx].Iter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for idx, e := range elemsB.Elements {
+		// Skip seen and duplicate keys.
+		if seen[idx] || elemsB.Index[e.Name] != idx {
+			continue
+		}
+		changes = append(changes, Change{Path: diffPath(path, e.Name), Kind: ChangeAdd, New: e.Iter})
+	}
+	return changes, nil
+}
+
+func diffArrays(changes []Change, path []string, a, b *Iter) ([]Change, error) {
+	arrA, err := a.Array(nil)
+	if err != nil {
+		return nil, err
+	}
+	arrB, err := b.Array(nil)
+	if err != nil {
+		return nil, err
+	}
+	iterA, iterB := arrA.Iter(), arrB.Iter()
+	var elemA, elemB Iter
+	for n := 0; ; n++ {
+		tA, err := iterA.AdvanceIter(&elemA)
+		if err != nil {
+			return nil, err
+		}
+		tB, err := iterB.AdvanceIter(&elemB)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tA == TypeNone && tB == TypeNone:
+			return changes, nil
+		case tB == TypeNone:
+			changes = append(changes, Change{Path: diffPath(path, strconv.Itoa(n)), Kind: ChangeRemove, Old: elemA})
+		case tA == TypeNone:
+			changes = append(changes, Change{Path: diffPath(path, strconv.Itoa(n)), Kind: ChangeAdd, New: elemB})
+		default:
+			changes, err = diffValues(changes, diffPath(path, strconv.Itoa(n)), &elemA, &elemB)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name string
+		a, b string
+		nd   bool
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    demo_json,
+			b:    demo_json,
+		},
+		{
+			name: "modify",
+			a:    `{"a":1,"b":"x","c":true,"d":1.5}`,
+			b:    `{"a":2,"b":"x","c":false,"d":1.5}`,
+			want: []string{"modify a 1 -> 2", "modify c true -> false"},
+		},
+		{
+			name: "type-change",
+			a:    `{"a":1,"b":{"c":1}}`,
+			b:    `{"a":"1","b":[1]}`,
+			want: []string{`modify a 1 -> "1"`, `modify b {"c":1} -> [1]`},
+		},
+		{
+			name: "add-remove",
+			a:    `{"a":1,"b":{"c":1,"d":2}}`,
+			b:    `{"b":{"d":2,"e":[3]},"f":null}`,
+			want: []string{"remove a 1 -> ", "remove b/c 1 -> ", "add b/e  -> [3]", "add f  -> null"},
+		},
+		{
+			name: "array",
+			a:    `[1,[2,3],{"x":4}]`,
+			b:    `[1,[2,5,6]]`,
+			want: []string{"modify 1/1 3 -> 5", "add 1/2  -> 6", `remove 2 {"x":4} -> `},
+		},
+		{
+			name: "ndjson",
+			a:    "{\"a\":1}\n{\"a\":2}",
+			b:    "{\"a\":1}\n{\"a\":3}\n{\"a\":4}",
+			nd:   true,
+			want: []string{"modify 1/a 2 -> 3", `add 2  -> {"a":4}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse := Parse
+			if tt.nd {
+				parse = ParseND
+			}
+			a, err := parse([]byte(tt.a), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := parse([]byte(tt.b), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := Diff(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range changes {
+				var oldV, newV []byte
+				if c.Old.Type() != TypeNone {
+					if oldV, err = c.Old.MarshalJSON(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if c.New.Type() != TypeNone {
+					if newV, err = c.New.MarshalJSON(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				got = append(got, fmt.Sprintf("%v %s %s -> %s", c.Kind, strings.Join(c.Path, "/"), oldV, newV))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
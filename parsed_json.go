@@ -17,10 +17,17 @@
 package simdjson
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 )
 
 const JSONVALUEMASK = 0xff_ffff_ffff_ffff
@@ -29,8 +36,88 @@ const JSONTAGMASK = 0xff << JSONTAGOFFSET
 const STRINGBUFBIT = 0x80_0000_0000_0000
 const STRINGBUFMASK = 0x7fffffffffffff
 
+// rawNumberBit is set on float entries parsed with WithLazyNumbers.
+// The value then contains the offset of the number in Message and the next entry contains its length.
+const rawNumberBit = 0x80_0000_0000_0000
+
 const maxdepth = 128
 
+// ErrEmptyInput is returned when parsing input that is empty or only contains whitespace.
+var ErrEmptyInput = errors.New("input is empty")
+
+// ErrTooManyElements is returned when the parsed input exceeds the limit set by WithMaxElements.
+var ErrTooManyElements = errors.New("maximum number of elements exceeded")
+
+// LiteralError is returned when a true, false or null literal in the input is malformed.
+type LiteralError struct {
+	// Offset is the byte offset of the literal in the input.
+	Offset int
+	// Want is the literal that was expected: "true", "false" or "null".
+	Want string
+	// Got contains the literal as it appeared in the input.
+	Got string
+}
+
+func (e *LiteralError) Error() string {
+	return fmt.Sprintf("invalid literal %q near offset %d: expected '%s'", e.Got, e.Offset, e.Want)
+}
+
+// EmptyError is returned when an empty key or container is rejected
+// by WithRejectEmptyKeys or WithRejectEmptyContainers.
+type EmptyError struct {
+	// Offset is the byte offset of the key or container in the input,
+	// not counting leading whitespace.
+	Offset int
+	// Kind is "key", "object" or "array".
+	Kind string
+}
+
+func (e *EmptyError) Error() string {
+	return fmt.Sprintf("empty %s at offset %d", e.Kind, e.Offset)
+}
+
+// ParseError is returned when the structure of the input is invalid.
+// The position is only calculated when parsing fails.
+type ParseError struct {
+	// Offset is the byte offset in the input where parsing failed,
+	// not counting leading whitespace.
+	// If the input ended before the last value was complete, Offset is the length of the input.
+	Offset int
+	// Line and Col are the 1-based line and byte column of Offset.
+	Line, Col int
+	// Msg describes the failure.
+	Msg string
+	// Err contains the reason parsing failed, if known.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v (line %d, column %d, offset %d)", e.Msg, e.Err, e.Line, e.Col, e.Offset)
+	}
+	return fmt.Sprintf("%s (line %d, column %d, offset %d)", e.Msg, e.Line, e.Col, e.Offset)
+}
+
+// Unwrap returns the reason parsing failed, if known.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError returns a ParseError at offset in msg.
+func newParseError(msg []byte, offset int, text string, err error) *ParseError {
+	if offset > len(msg) {
+		offset = len(msg)
+	}
+	before := msg[:offset]
+	return &ParseError{
+		Offset: offset,
+		Line:   1 + bytes.Count(before, []byte{'\n'}),
+		Col:    offset - bytes.LastIndexByte(before, '\n'),
+		Msg:    text,
+		Err:    err,
+	}
+}
+
 // FloatFlags are flags recorded when converting floats.
 type FloatFlags uint64
 
@@ -66,8 +153,40 @@ type ParsedJson struct {
 	Tape    []uint64
 	Strings *TStrings
 
+	// rawStrings contains the source location of copied strings
+	// when parsed with WithPreserveEscapes.
+	rawStrings []rawString
+
+	// spans contains the source location of objects and arrays
+	// when parsed with WithSourceSpans.
+	spans []containerSpan
+
+	// warnings contains the warnings collected by WithCollectWarnings.
+	warnings []Warning
+
 	// allows to reuse the internal structures without exposing it.
 	internal *internalParsedJson
+
+	// owner is the ParsedJson an iterator was created from.
+	// It is only set on the copies held by iterators, objects and arrays,
+	// and is updated when the tape must grow to fit a replacement value.
+	owner *ParsedJson
+}
+
+// rawString is the location in Message of a string copied to the string buffer.
+type rawString struct {
+	// strOffset is the offset in the string buffer.
+	strOffset uint64
+	// srcStart and srcEnd is the location of the string in Message, including quotes.
+	srcStart, srcEnd uint64
+}
+
+// containerSpan is the location in Message of an object or array.
+type containerSpan struct {
+	// tapeOffset is the offset of the start entry on the tape.
+	tapeOffset uint64
+	// srcStart and srcEnd is the location in Message, including braces.
+	srcStart, srcEnd uint64
 }
 
 const indexSlots = 16
@@ -89,12 +208,82 @@ type internalParsedJson struct {
 	buffers               [indexSlots][indexSize]uint32
 	buffersOffset         uint64
 	ndjson                uint64
-	copyStrings           bool
+	copyKeys              bool
+	copyValues            bool
+	preserveEscapes       bool
+	skipBadLines          bool
+	strict                bool
+	duplicateKeys         DuplicateKeys
+	internStrings         bool
+	leadingPlus           bool
+	inputPadding          int
+	singleAlloc           bool
+	lazyNumbers           bool
+	maxElements           int
+	sourceSpans           bool
+	rejectEmptyKeys       bool
+	rejectEmptyContainers bool
+	singleQuotes          bool
+	collectWarnings       bool
+	validateUTF8          bool
+	allowScalarRoot       bool
+	bigNumbers            bool
+
+	// messagePadding is the number of bytes that can be read after Message.
+	messagePadding int
+
+	// done will cancel parsing when closed, if non-nil.
+	done <-chan struct{}
+	// aborted is set if stage 1 or stage 2 stopped because parsing was cancelled.
+	aborted bool
+	// cancelChecks counts the calls to stage2Cancelled.
+	cancelChecks uint
+	// trackContainers is set if the source location of objects and arrays is recorded,
+	// either for WithSourceSpans or for warnings.
+	trackContainers bool
+	// warnedDepth is set when WarningDeepNesting has been added.
+	warnedDepth bool
+	// spanStack contains the index in spans of each open object and array.
+	spanStack []int
+	// stage2Err contains the reason stage 2 failed, if known.
+	stage2Err error
+	// failOffset is the offset in Message where stage 2 failed.
+	failOffset int
+	// transcoded contains the input converted by ParseEncoding.
+	transcoded []byte
+	// singleQuoted contains the input converted by WithSingleQuoteStrings.
+	singleQuoted []byte
+	// intern is used for deduplicating strings when internStrings is set, otherwise nil.
+	intern *internTable
+	// internTable is kept between parses, so it can be reused.
+	internTable *internTable
+}
+
+// internTable contains the offset+1 of strings in the string buffer, indexed by hash.
+type internTable [stringSize]uint32
+
+// lookup returns the offset of a previous string equal to strs[start:].
+// If no string is found, the string is added to the table.
+func (t *internTable) lookup(strs []byte, start int) (offset int, found bool) {
+	s := strs[start:]
+	h := memHash(s) & stringmask
+	off := int(t[h]) - 1
+	if off >= 0 && off+len(s) <= start && bytes.Equal(strs[off:off+len(s)], s) {
+		return off, true
+	}
+	if uint64(start) < math.MaxUint32 {
+		t[h] = uint32(start + 1)
+	}
+	return 0, false
 }
 
 // Iter returns a new Iter.
 func (pj *ParsedJson) Iter() Iter {
-	return Iter{tape: *pj}
+	i := Iter{tape: *pj}
+	if i.tape.owner == nil {
+		i.tape.owner = pj
+	}
+	return i
 }
 
 // stringAt returns a string at a specific offset in the stringbuffer.
@@ -119,24 +308,186 @@ func (pj *ParsedJson) stringByteAt(offset, length uint64) ([]byte, error) {
 	return pj.Strings.B[offset : offset+length], nil
 }
 
+// rawNumberAt returns the source of a number stored with rawNumberBit.
+func (pj *ParsedJson) rawNumberAt(payload, length uint64) ([]byte, error) {
+	offset := payload &^ rawNumberBit
+	if offset+length > uint64(len(pj.Message)) {
+		return nil, fmt.Errorf("number message offset (%v) outside valid area (%v)", offset+length, len(pj.Message))
+	}
+	return pj.Message[offset : offset+length], nil
+}
+
+// floatAt returns the value of a float given the payload of the tag and the value entry.
+// Floats stored with rawNumberBit are converted from their source.
+func (pj *ParsedJson) floatAt(payload, val uint64) (float64, FloatFlags, error) {
+	if payload&rawNumberBit == 0 {
+		return math.Float64frombits(val), FloatFlags(payload), nil
+	}
+	b, err := pj.rawNumberAt(payload, val)
+	if err != nil {
+		return 0, 0, err
+	}
+	id, v := parseNumber(b)
+	if id == 0 {
+		return 0, 0, numberError(b)
+	}
+	if Tag(id>>JSONTAGOFFSET) != TagFloat {
+		return 0, 0, fmt.Errorf("number %q is not a float", b)
+	}
+	return math.Float64frombits(v), FloatFlags(id & JSONVALUEMASK), nil
+}
+
+// MemStats returns the memory used by the tape, the string buffer and the message,
+// based on the capacity of each buffer.
+// When a ParsedJson is reused, buffers are kept and grown as needed,
+// so this can be used to decide whether to discard a ParsedJson that has grown too large.
+// The message is only owned by the ParsedJson if the input was copied, for example by ParseJSONSeq.
+func (pj *ParsedJson) MemStats() (tapeBytes, stringBytes, messageBytes int) {
+	tapeBytes = cap(pj.Tape) * 8
+	if pj.Strings != nil {
+		stringBytes = cap(pj.Strings.B)
+	}
+	return tapeBytes, stringBytes, cap(pj.Message)
+}
+
+// RootType returns the type of the value in the first root element.
+// For NDJSON this is the type of the first line.
+// TypeNone is returned if the tape is empty or invalid.
+func (pj *ParsedJson) RootType() Type {
+	tape := pj.Tape
+	if len(tape) < 2 || Tag(tape[0]>>JSONTAGOFFSET) != TagRoot {
+		return TypeNone
+	}
+	for off := 1; off < len(tape); {
+		tag := Tag(tape[off] >> JSONTAGOFFSET)
+		if tag != TagNop {
+			if tag == TagRoot {
+				return TypeNone
+			}
+			return tag.Type()
+		}
+		skip := int(tape[off] & JSONVALUEMASK)
+		if skip <= 0 {
+			return TypeNone
+		}
+		off += skip
+	}
+	return TypeNone
+}
+
 // ForEach returns each line in NDJSON, or the top element in non-ndjson.
 // This will usually be an object or an array.
 // If the callback returns a non-nil error parsing stops and the errors is returned.
 func (pj *ParsedJson) ForEach(fn func(i Iter) error) error {
-	i := Iter{tape: *pj}
-	var elem Iter
+	i := pj.Iter()
+	var elem, content Iter
 	for {
 		t, err := i.AdvanceIter(&elem)
 		if err != nil || t != TypeRoot {
 			return err
 		}
-		elem.AdvanceInto()
-		if err = fn(elem); err != nil {
+		if _, _, err = elem.Root(&content); err != nil {
+			return err
+		}
+		if err = fn(content); err != nil {
 			return err
 		}
 	}
 }
 
+// Column returns the value at path in every root, in order.
+// For NDJSON this is one value per line.
+// The path is resolved like FindElement, so it can only traverse objects.
+// Each value is appended to dst as marshaled JSON, so strings are quoted
+// and objects and arrays are included in full.
+// If a root does not contain the path, or is not an object, nil is appended.
+// Buffers of entries in dst beyond its length are reused,
+// so calling Column(path, column[:0]) will reuse all previous values.
+func (pj *ParsedJson) Column(path []string, dst [][]byte) ([][]byte, error) {
+	if len(path) == 0 {
+		return dst, errors.New("column: empty path")
+	}
+	var elem Element
+	n := 0
+	err := pj.ForEach(func(i Iter) error {
+		n++
+		var buf []byte
+		if len(dst) < cap(dst) {
+			buf = dst[:len(dst)+1][len(dst)][:0]
+		}
+		if i.Type() != TypeObject {
+			dst = append(dst, nil)
+			return nil
+		}
+		_, err := i.FindElement(&elem, path...)
+		if errors.Is(err, ErrPathNotFound) {
+			dst = append(dst, nil)
+			return nil
+		}
+		if err == nil {
+			buf, err = elem.Iter.MarshalJSONBuffer(buf)
+		}
+		if err != nil {
+			return fmt.Errorf("root %d: %w", n, err)
+		}
+		dst = append(dst, buf)
+		return nil
+	})
+	return dst, err
+}
+
+// CountKeyOccurrences returns how many times key is used as an object key
+// at any depth in all roots of the document.
+// The tape is scanned linearly, so no values are decoded.
+func (pj *ParsedJson) CountKeyOccurrences(key string) int {
+	// inObject contains whether each open scope is an object.
+	var inObject []bool
+	expectKey := false
+	n := 0
+	tape := pj.Tape
+	for off := 0; off < len(tape); off++ {
+		entry := tape[off]
+		tag := Tag(entry >> JSONTAGOFFSET)
+		switch tag {
+		case TagString:
+			if off+1 >= len(tape) {
+				return n
+			}
+			off++
+			if expectKey {
+				if tape[off] == uint64(len(key)) {
+					b, err := pj.stringByteAt(entry&JSONVALUEMASK, tape[off])
+					if err == nil && string(b) == key {
+						n++
+					}
+				}
+				expectKey = false
+				continue
+			}
+		case TagInteger, TagUint, TagFloat:
+			off++
+		case TagNop:
+			if skip := entry & JSONVALUEMASK; skip > 0 {
+				off += int(skip) - 1
+			}
+			continue
+		case TagObjectStart, TagArrayStart:
+			inObject = append(inObject, tag == TagObjectStart)
+			expectKey = tag == TagObjectStart
+			continue
+		case TagObjectEnd, TagArrayEnd:
+			if len(inObject) > 0 {
+				inObject = inObject[:len(inObject)-1]
+			}
+		case TagRoot:
+			continue
+		}
+		// A value has ended, a key follows if we are in an object.
+		expectKey = len(inObject) > 0 && inObject[len(inObject)-1]
+	}
+	return n
+}
+
 // Clone returns a deep clone of the ParsedJson.
 // If a nil destination is sent a new will be created.
 func (pj *ParsedJson) Clone(dst *ParsedJson) *ParsedJson {
@@ -161,12 +512,16 @@ func (pj *ParsedJson) Clone(dst *ParsedJson) *ParsedJson {
 		}
 	}
 	dst.internal = nil
+	dst.owner = nil
 	dst.Tape = dst.Tape[:len(pj.Tape)]
 	copy(dst.Tape, pj.Tape)
 	dst.Message = dst.Message[:len(pj.Message)]
 	copy(dst.Message, pj.Message)
 	dst.Strings.B = dst.Strings.B[:len(pj.Strings.B)]
 	copy(dst.Strings.B, pj.Strings.B)
+	dst.rawStrings = append(dst.rawStrings[:0], pj.rawStrings...)
+	dst.spans = append(dst.spans[:0], pj.spans...)
+	dst.warnings = append(dst.warnings[:0], pj.warnings...)
 	return dst
 }
 
@@ -208,7 +563,12 @@ func (i *Iter) Advance() Type {
 		i.off++
 		i.cur = v & JSONVALUEMASK
 		if i.t == TagNop {
-			i.off += int(i.cur)
+			if i.cur == 0 {
+				i.moveToEnd()
+				return TypeNone
+			}
+			// The skip includes the nop itself.
+			i.off += int(i.cur) - 1
 			continue
 		}
 		break
@@ -277,6 +637,62 @@ func (i *Iter) calcNext(into bool) {
 	}
 }
 
+// Reset will return the iterator to the start of its tape span.
+// For iterators returned by ParsedJson.Iter the next call to Advance
+// will return the first root again.
+// For other iterators the value ending the span is queued again.
+// This is the array for iterators from Array.Iter and the value for iterators
+// from AdvanceIter, Object.NextElement or Root,
+// so the next call to Advance will return the first element again.
+// This allows multiple passes over the same values.
+func (i *Iter) Reset() {
+	i.addNext = 0
+	i.cur = 0
+	i.t = TagEnd
+	i.off = 0
+	end := len(i.tape.Tape)
+	if end == 0 || Tag(i.tape.Tape[end-1]>>56) == TagRoot {
+		return
+	}
+	i.off = i.tape.valueStart(end)
+	i.AdvanceInto()
+}
+
+// valueStart returns the offset of the value ending at end.
+// Objects and arrays are found from their end tag.
+// Other values are found by descending from the start of the tape.
+func (pj *ParsedJson) valueStart(end int) int {
+	v := pj.Tape[end-1]
+	switch Tag(v >> 56) {
+	case TagObjectEnd, TagArrayEnd:
+		return int(v & JSONVALUEMASK)
+	}
+	for off := 0; off < end; {
+		v := pj.Tape[off]
+		next := off + 1
+		switch Tag(v >> 56) {
+		case TagRoot, TagObjectStart, TagArrayStart:
+			next = int(v & JSONVALUEMASK)
+			if next > end {
+				// end is inside, descend.
+				off++
+				continue
+			}
+		case TagInteger, TagUint, TagFloat, TagString:
+			next = off + 2
+		case TagNop:
+			if skip := int(v & JSONVALUEMASK); skip > 0 {
+				next = off + skip
+			}
+		}
+		if next == end {
+			return off
+		}
+		off = next
+	}
+	return 0
+}
+
 // Type returns the queued value type from the previous call to Advance.
 func (i *Iter) Type() Type {
 	if i.off+i.addNext > len(i.tape.Tape) {
@@ -310,7 +726,7 @@ func (i *Iter) AdvanceIter(dst *Iter) (Type, error) {
 			if i.cur <= 0 {
 				return TypeNone, errors.New("invalid nop skip")
 			}
-			i.off += int(i.cur)
+			i.off += int(i.cur) - 1
 			continue
 		}
 		break
@@ -399,7 +815,95 @@ func (i *Iter) MarshalJSON() ([]byte, error) {
 // An optional buffer can be provided for fewer allocations.
 // Output will be appended to the destination.
 func (i *Iter) MarshalJSONBuffer(dst []byte) ([]byte, error) {
-	var tmpBuf []byte
+	return i.marshalJSONBuffer(dst, defaultMarshalOptions())
+}
+
+// MarshalJSONBufferOpts will marshal the remaining scope of the iterator including the current value,
+// using the supplied options.
+// An optional buffer can be provided for fewer allocations.
+// Output will be appended to the destination.
+func (i *Iter) MarshalJSONBufferOpts(dst []byte, opts ...MarshalOption) ([]byte, error) {
+	o := defaultMarshalOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	return i.marshalJSONBuffer(dst, o)
+}
+
+// MarshalJSONIndent will marshal the remaining scope of the iterator including the current value
+// with indentation, like encoding/json.MarshalIndent.
+// See MarshalIndent for how output is formatted.
+// An optional buffer can be provided for fewer allocations.
+// Output will be appended to the destination.
+func (i *Iter) MarshalJSONIndent(dst []byte, prefix, indent string) ([]byte, error) {
+	o := defaultMarshalOptions()
+	o.indent = true
+	o.prefix, o.indStr = prefix, indent
+	return i.marshalJSONBuffer(dst, o)
+}
+
+// appendIndent will add a newline, the prefix and indent depth times to dst.
+func (o *marshalOptions) appendIndent(dst []byte, depth int) []byte {
+	dst = append(dst, '\n')
+	dst = append(dst, o.prefix...)
+	for ; depth > 0; depth-- {
+		dst = append(dst, o.indStr...)
+	}
+	return dst
+}
+
+// appendScalar will append the current string, number, bool or null value to dst.
+// Floats stored as their source, with WithLazyNumbers or WithBigNumbers, are written as they appeared in the input.
+func (i *Iter) appendScalar(dst []byte, opts marshalOptions) ([]byte, error) {
+	switch i.t {
+	case TagString:
+		sb, err := i.StringBytes()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, '"')
+		dst = escapeBytes(dst, sb)
+		return append(dst, '"'), nil
+	case TagInteger:
+		v, err := i.Int()
+		if err != nil {
+			return nil, err
+		}
+		return appendIntOpts(dst, v, opts), nil
+	case TagUint:
+		v, err := i.Uint()
+		if err != nil {
+			return nil, err
+		}
+		return appendUintOpts(dst, v, opts), nil
+	case TagFloat:
+		if i.cur&rawNumberBit != 0 && i.off < len(i.tape.Tape) {
+			b, err := i.tape.rawNumberAt(i.cur, i.tape.Tape[i.off])
+			if err != nil {
+				return nil, err
+			}
+			return append(dst, b...), nil
+		}
+		v, err := i.Float()
+		if err != nil {
+			return nil, err
+		}
+		return appendFloatOpts(dst, v, opts)
+	case TagNull:
+		return append(dst, "null"...), nil
+	case TagBoolTrue:
+		return append(dst, "true"...), nil
+	case TagBoolFalse:
+		return append(dst, "false"...), nil
+	}
+	return nil, fmt.Errorf("cannot marshal type %v", i.Type())
+}
+
+func (i *Iter) marshalJSONBuffer(dst []byte, opts marshalOptions) ([]byte, error) {
+	// depth is the number of open objects and arrays, used for indentation.
+	var depth int
 
 	// Pre-allocate for 100 deep.
 	var stackTmp [100]uint8
@@ -423,6 +927,9 @@ writeloop:
 			dst = append(dst, '"')
 			dst = escapeBytes(dst, sb)
 			dst = append(dst, '"', ':')
+			if opts.indent {
+				dst = append(dst, ' ')
+			}
 			if i.PeekNextTag() == TagEnd {
 				return nil, fmt.Errorf("unexpected end of tape within object")
 			}
@@ -442,6 +949,9 @@ writeloop:
 				case stackRoot:
 					if i.PeekNextTag() != TagEnd {
 						dst = append(dst, '\n')
+						if opts.indent {
+							dst = append(dst, opts.prefix...)
+						}
 					}
 					stack = stack[:len(stack)-1]
 					break tagswitch
@@ -459,64 +969,50 @@ writeloop:
 			i.AdvanceInto()
 			stack = append(stack, stackRoot)
 			continue
-		case TagString:
-			sb, err := i.StringBytes()
-			if err != nil {
-				return nil, err
-			}
-			dst = append(dst, '"')
-			dst = escapeBytes(dst, sb)
-			dst = append(dst, '"')
-			tmpBuf = tmpBuf[:0]
-		case TagInteger:
-			v, err := i.Int()
-			if err != nil {
-				return nil, err
-			}
-			dst = strconv.AppendInt(dst, v, 10)
-		case TagUint:
-			v, err := i.Uint()
-			if err != nil {
-				return nil, err
-			}
-			dst = strconv.AppendUint(dst, v, 10)
-		case TagFloat:
-			v, err := i.Float()
+		case TagString, TagInteger, TagUint, TagFloat, TagNull, TagBoolTrue, TagBoolFalse:
+			var err error
+			dst, err = i.appendScalar(dst, opts)
 			if err != nil {
 				return nil, err
 			}
-			dst, err = appendFloat(dst, v)
-			if err != nil {
-				return nil, err
-			}
-		case TagNull:
-			dst = append(dst, []byte("null")...)
-		case TagBoolTrue:
-			dst = append(dst, []byte("true")...)
-		case TagBoolFalse:
-			dst = append(dst, []byte("false")...)
 		case TagObjectStart:
 			dst = append(dst, '{')
 			stack = append(stack, stackObject)
+			depth++
 			// We should not emit commas.
 			i.AdvanceInto()
+			if opts.indent && i.t != TagObjectEnd {
+				dst = opts.appendIndent(dst, depth)
+			}
 			continue
 		case TagObjectEnd:
-			dst = append(dst, '}')
 			if stack[len(stack)-1] != stackObject {
 				return dst, errors.New("end of object with no object on stack")
 			}
+			depth--
+			if opts.indent && dst[len(dst)-1] != '{' {
+				dst = opts.appendIndent(dst, depth)
+			}
+			dst = append(dst, '}')
 			stack = stack[:len(stack)-1]
 		case TagArrayStart:
 			dst = append(dst, '[')
 			stack = append(stack, stackArray)
+			depth++
 			i.AdvanceInto()
+			if opts.indent && i.t != TagArrayEnd {
+				dst = opts.appendIndent(dst, depth)
+			}
 			continue
 		case TagArrayEnd:
-			dst = append(dst, ']')
 			if stack[len(stack)-1] != stackArray {
 				return nil, errors.New("end of array with no array on stack")
 			}
+			depth--
+			if opts.indent && dst[len(dst)-1] != '[' {
+				dst = opts.appendIndent(dst, depth)
+			}
+			dst = append(dst, ']')
 			stack = stack[:len(stack)-1]
 		case TagEnd:
 			if i.PeekNextTag() == TagEnd {
@@ -538,12 +1034,18 @@ writeloop:
 			case TagArrayEnd:
 			default:
 				dst = append(dst, ',')
+				if opts.indent {
+					dst = opts.appendIndent(dst, depth)
+				}
 			}
 		case stackObject:
 			switch i.t {
 			case TagObjectEnd:
 			default:
 				dst = append(dst, ',')
+				if opts.indent {
+					dst = opts.appendIndent(dst, depth)
+				}
 			}
 		}
 	}
@@ -563,8 +1065,8 @@ func (i *Iter) Float() (float64, error) {
 		if i.off >= len(i.tape.Tape) {
 			return 0, errors.New("corrupt input: expected float, but no more values on tape")
 		}
-		v := math.Float64frombits(i.tape.Tape[i.off])
-		return v, nil
+		v, _, err := i.tape.floatAt(i.cur, i.tape.Tape[i.off])
+		return v, err
 	case TagInteger:
 		if i.off >= len(i.tape.Tape) {
 			return 0, errors.New("corrupt input: expected integer, but no more values on tape")
@@ -591,8 +1093,7 @@ func (i *Iter) FloatFlags() (float64, FloatFlags, error) {
 		if i.off >= len(i.tape.Tape) {
 			return 0, 0, errors.New("corrupt input: expected float, but no more values on tape")
 		}
-		v := math.Float64frombits(i.tape.Tape[i.off])
-		return v, FloatFlags(i.cur), nil
+		return i.tape.floatAt(i.cur, i.tape.Tape[i.off])
 	case TagInteger:
 		if i.off >= len(i.tape.Tape) {
 			return 0, 0, errors.New("corrupt input: expected integer, but no more values on tape")
@@ -610,6 +1111,134 @@ func (i *Iter) FloatFlags() (float64, FloatFlags, error) {
 	}
 }
 
+// NumberKind is the native representation of a number on the tape.
+type NumberKind uint8
+
+const (
+	// NumberNone is returned when the value is not a number.
+	NumberNone NumberKind = iota
+	// NumberInt is a number stored as int64.
+	NumberInt
+	// NumberUint is a number stored as uint64.
+	NumberUint
+	// NumberFloat is a number stored as float64.
+	NumberFloat
+)
+
+// String returns the kind as a string.
+func (n NumberKind) String() string {
+	switch n {
+	case NumberNone:
+		return "(not a number)"
+	case NumberInt:
+		return "int"
+	case NumberUint:
+		return "uint"
+	case NumberFloat:
+		return "float"
+	}
+	return "(invalid)"
+}
+
+// NumberKind returns the native kind of the current value if it is a number.
+// NumberNone is returned for all other types.
+func (i *Iter) NumberKind() NumberKind {
+	switch i.t {
+	case TagInteger:
+		return NumberInt
+	case TagUint:
+		return NumberUint
+	case TagFloat:
+		return NumberFloat
+	}
+	return NumberNone
+}
+
+// Num returns the current number value in its native kind without any conversion.
+// Only the return value matching the returned kind is set.
+// An error is returned if the value is not a number.
+func (i *Iter) Num() (int64, uint64, float64, NumberKind, error) {
+	kind := i.NumberKind()
+	if kind == NumberNone {
+		return 0, 0, 0, kind, fmt.Errorf("value is not a number, but %v", i.t)
+	}
+	if i.off >= len(i.tape.Tape) {
+		return 0, 0, 0, NumberNone, errors.New("corrupt input: expected number, but no more values on tape")
+	}
+	v := i.tape.Tape[i.off]
+	switch kind {
+	case NumberInt:
+		return int64(v), 0, 0, kind, nil
+	case NumberUint:
+		return 0, v, 0, kind, nil
+	default:
+		f, _, err := i.tape.floatAt(i.cur, v)
+		if err != nil {
+			return 0, 0, 0, NumberNone, err
+		}
+		return 0, 0, f, kind, nil
+	}
+}
+
+// InRange returns whether the current number is within min and max, both inclusive.
+// Integers are compared as float64, so values above 2^53 may lose precision.
+// An error is returned if the value is not a number.
+func (i *Iter) InRange(min, max float64) (bool, error) {
+	vi, vu, vf, kind, err := i.Num()
+	if err != nil {
+		return false, err
+	}
+	switch kind {
+	case NumberInt:
+		vf = float64(vi)
+	case NumberUint:
+		vf = float64(vu)
+	}
+	return vf >= min && vf <= max, nil
+}
+
+// MultipleOf returns whether the current number is an integer multiple of n.
+// Floats are only a multiple if they have no fractional part.
+// An error is returned if the value is not a number or n is 0.
+func (i *Iter) MultipleOf(n int64) (bool, error) {
+	if n == 0 {
+		return false, errors.New("multiple of zero")
+	}
+	vi, vu, vf, kind, err := i.Num()
+	if err != nil {
+		return false, err
+	}
+	switch kind {
+	case NumberInt:
+		if n == -1 {
+			// Avoid overflow on math.MinInt64 % -1.
+			return true, nil
+		}
+		return vi%n == 0, nil
+	case NumberUint:
+		if n < 0 {
+			n = -n
+		}
+		return vu%uint64(n) == 0, nil
+	default:
+		if vf != math.Trunc(vf) || math.IsInf(vf, 0) {
+			return false, nil
+		}
+		return math.Mod(vf, float64(n)) == 0, nil
+	}
+}
+
+// StringMatches returns whether the current string value matches the regular expression.
+// The string is not copied, so no allocations are made.
+// An error is returned if the value is not a string.
+func (i *Iter) StringMatches(re *regexp.Regexp) (bool, error) {
+	b, err := i.StringBytes()
+	if err != nil {
+		return false, err
+	}
+	return re.Match(b), nil
+}
+
 // SetFloat can change a float, int, uint or string with the specified value.
 // Attempting to change other types will return an error.
 func (i *Iter) SetFloat(v float64) error {
@@ -632,7 +1261,10 @@ func (i *Iter) Int() (int64, error) {
 		if i.off >= len(i.tape.Tape) {
 			return 0, errors.New("corrupt input: expected float, but no more values on tape")
 		}
-		v := math.Float64frombits(i.tape.Tape[i.off])
+		v, _, err := i.tape.floatAt(i.cur, i.tape.Tape[i.off])
+		if err != nil {
+			return 0, err
+		}
 		if v > math.MaxInt64 {
 			return 0, errors.New("float value overflows int64")
 		}
@@ -682,7 +1314,10 @@ func (i *Iter) Uint() (uint64, error) {
 		if i.off >= len(i.tape.Tape) {
 			return 0, errors.New("corrupt input: expected float, but no more values on tape")
 		}
-		v := math.Float64frombits(i.tape.Tape[i.off])
+		v, _, err := i.tape.floatAt(i.cur, i.tape.Tape[i.off])
+		if err != nil {
+			return 0, err
+		}
 		if v > math.MaxUint64 {
 			return 0, errors.New("float value overflows uint64")
 		}
@@ -748,37 +1383,270 @@ func (i *Iter) StringBytes() ([]byte, error) {
 	return i.tape.stringByteAt(i.cur, i.tape.Tape[i.off])
 }
 
-// SetString can change a string, int, uint or float with the specified string.
-// Attempting to change other types will return an error.
-func (i *Iter) SetString(v string) error {
-	return i.SetStringBytes([]byte(v))
-}
-
-// SetStringBytes can change a string, int, uint or float with the specified string.
-// Attempting to change other types will return an error.
-// Sending nil will add an empty string.
-func (i *Iter) SetStringBytes(v []byte) error {
-	switch i.t {
-	case TagString, TagFloat, TagInteger, TagUint:
-		i.cur = ((uint64(TagString) << JSONTAGOFFSET) | STRINGBUFBIT) | uint64(len(i.tape.Strings.B))
-		i.tape.Tape[i.off-1] = i.cur
-		i.tape.Tape[i.off] = uint64(len(v))
-		i.t = TagString
-		i.tape.Strings.B = append(i.tape.Strings.B, v...)
-		return nil
-	}
-	return fmt.Errorf("cannot set tag %s to string", i.t.String())
-}
-
-// StringCvt returns a string representation of the value.
-// Root, Object and Arrays are not supported.
-func (i *Iter) StringCvt() (string, error) {
+// Raw returns the JSON representation of the current value.
+// For strings this is the string as it appeared in the input including quotes and
+// any escape sequences, which may differ from the decoded value returned by String().
+// The exact source is available when strings were not copied or when parsed with WithPreserveEscapes,
+// in which case the returned slice references the input and should not be modified.
+// Otherwise the decoded string is escaped.
+// Floats parsed with WithLazyNumbers are returned as they appeared in the input.
+// Other scalar values are returned as if marshaled.
+// Objects and arrays are marshaled including any modifications,
+// and values following them are not included.
+func (i *Iter) Raw() ([]byte, error) {
 	switch i.t {
+	case TagObjectStart, TagArrayStart:
+		return i.rawContainer(nil)
 	case TagString:
-		return i.String()
 	case TagInteger:
 		v, err := i.Int()
-		return strconv.FormatInt(v, 10), err
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, v, 10), nil
+	case TagUint:
+		v, err := i.Uint()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendUint(nil, v, 10), nil
+	case TagFloat:
+		if i.cur&rawNumberBit != 0 && i.off < len(i.tape.Tape) {
+			return i.tape.rawNumberAt(i.cur, i.tape.Tape[i.off])
+		}
+		v, err := i.Float()
+		if err != nil {
+			return nil, err
+		}
+		return appendFloat(nil, v)
+	case TagNull:
+		return []byte("null"), nil
+	case TagBoolTrue:
+		return []byte("true"), nil
+	case TagBoolFalse:
+		return []byte("false"), nil
+	default:
+		return nil, fmt.Errorf("raw value of type %v not supported", i.t.Type())
+	}
+	if i.off >= len(i.tape.Tape) {
+		return nil, errors.New("corrupt input: no string offset on tape")
+	}
+	if b := i.tape.rawStringAt(i.cur, i.tape.Tape[i.off]); b != nil {
+		return b, nil
+	}
+	sb, err := i.StringBytes()
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, 0, len(sb)+2)
+	dst = append(dst, '"')
+	dst = escapeBytes(dst, sb)
+	return append(dst, '"'), nil
+}
+
+// NumberBytes returns the current number as it appeared in the input.
+// Floats are only available when parsed with WithLazyNumbers(true),
+// or WithBigNumbers(true) for integers that overflow 64 bits,
+// and the returned slice then references the input and should not be modified.
+// Integers have a single representation in JSON and are returned formatted,
+// except that -0 is returned as 0.
+// An error is returned if the value is not a number, or if the source of a float
+// was not recorded or the value has been modified.
+func (i *Iter) NumberBytes() ([]byte, error) {
+	switch i.t {
+	case TagInteger:
+		v, err := i.Int()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, v, 10), nil
+	case TagUint:
+		v, err := i.Uint()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendUint(nil, v, 10), nil
+	case TagFloat:
+		if i.off >= len(i.tape.Tape) {
+			return nil, errors.New("corrupt input: expected float, but no more values on tape")
+		}
+		if i.cur&rawNumberBit == 0 {
+			return nil, errors.New("number source not recorded, use WithLazyNumbers")
+		}
+		return i.tape.rawNumberAt(i.cur, i.tape.Tape[i.off])
+	}
+	return nil, errors.New("value is not a number")
+}
+
+// RawBuffer will append the JSON representation of the current value to dst.
+// Only the current value is written, so for objects and arrays
+// this is equivalent to calling AdvanceIter and marshaling the result.
+// See Raw for how values are represented.
+func (i *Iter) RawBuffer(dst []byte) ([]byte, error) {
+	switch i.t {
+	case TagObjectStart, TagArrayStart:
+		return i.rawContainer(dst)
+	}
+	b, err := i.Raw()
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, b...), nil
+}
+
+// rawContainer will append the marshaled object or array at the current position to dst.
+func (i *Iter) rawContainer(dst []byte) ([]byte, error) {
+	end := int(i.cur)
+	if end > len(i.tape.Tape) || end < i.off {
+		return dst, errors.New("corrupt input: object or array extends beyond tape")
+	}
+	cp := *i
+	// Restrict to the current value and move into it.
+	cp.tape.Tape = cp.tape.Tape[:end]
+	cp.calcNext(true)
+	return cp.marshalJSONBuffer(dst, defaultMarshalOptions())
+}
+
+// SpanBytes returns the number of bytes the current object or array occupies in the input,
+// including the braces or brackets.
+// This is cheaper than getting the source of the value when only the size is needed.
+// The input must have been parsed with WithSourceSpans(true).
+func (i *Iter) SpanBytes() (int, error) {
+	start, end, err := i.sourceSpan()
+	return end - start, err
+}
+
+// sourceSpan returns the location in the input of the current object or array.
+func (i *Iter) sourceSpan() (start, end int, err error) {
+	if i.t != TagObjectStart && i.t != TagArrayStart {
+		return 0, 0, fmt.Errorf("source span of type %v not supported", i.t.Type())
+	}
+	spans := i.tape.spans
+	if len(spans) == 0 {
+		return 0, 0, errors.New("source spans not recorded, use WithSourceSpans")
+	}
+	offset := uint64(i.off - 1)
+	idx := sort.Search(len(spans), func(n int) bool {
+		return spans[n].tapeOffset >= offset
+	})
+	if idx >= len(spans) || spans[idx].tapeOffset != offset {
+		return 0, 0, errors.New("source span not found")
+	}
+	s := spans[idx]
+	if s.srcEnd > uint64(len(i.tape.Message)) || s.srcStart >= s.srcEnd {
+		return 0, 0, errors.New("corrupt input: source span outside input")
+	}
+	return int(s.srcStart), int(s.srcEnd), nil
+}
+
+// rawStringAt returns the source of a string at a specific offset.
+// Returns nil if the source is unknown.
+func (pj *ParsedJson) rawStringAt(offset, length uint64) []byte {
+	if offset&STRINGBUFBIT == 0 {
+		if offset == 0 || offset+length >= uint64(len(pj.Message)) {
+			return nil
+		}
+		b := pj.Message[offset-1 : offset+length+1]
+		if b[0] != '"' || b[len(b)-1] != '"' {
+			return nil
+		}
+		return b
+	}
+	offset &= STRINGBUFMASK
+	idx := sort.Search(len(pj.rawStrings), func(i int) bool {
+		return pj.rawStrings[i].strOffset >= offset
+	})
+	if idx >= len(pj.rawStrings) || pj.rawStrings[idx].strOffset != offset {
+		return nil
+	}
+	r := pj.rawStrings[idx]
+	if r.srcEnd > uint64(len(pj.Message)) || r.srcStart >= r.srcEnd {
+		return nil
+	}
+	return pj.Message[r.srcStart:r.srcEnd]
+}
+
+// SetString can change a string, int, uint or float with the specified string.
+// Attempting to change other types will return an error.
+func (i *Iter) SetString(v string) error {
+	return i.SetStringBytes([]byte(v))
+}
+
+// SetStringBytes can change a string, int, uint or float with the specified string.
+// Attempting to change other types will return an error.
+// Sending nil will add an empty string.
+func (i *Iter) SetStringBytes(v []byte) error {
+	switch i.t {
+	case TagString, TagFloat, TagInteger, TagUint:
+		i.cur = ((uint64(TagString) << JSONTAGOFFSET) | STRINGBUFBIT) | uint64(len(i.tape.Strings.B))
+		i.tape.Tape[i.off-1] = i.cur
+		i.tape.Tape[i.off] = uint64(len(v))
+		i.t = TagString
+		i.tape.Strings.B = append(i.tape.Strings.B, v...)
+		return nil
+	}
+	return fmt.Errorf("cannot set tag %s to string", i.t.String())
+}
+
+// Time returns the string value parsed as a time using the layout.
+// If layout is empty, time.RFC3339 is used, which also accepts fractional seconds.
+func (i *Iter) Time(layout string) (time.Time, error) {
+	s, err := i.String()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return time.Parse(layout, s)
+}
+
+// SetTime can change a string, int, uint or float to the time formatted using the layout.
+// If layout is empty, time.RFC3339Nano is used.
+// Attempting to change other types will return an error.
+func (i *Iter) SetTime(t time.Time, layout string) error {
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	var tmp [64]byte
+	return i.SetStringBytes(t.AppendFormat(tmp[:0], layout))
+}
+
+// Bytes returns the string value decoded as standard base64.
+func (i *Iter) Bytes() ([]byte, error) {
+	s, err := i.StringBytes()
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+	n, err := base64.StdEncoding.Decode(dst, s)
+	return dst[:n], err
+}
+
+// SetBytes can change a string, int, uint or float to b encoded as standard base64.
+// Attempting to change other types will return an error.
+func (i *Iter) SetBytes(b []byte) error {
+	// Add an empty string and encode directly to the string buffer.
+	if err := i.SetStringBytes(nil); err != nil {
+		return err
+	}
+	start := len(i.tape.Strings.B)
+	n := base64.StdEncoding.EncodedLen(len(b))
+	i.tape.Strings.B = append(i.tape.Strings.B, make([]byte, n)...)
+	base64.StdEncoding.Encode(i.tape.Strings.B[start:], b)
+	i.tape.Tape[i.off] = uint64(n)
+	return nil
+}
+
+// StringCvt returns a string representation of the value.
+// Root, Object and Arrays are not supported.
+func (i *Iter) StringCvt() (string, error) {
+	switch i.t {
+	case TagString:
+		return i.String()
+	case TagInteger:
+		v, err := i.Int()
+		return strconv.FormatInt(v, 10), err
 	case TagUint:
 		v, err := i.Uint()
 		return strconv.FormatUint(v, 10), err
@@ -798,6 +1666,41 @@ func (i *Iter) StringCvt() (string, error) {
 	return "", fmt.Errorf("cannot convert type %s to string", TagToType[i.t])
 }
 
+// ScalarOrFirst returns the current value if it is a scalar,
+// or the element of an array containing a single scalar.
+// This can be used for values that may be encoded as either `"x"` or `["x"]`.
+// An error is returned for objects, empty arrays, arrays with more than one element
+// and arrays containing an object or array.
+// The iterator is not advanced.
+func (i *Iter) ScalarOrFirst() (Iter, error) {
+	switch i.t.Type() {
+	case TypeArray:
+		arr, err := i.Array(nil)
+		if err != nil {
+			return Iter{}, err
+		}
+		it := arr.Iter()
+		var elem Iter
+		t, err := it.AdvanceIter(&elem)
+		if err != nil {
+			return Iter{}, err
+		}
+		switch t {
+		case TypeNone:
+			return Iter{}, errors.New("array is empty")
+		case TypeObject, TypeArray:
+			return Iter{}, fmt.Errorf("array element is %v, not a scalar", t)
+		}
+		if it.Advance() != TypeNone {
+			return Iter{}, errors.New("array has more than one element")
+		}
+		return elem, nil
+	case TypeObject, TypeRoot, TypeNone:
+		return Iter{}, fmt.Errorf("value is %v, not a scalar or array", i.t.Type())
+	}
+	return *i, nil
+}
+
 // Root returns the object embedded in root as an iterator
 // along with the type of the content of the first element of the iterator.
 // An optional destination can be supplied to avoid allocations.
@@ -817,6 +1720,9 @@ func (i *Iter) Root(dst *Iter) (Type, *Iter, error) {
 		dst.t = i.t
 		dst.tape.Strings = i.tape.Strings
 		dst.tape.Message = i.tape.Message
+		dst.tape.rawStrings = i.tape.rawStrings
+		dst.tape.spans = i.tape.spans
+		dst.tape.owner = i.tape.owner
 	}
 	dst.addNext = 0
 	dst.tape.Tape = i.tape.Tape[:i.cur-1]
@@ -824,9 +1730,10 @@ func (i *Iter) Root(dst *Iter) (Type, *Iter, error) {
 }
 
 // FindElement allows searching for fields and objects by path from the iter and forward,
-// moving into root and objects, but not arrays.
+// moving into root and objects.
 // For example "Image", "Url" will search the current root/object for an "Image"
 // object and return the value of the "Url" element.
+// Arrays inside the object can be indexed as described in Object.FindPath.
 // ErrPathNotFound is returned if any part of the path cannot be found.
 // If the tape contains an error it will be returned.
 // The iter will *not* be advanced.
@@ -934,30 +1841,46 @@ func (i *Iter) SetNull() error {
 // Boolean values are returned as bool.
 // Null values are returned as nil.
 // Root objects are returned as []interface{}.
+// Nested objects and arrays are converted without recursion,
+// so the stack usage does not depend on the nesting depth of the input.
 func (i *Iter) Interface() (interface{}, error) {
+	return i.interfaceValue(false)
+}
+
+// InterfaceWithNumbers returns the value as an interface like Interface,
+// but numbers are returned as json.Number, like json.Decoder.UseNumber does.
+// Integers are returned exactly.
+// Floats parsed with WithLazyNumbers are returned as they appeared in the input,
+// other floats are returned as if marshaled.
+func (i *Iter) InterfaceWithNumbers() (interface{}, error) {
+	return i.interfaceValue(true)
+}
+
+// interfaceValue returns the value as an interface,
+// with numbers as json.Number if useNumber is set.
+func (i *Iter) interfaceValue(useNumber bool) (interface{}, error) {
 	switch i.t.Type() {
-	case TypeUint:
-		return i.Uint()
-	case TypeInt:
-		return i.Int()
-	case TypeFloat:
+	case TypeUint, TypeInt, TypeFloat:
+		if useNumber {
+			b, err := i.Raw()
+			if err != nil {
+				return nil, err
+			}
+			return json.Number(b), nil
+		}
+		switch i.t.Type() {
+		case TypeUint:
+			return i.Uint()
+		case TypeInt:
+			return i.Int()
+		}
 		return i.Float()
 	case TypeNull:
 		return nil, nil
-	case TypeArray:
-		arr, err := i.Array(nil)
-		if err != nil {
-			return nil, err
-		}
-		return arr.Interface()
+	case TypeArray, TypeObject:
+		return interfaceContainer(i, useNumber)
 	case TypeString:
 		return i.String()
-	case TypeObject:
-		obj, err := i.Object(nil)
-		if err != nil {
-			return nil, err
-		}
-		return obj.Map(nil)
 	case TypeBool:
 		return i.t == TagBoolTrue, nil
 	case TypeRoot:
@@ -971,7 +1894,7 @@ func (i *Iter) Interface() (interface{}, error) {
 			if typ == TypeNone {
 				break
 			}
-			elem, err := obj.Interface()
+			elem, err := obj.interfaceValue(useNumber)
 			if err != nil {
 				return nil, err
 			}
@@ -987,12 +1910,428 @@ func (i *Iter) Interface() (interface{}, error) {
 			return nil, errors.New("no content in iterator")
 		}
 		i.Advance()
-		return i.Interface()
+		return i.interfaceValue(useNumber)
 	default:
 	}
 	return nil, fmt.Errorf("unknown tag type: %v", i.t)
 }
 
+// interfaceFrame is an object or array being converted by interfaceContainer.
+type interfaceFrame struct {
+	// obj is set for objects and elems for arrays.
+	obj   *Object
+	elems Iter
+
+	m   map[string]interface{}
+	arr []interface{}
+	// key of the object member being converted.
+	key string
+}
+
+// interfaceContainer converts the object or array in i to an interface
+// using an explicit stack instead of recursion.
+func interfaceContainer(i *Iter, useNumber bool) (interface{}, error) {
+	var stack []interfaceFrame
+	var tmp Iter
+	cur := i
+	for {
+		var v interface{}
+		switch cur.t.Type() {
+		case TypeObject:
+			obj, err := cur.Object(nil)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, interfaceFrame{obj: obj, m: make(map[string]interface{})})
+		case TypeArray:
+			arr, err := cur.Array(nil)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, interfaceFrame{elems: arr.Iter(), arr: make([]interface{}, 0)})
+		default:
+			var err error
+			v, err = cur.interfaceValue(useNumber)
+			if err != nil {
+				if top := &stack[len(stack)-1]; top.obj != nil {
+					return nil, fmt.Errorf("parsing element %q: %w", top.key, err)
+				}
+				return nil, err
+			}
+			stack[len(stack)-1].add(v)
+		}
+
+		// Find the next value, finishing completed objects and arrays.
+		for cur = nil; cur == nil; {
+			top := &stack[len(stack)-1]
+			if top.obj != nil {
+				name, t, err := top.obj.NextElement(&tmp)
+				if err != nil {
+					return nil, err
+				}
+				if t != TypeNone {
+					top.key = name
+					cur = &tmp
+					break
+				}
+				v = top.m
+			} else {
+				if top.elems.Advance() != TypeNone {
+					tmp = top.elems
+					cur = &tmp
+					break
+				}
+				v = top.arr
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return v, nil
+			}
+			stack[len(stack)-1].add(v)
+		}
+	}
+}
+
+// add v to the object or array.
+func (f *interfaceFrame) add(v interface{}) {
+	if f.obj != nil {
+		f.m[f.key] = v
+		return
+	}
+	f.arr = append(f.arr, v)
+}
+
+// InterfaceScratch contains storage that can be reused between calls to Iter.InterfaceReuse.
+// The zero value is ready to use. An InterfaceScratch cannot be used concurrently.
+type InterfaceScratch struct {
+	// stack of values and keys of the arrays and objects being decoded.
+	values []interface{}
+	keys   []string
+
+	// interned object keys.
+	interned map[string]string
+
+	// chunks of storage for returned arrays.
+	chunks [][]interface{}
+	// current chunk and position within it.
+	chunk, pos int
+}
+
+// interfaceChunkSize is the minimum size of array storage chunks.
+const interfaceChunkSize = 1024
+
+// Limits for interning object keys.
+const (
+	interfaceMaxInterned   = 4096
+	interfaceMaxInternSize = 64
+)
+
+// key returns b as a string.
+// Object keys are interned, since they are often repeated.
+func (s *InterfaceScratch) key(b []byte) string {
+	if len(b) > interfaceMaxInternSize {
+		return string(b)
+	}
+	if k, ok := s.interned[string(b)]; ok {
+		return k
+	}
+	k := string(b)
+	if s.interned == nil {
+		s.interned = make(map[string]string, 64)
+	}
+	if len(s.interned) < interfaceMaxInterned {
+		s.interned[k] = k
+	}
+	return k
+}
+
+// reset the scratch, so array storage can be reused.
+func (s *InterfaceScratch) reset() {
+	for n, c := range s.chunks {
+		if n > s.chunk {
+			break
+		}
+		if n == s.chunk {
+			c = c[:s.pos]
+		}
+		for k := range c {
+			c[k] = nil
+		}
+	}
+	s.chunk, s.pos = 0, 0
+}
+
+// alloc returns a slice of n values from the array storage.
+func (s *InterfaceScratch) alloc(n int) []interface{} {
+	if n == 0 {
+		return make([]interface{}, 0)
+	}
+	for s.chunk < len(s.chunks) {
+		c := s.chunks[s.chunk]
+		if s.pos+n <= len(c) {
+			s.pos += n
+			return c[s.pos-n : s.pos : s.pos]
+		}
+		s.chunk++
+		s.pos = 0
+	}
+	size := interfaceChunkSize
+	if n > size {
+		size = n
+	}
+	s.chunks = append(s.chunks, make([]interface{}, size))
+	s.chunk = len(s.chunks) - 1
+	s.pos = n
+	return s.chunks[s.chunk][:n:n]
+}
+
+// InterfaceReuse returns the current value as an interface, like Interface.
+// Storage for arrays is taken from scratch, object keys are shared between calls
+// and maps are allocated with their final size,
+// which reduces allocations when converting many similar documents.
+// Arrays returned by a previous call using the same scratch are overwritten,
+// so values must no longer be used when scratch is reused.
+// If scratch is nil, this is the same as calling Interface.
+func (i *Iter) InterfaceReuse(scratch *InterfaceScratch) (interface{}, error) {
+	if scratch == nil {
+		return i.Interface()
+	}
+	scratch.reset()
+	return scratch.value(i)
+}
+
+func (s *InterfaceScratch) value(i *Iter) (interface{}, error) {
+	switch i.t.Type() {
+	case TypeArray:
+		var arr Array
+		if _, err := i.Array(&arr); err != nil {
+			return nil, err
+		}
+		start := len(s.values)
+		tmp := arr.Iter()
+		for tmp.Advance() != TypeNone {
+			elem, err := s.value(&tmp)
+			if err != nil {
+				return nil, err
+			}
+			s.values = append(s.values, elem)
+		}
+		dst := s.alloc(len(s.values) - start)
+		copy(dst, s.values[start:])
+		s.truncate(start, len(s.keys))
+		return dst, nil
+	case TypeObject:
+		var obj Object
+		if _, err := i.Object(&obj); err != nil {
+			return nil, err
+		}
+		start, startKeys := len(s.values), len(s.keys)
+		var tmp Iter
+		for {
+			nameB, t, err := obj.NextElementBytes(&tmp)
+			if err != nil {
+				return nil, err
+			}
+			if t == TypeNone {
+				break
+			}
+			name := s.key(nameB)
+			elem, err := s.value(&tmp)
+			if err != nil {
+				return nil, fmt.Errorf("parsing element %q: %w", name, err)
+			}
+			s.keys = append(s.keys, name)
+			s.values = append(s.values, elem)
+		}
+		dst := make(map[string]interface{}, len(s.keys)-startKeys)
+		for n, key := range s.keys[startKeys:] {
+			dst[key] = s.values[start+n]
+		}
+		s.truncate(start, startKeys)
+		return dst, nil
+	case TypeRoot:
+		start := len(s.values)
+		var tmp Iter
+		for {
+			typ, obj, err := i.Root(&tmp)
+			if err != nil {
+				return nil, err
+			}
+			if typ == TypeNone {
+				break
+			}
+			elem, err := s.value(obj)
+			if err != nil {
+				return nil, err
+			}
+			s.values = append(s.values, elem)
+			if i.Advance() != TypeRoot {
+				break
+			}
+		}
+		if len(s.values) == start {
+			return []interface{}(nil), nil
+		}
+		dst := s.alloc(len(s.values) - start)
+		copy(dst, s.values[start:])
+		s.truncate(start, len(s.keys))
+		return dst, nil
+	case TypeNone:
+		if i.PeekNextTag() == TagEnd {
+			return nil, errors.New("no content in iterator")
+		}
+		i.Advance()
+		return s.value(i)
+	}
+	return i.Interface()
+}
+
+// truncate the value and key stacks, releasing references.
+func (s *InterfaceScratch) truncate(values, keys int) {
+	for n := range s.values[values:] {
+		s.values[values+n] = nil
+	}
+	for n := range s.keys[keys:] {
+		s.keys[keys+n] = ""
+	}
+	s.values = s.values[:values]
+	s.keys = s.keys[:keys]
+}
+
+// ForEachScalar will call fn for every scalar value in the current value,
+// descending into objects and arrays.
+// The path contains the object keys and array indexes leading to the value,
+// separated by '/', for example "Image/IDs/0".
+// Scalars at the top level have an empty path.
+// The iterator will not be advanced.
+// If the callback returns a non-nil error iteration stops and the error is returned.
+func (i *Iter) ForEachScalar(fn func(path string, i Iter) error) error {
+	w := scalarWalker{fn: fn}
+	return w.walk(i)
+}
+
+// ForEachScalarValue will call fn for every scalar value in the current value,
+// descending into objects and arrays.
+// It works as ForEachScalar, but the path of each value is not tracked.
+func (i *Iter) ForEachScalarValue(fn func(i Iter) error) error {
+	w := scalarWalker{fnValue: fn}
+	return w.walk(i)
+}
+
+// CollectAtDepth will call fn for every value at the given nesting depth of the current value.
+// Depth 0 is the current value, depth 1 is the members of an object or the elements of an array, and so on.
+// Values at the depth are not descended into, so fn may receive objects and arrays.
+// Scalars above the depth are skipped. Root values do not count as a level.
+// The path is formatted as in ForEachScalar.
+// The iterator will not be advanced.
+// If the callback returns a non-nil error iteration stops and the error is returned.
+func (i *Iter) CollectAtDepth(depth int, fn func(path string, i Iter) error) error {
+	if depth < 0 {
+		return fmt.Errorf("invalid depth: %d", depth)
+	}
+	w := scalarWalker{fn: fn, atDepth: true, depth: depth}
+	return w.walk(i)
+}
+
+// scalarWalker descends into objects and arrays for ForEachScalar, ForEachScalarValue and CollectAtDepth.
+type scalarWalker struct {
+	fn      func(path string, i Iter) error
+	fnValue func(i Iter) error
+	path    []byte
+
+	// atDepth is set if only values at depth should be visited.
+	// depth is the number of levels remaining before the target depth.
+	atDepth bool
+	depth   int
+}
+
+func (w *scalarWalker) walk(i *Iter) error {
+	var tmp Iter
+	t := i.Type()
+	if w.atDepth && w.depth == 0 && t != TypeNone && t != TypeRoot {
+		return w.fn(string(w.path), *i)
+	}
+	switch t {
+	case TypeNone:
+		return nil
+	case TypeRoot:
+		_, root, err := i.Root(&tmp)
+		if err != nil {
+			return err
+		}
+		return w.walk(root)
+	case TypeObject:
+		obj, err := i.Object(nil)
+		if err != nil {
+			return err
+		}
+		if w.atDepth {
+			w.depth--
+			defer func() { w.depth++ }()
+		}
+		pathLen := len(w.path)
+		for {
+			name, t, err := obj.NextElementBytes(&tmp)
+			if err != nil {
+				return err
+			}
+			if t == TypeNone {
+				return nil
+			}
+			if w.fn != nil {
+				w.path = w.appendSep(w.path[:pathLen])
+				w.path = append(w.path, name...)
+			}
+			if err := w.walk(&tmp); err != nil {
+				return err
+			}
+		}
+	case TypeArray:
+		arr, err := i.Array(nil)
+		if err != nil {
+			return err
+		}
+		if w.atDepth {
+			w.depth--
+			defer func() { w.depth++ }()
+		}
+		pathLen := len(w.path)
+		elems := arr.Iter()
+		for n := 0; ; n++ {
+			t, err := elems.AdvanceIter(&tmp)
+			if err != nil {
+				return err
+			}
+			if t == TypeNone {
+				return nil
+			}
+			if w.fn != nil {
+				w.path = w.appendSep(w.path[:pathLen])
+				w.path = strconv.AppendInt(w.path, int64(n), 10)
+			}
+			if err := w.walk(&tmp); err != nil {
+				return err
+			}
+		}
+	}
+	if w.atDepth {
+		// Scalar above the target depth.
+		return nil
+	}
+	if w.fn != nil {
+		return w.fn(string(w.path), *i)
+	}
+	return w.fnValue(*i)
+}
+
+// appendSep adds a path separator unless dst is empty.
+func (w *scalarWalker) appendSep(dst []byte) []byte {
+	if len(dst) == 0 {
+		return dst
+	}
+	return append(dst, '/')
+}
+
 // Object will return the next element as an object.
 // An optional destination can be given.
 func (i *Iter) Object(dst *Object) (*Object, error) {
@@ -1012,6 +2351,9 @@ func (i *Iter) Object(dst *Object) (*Object, error) {
 	dst.tape.Tape = i.tape.Tape[:end]
 	dst.tape.Strings = i.tape.Strings
 	dst.tape.Message = i.tape.Message
+	dst.tape.rawStrings = i.tape.rawStrings
+	dst.tape.spans = i.tape.spans
+	dst.tape.owner = i.tape.owner
 	dst.off = i.off
 
 	return dst, nil
@@ -1033,6 +2375,9 @@ func (i *Iter) Array(dst *Array) (*Array, error) {
 	dst.tape.Tape = i.tape.Tape[:end]
 	dst.tape.Strings = i.tape.Strings
 	dst.tape.Message = i.tape.Message
+	dst.tape.rawStrings = i.tape.rawStrings
+	dst.tape.spans = i.tape.spans
+	dst.tape.owner = i.tape.owner
 	dst.off = i.off
 
 	return dst, nil
@@ -1042,6 +2387,63 @@ func (pj *ParsedJson) Reset() {
 	pj.Tape = pj.Tape[:0]
 	pj.Strings.B = pj.Strings.B[:0]
 	pj.Message = pj.Message[:0]
+	pj.rawStrings = pj.rawStrings[:0]
+	pj.spans = pj.spans[:0]
+	pj.warnings = pj.warnings[:0]
+}
+
+// Recycle prepares pj to be reused by another call to Parse or ParseND.
+// The tape and string buffers are truncated, keeping their capacity,
+// and references to the input and source spans are released,
+// so a recycled ParsedJson does not keep the input alive.
+// Internal buffers are kept, so the next parse can avoid setup cost.
+// Values, iterators, objects and arrays from pj must not be used after it has been recycled.
+func (pj *ParsedJson) Recycle() {
+	pj.Tape = pj.Tape[:0]
+	if pj.Strings != nil {
+		pj.Strings.B = pj.Strings.B[:0]
+	}
+	pj.Message = nil
+	if pj.internal != nil {
+		pj.internal.Message = nil
+	}
+	pj.rawStrings = pj.rawStrings[:0]
+	pj.spans = pj.spans[:0]
+	pj.warnings = pj.warnings[:0]
+	pj.owner = nil
+}
+
+// parsedJsonPool contains ParsedJson returned by PutParsedJson.
+var parsedJsonPool sync.Pool
+
+// GetParsedJson returns a ParsedJson that can be supplied as reuse to Parse or ParseND.
+// It is a previously recycled value returned by PutParsedJson, if any.
+// The pool can be used concurrently, but each ParsedJson must only be used by one goroutine at a time.
+// For example:
+//
+//	pj, err := simdjson.Parse(b, simdjson.GetParsedJson())
+//	if err != nil {
+//		return err
+//	}
+//	defer simdjson.PutParsedJson(pj)
+//
+// The value supplied as reuse must not be used after parsing, only the returned ParsedJson.
+func GetParsedJson() *ParsedJson {
+	if pj, ok := parsedJsonPool.Get().(*ParsedJson); ok {
+		return pj
+	}
+	return &ParsedJson{}
+}
+
+// PutParsedJson will recycle pj and return it to the pool used by GetParsedJson.
+// pj, and any values, iterators, objects and arrays from it, must not be used after it has been returned.
+// Strings returned as copies, like from String, are not affected.
+func PutParsedJson(pj *ParsedJson) {
+	if pj == nil {
+		return
+	}
+	pj.Recycle()
+	parsedJsonPool.Put(pj)
 }
 
 func (pj *ParsedJson) get_current_loc() uint64 {
@@ -1073,6 +2475,273 @@ func (pj *ParsedJson) annotate_previousloc(saved_loc uint64, val uint64) {
 	pj.Tape[saved_loc] |= val
 }
 
+// appendTapeRange will append the tape entries from start to end in src to the tape.
+// The range must contain complete values.
+// Strings are copied to the string buffer, floats are converted and nop entries are removed,
+// so the appended entries do not reference the message or strings of src.
+func (pj *ParsedJson) appendTapeRange(src *ParsedJson, start, end int) error {
+	if start < 0 || end > len(src.Tape) || start > end {
+		return errors.New("corrupt input: range outside tape")
+	}
+	var scopes []uint64
+	for off := start; off < end; off++ {
+		entry := src.Tape[off]
+		tag := Tag(entry >> JSONTAGOFFSET)
+		payload := entry & JSONVALUEMASK
+		switch tag {
+		case TagNop:
+			if payload == 0 {
+				return errors.New("invalid nop skip")
+			}
+			off += int(payload) - 1
+		case TagString, TagInteger, TagUint, TagFloat:
+			if off+1 >= end {
+				return fmt.Errorf("corrupt input: expected %v value, but no more values on tape", tag.Type())
+			}
+			val := src.Tape[off+1]
+			off++
+			switch tag {
+			case TagString:
+				b, err := src.stringByteAt(payload, val)
+				if err != nil {
+					return err
+				}
+				pj.write_tape(STRINGBUFBIT|uint64(len(pj.Strings.B)), byte(TagString))
+				pj.Tape = append(pj.Tape, val)
+				pj.Strings.B = append(pj.Strings.B, b...)
+			case TagFloat:
+				f, flags, err := src.floatAt(payload, val)
+				if err != nil {
+					return err
+				}
+				pj.writeTapeTagValFlags(uint64(TagFloat)<<JSONTAGOFFSET|uint64(flags), math.Float64bits(f))
+			default:
+				pj.writeTapeTagValFlags(entry, val)
+			}
+		case TagNull, TagBoolTrue, TagBoolFalse:
+			pj.Tape = append(pj.Tape, entry)
+		case TagObjectStart, TagArrayStart:
+			scopes = append(scopes, uint64(len(pj.Tape)))
+			pj.write_tape(0, byte(tag))
+		case TagObjectEnd, TagArrayEnd:
+			if len(scopes) == 0 {
+				return fmt.Errorf("corrupt input: unexpected %v", tag)
+			}
+			scope := scopes[len(scopes)-1]
+			scopes = scopes[:len(scopes)-1]
+			if tagOpenToClose[Tag(pj.Tape[scope]>>JSONTAGOFFSET)] != tag {
+				return fmt.Errorf("corrupt input: unexpected %v", tag)
+			}
+			pj.write_tape(scope, byte(tag))
+			pj.annotate_previousloc(scope, uint64(len(pj.Tape)))
+		default:
+			return fmt.Errorf("corrupt input: unexpected tag %v", tag)
+		}
+	}
+	if len(scopes) != 0 {
+		return errors.New("corrupt input: range ends inside value")
+	}
+	return nil
+}
+
+// ErrReplaceTooLarge is returned when a replacement value needs more tape entries than the value it replaces.
+// Larger values can be inserted with Iter.SetRawJSON, or by rebuilding the document, for example using FromInterface.
+var ErrReplaceTooLarge = errors.New("replacement value is larger than the replaced value")
+
+// valueSpan returns the tape range of the current value.
+func (i *Iter) valueSpan() (start, end int, err error) {
+	start = i.off - 1
+	switch i.t {
+	case TagString, TagInteger, TagUint, TagFloat:
+		end = i.off + 1
+	case TagNull, TagBoolTrue, TagBoolFalse:
+		end = i.off
+	case TagObjectStart, TagArrayStart:
+		end = int(i.cur)
+	default:
+		return 0, 0, fmt.Errorf("cannot replace tag %v", i.t)
+	}
+	if start < 0 || end > len(i.tape.Tape) || end <= start {
+		return 0, 0, errors.New("corrupt input: value outside tape")
+	}
+	return start, end, nil
+}
+
+// replaceValue will replace the value at Tape[start:end] with the value of v.
+// v may be from another ParsedJson. If v is a root, the content is used.
+// Strings are appended to the string buffer and unused entries are filled with nops.
+// ErrReplaceTooLarge is returned if the value does not fit.
+func (pj *ParsedJson) replaceValue(start, end int, v *Iter) error {
+	// Encode the value separately, so nothing is modified if it doesn't fit.
+	tmp, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+	if len(tmp.Tape) > end-start {
+		return fmt.Errorf("%w: need %d tape entries, have %d", ErrReplaceTooLarge, len(tmp.Tape), end-start)
+	}
+	pj.writeValue(start, end, tmp)
+	return nil
+}
+
+// encodeValue returns the value of v on a separate tape starting at offset 0.
+// If no value is queued in v, the next value is used. If v is a root, the content is used.
+func encodeValue(v *Iter) (*ParsedJson, error) {
+	it := *v
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() == TypeRoot {
+		_, root, err := it.Root(nil)
+		if err != nil {
+			return nil, err
+		}
+		it = *root
+	}
+	vStart, vEnd, err := it.valueSpan()
+	if err != nil {
+		return nil, err
+	}
+	tmp := &ParsedJson{Strings: &TStrings{}}
+	if err := tmp.appendTapeRange(&it.tape, vStart, vEnd); err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// encodeRawJSON parses the JSON value in b and returns it encoded like encodeValue.
+func encodeRawJSON(b []byte) (*ParsedJson, error) {
+	parsed, err := Parse(b, nil, WithStrictRFC8259(true))
+	if err != nil {
+		return nil, err
+	}
+	v := parsed.Iter()
+	return encodeValue(&v)
+}
+
+// writeValue will write the value encoded by encodeValue to Tape[start:end].
+// The value must fit, and unused entries are filled with nops.
+func (pj *ParsedJson) writeValue(start, end int, tmp *ParsedJson) {
+	strOff := uint64(len(pj.Strings.B))
+	pj.Strings.B = append(pj.Strings.B, tmp.Strings.B...)
+	for off := 0; off < len(tmp.Tape); off++ {
+		entry := tmp.Tape[off]
+		switch Tag(entry >> JSONTAGOFFSET) {
+		case TagString, TagInteger, TagUint, TagFloat:
+			if Tag(entry>>JSONTAGOFFSET) == TagString {
+				entry += strOff
+			}
+			pj.Tape[start+off] = entry
+			off++
+			entry = tmp.Tape[off]
+		case TagObjectStart, TagObjectEnd, TagArrayStart, TagArrayEnd:
+			entry += uint64(start)
+		}
+		pj.Tape[start+off] = entry
+	}
+	for j := start + len(tmp.Tape); j < end; j++ {
+		pj.Tape[j] = uint64(TagNop)<<JSONTAGOFFSET | uint64(end-j)
+	}
+}
+
+// spliceValue will replace the value at Tape[start:end] with the value encoded by encodeValue.
+// If the value doesn't fit, a new tape is allocated for the ParsedJson the iterator was created from,
+// with the value inserted and all following offsets adjusted.
+// The tape of pj is updated to the new tape, keeping the relative length.
+// Returns the number of entries the tape grew.
+func (pj *ParsedJson) spliceValue(start, end int, tmp *ParsedJson) (int, error) {
+	if len(tmp.Tape) <= end-start {
+		pj.writeValue(start, end, tmp)
+		return 0, nil
+	}
+	owner := pj.owner
+	if owner == nil {
+		return 0, fmt.Errorf("%w: iterator was not created by ParsedJson.Iter", ErrReplaceTooLarge)
+	}
+	if len(owner.Tape) < end || &owner.Tape[0] != &pj.Tape[0] {
+		return 0, errors.New("iterator is no longer valid, since the tape has grown")
+	}
+	delta := len(tmp.Tape) - (end - start)
+	tape := make([]uint64, 0, len(owner.Tape)+delta)
+	tape = append(tape, owner.Tape[:start]...)
+	tape = append(tape, tmp.Tape...)
+	tape = append(tape, owner.Tape[end:]...)
+
+	// Adjust all offsets pointing beyond the inserted value.
+	// Start entries point past their end, so containers ending at start
+	// are not adjusted when a value is inserted after their last element.
+	for off := 0; off < len(tape); off++ {
+		if off == start {
+			// Written below.
+			off += len(tmp.Tape) - 1
+			continue
+		}
+		entry := tape[off]
+		val := int(entry & JSONVALUEMASK)
+		orig := off
+		if off > start {
+			orig -= delta
+		}
+		switch Tag(entry >> JSONTAGOFFSET) {
+		case TagString, TagInteger, TagUint, TagFloat:
+			off++
+		case TagObjectStart, TagObjectEnd, TagArrayStart, TagArrayEnd, TagRoot:
+			if (val > orig && val > start) || (val < orig && val >= end) {
+				tape[off] = entry + uint64(delta)
+			}
+		}
+	}
+	if owner.spans != nil {
+		spans := make([]containerSpan, 0, len(owner.spans))
+		for _, span := range owner.spans {
+			switch {
+			case int(span.tapeOffset) < start:
+			case int(span.tapeOffset) < end:
+				// Replaced.
+				continue
+			default:
+				span.tapeOffset += uint64(delta)
+			}
+			spans = append(spans, span)
+		}
+		owner.spans = spans
+	}
+	owner.Tape = tape
+	owner.writeValue(start, start+len(tmp.Tape), tmp)
+	pj.Tape = tape[:len(pj.Tape)+delta]
+	pj.spans = owner.spans
+	return delta, nil
+}
+
+// SetRawJSON will replace the current value with the JSON value in b.
+// Any JSON value is accepted, so scalars can be replaced with objects and the other way around.
+// If the new value needs more tape entries than the current value, the tape of the ParsedJson
+// the iterator was created from is reallocated and the value is inserted.
+// In that case only i and the ParsedJson are updated, so other iterators,
+// objects and arrays from the same ParsedJson must be recreated before they are used.
+// Modifications made through them will not be visible in the ParsedJson.
+// If the value is smaller, unused entries are filled with nops.
+// The iterator will be positioned on the new value.
+func (i *Iter) SetRawJSON(b []byte) error {
+	start, end, err := i.valueSpan()
+	if err != nil {
+		return err
+	}
+	tmp, err := encodeRawJSON(b)
+	if err != nil {
+		return err
+	}
+	if _, err := i.tape.spliceValue(start, end, tmp); err != nil {
+		return err
+	}
+	entry := i.tape.Tape[start]
+	i.t = Tag(entry >> JSONTAGOFFSET)
+	i.cur = entry & JSONVALUEMASK
+	i.off = start + 1
+	i.calcNext(false)
+	return nil
+}
+
 // Tag indicates the data type of a tape entry
 type Tag uint8
 
@@ -1246,6 +2915,32 @@ func floatToString(f float64) (string, error) {
 	return string(v), err
 }
 
+// appendIntOpts will append an integer using the marshal options.
+func appendIntOpts(dst []byte, v int64, opts marshalOptions) []byte {
+	if opts.largeIntsAsStrings {
+		abs := uint64(v)
+		if v < 0 {
+			abs = -abs
+		}
+		if abs > opts.largeIntThreshold {
+			dst = append(dst, '"')
+			dst = strconv.AppendInt(dst, v, 10)
+			return append(dst, '"')
+		}
+	}
+	return strconv.AppendInt(dst, v, 10)
+}
+
+// appendUintOpts will append an unsigned integer using the marshal options.
+func appendUintOpts(dst []byte, v uint64, opts marshalOptions) []byte {
+	if opts.largeIntsAsStrings && v > opts.largeIntThreshold {
+		dst = append(dst, '"')
+		dst = strconv.AppendUint(dst, v, 10)
+		return append(dst, '"')
+	}
+	return strconv.AppendUint(dst, v, 10)
+}
+
 // appendFloat converts a float to string similar to Go stdlib and appends it to dst.
 func appendFloat(dst []byte, f float64) ([]byte, error) {
 	if math.IsInf(f, 0) || math.IsNaN(f) {
@@ -1270,3 +2965,20 @@ func appendFloat(dst []byte, f float64) ([]byte, error) {
 	}
 	return dst, nil
 }
+
+// appendFloatOpts will append a float like appendFloat, using the marshal options.
+// When RenderIntegralFloatsAsInt is disabled, values without a fraction or exponent get a ".0" suffix.
+func appendFloatOpts(dst []byte, f float64, opts marshalOptions) ([]byte, error) {
+	start := len(dst)
+	dst, err := appendFloat(dst, f)
+	if err != nil || opts.integralFloatsAsInt {
+		return dst, err
+	}
+	// Exponent or fraction means it will not be read as an integer.
+	for _, c := range dst[start:] {
+		if c == '.' || c == 'e' {
+			return dst, nil
+		}
+	}
+	return append(dst, '.', '0'), nil
+}
@@ -19,10 +19,15 @@ package simdjson
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -133,6 +138,124 @@ func BenchmarkIter_MarshalJSONBuffer(b *testing.B) {
 	}
 }
 
+// walkIter visits all values below i using AdvanceIter and NextElement,
+// which copy an Iter for every value.
+func walkIter(i *Iter) (n int, err error) {
+	var elem Iter
+	for {
+		typ, err := i.AdvanceIter(&elem)
+		if err != nil || typ == TypeNone {
+			return n, err
+		}
+		got, err := walkValue(&elem, typ)
+		n += got
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// walkValue visits the value in i and all values below it.
+func walkValue(i *Iter, typ Type) (n int, err error) {
+	n = 1
+	switch typ {
+	case TypeRoot:
+		typ, root, err := i.Root(nil)
+		if err != nil {
+			return n, err
+		}
+		got, err := walkValue(root, typ)
+		return n + got, err
+	case TypeObject:
+		var obj Object
+		if _, err := i.Object(&obj); err != nil {
+			return n, err
+		}
+		var value Iter
+		for {
+			_, t, err := obj.NextElement(&value)
+			if err != nil || t == TypeNone {
+				return n, err
+			}
+			got, err := walkValue(&value, t)
+			n += got
+			if err != nil {
+				return n, err
+			}
+		}
+	case TypeArray:
+		var arr Array
+		if _, err := i.Array(&arr); err != nil {
+			return n, err
+		}
+		ai := arr.Iter()
+		got, err := walkIter(&ai)
+		return n + got, err
+	}
+	return n, nil
+}
+
+func BenchmarkIter_Walk(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	for _, tt := range testCases {
+		b.Run(tt.name, func(b *testing.B) {
+			ref := loadCompressed(b, tt.name)
+			pj, err := Parse(ref, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(ref)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				iter := pj.Iter()
+				if _, err := walkIter(&iter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkIter_Interface(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	ref := loadCompressed(b, "github_events")
+	pj, err := Parse(ref, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	iter := pj.Iter()
+	b.Run("new", func(b *testing.B) {
+		b.SetBytes(int64(len(ref)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cpy := iter
+			_, err := cpy.Interface()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reuse", func(b *testing.B) {
+		var scratch InterfaceScratch
+		b.SetBytes(int64(len(ref)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cpy := iter
+			_, err := cpy.InterfaceReuse(&scratch)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func BenchmarkGoMarshalJSON(b *testing.B) {
 	for _, tt := range testCases {
 		b.Run(tt.name, func(b *testing.B) {
@@ -339,6 +462,130 @@ func TestIter_SetNull(t *testing.T) {
 	}
 }
 
+func TestIter_SetRawJSON(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":1,"b":{"c":[1,2]},"d":"x"}` + "\n" + `{"e":[true]}`
+	tests := []struct {
+		pointer, raw, want string
+	}{
+		{pointer: "/a", raw: `{"new":[1,2,{"x":"y"}]}`, want: `{"a":{"new":[1,2,{"x":"y"}]},"b":{"c":[1,2]},"d":"x"}`},
+		{pointer: "/b", raw: `5`, want: `{"a":1,"b":5,"d":"x"}`},
+		{pointer: "/b", raw: ` "string" `, want: `{"a":1,"b":"string","d":"x"}`},
+		{pointer: "/b/c/0", raw: `[0,[1,{}]]`, want: `{"a":1,"b":{"c":[[0,[1,{}]],2]},"d":"x"}`},
+		{pointer: "/b/c/1", raw: `{"z":2.5}`, want: `{"a":1,"b":{"c":[1,{"z":2.5}]},"d":"x"}`},
+		{pointer: "/b/c", raw: `[]`, want: `{"a":1,"b":{"c":[]},"d":"x"}`},
+		{pointer: "/d", raw: `null`, want: `{"a":1,"b":{"c":[1,2]},"d":null}`},
+		{pointer: "/d", raw: `["x","y"]`, want: `{"a":1,"b":{"c":[1,2]},"d":["x","y"]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.pointer+test.raw, func(t *testing.T) {
+			pj, err := ParseND([]byte(input), nil, WithSourceSpans(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := pj.Iter()
+			var elem Element
+			if _, err := root.FindPointer(&elem, test.pointer); err != nil {
+				t.Fatal(err)
+			}
+			old := elem.Iter
+			tapeLen := len(pj.Tape)
+			if err := elem.Iter.SetRawJSON([]byte(test.raw)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := elem.Iter.Raw()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.TrimSpace(test.raw); string(got) != want {
+				t.Errorf("iterator: want %s, got %s", want, got)
+			}
+			want := test.want + "\n" + `{"e":[true]}`
+			root = pj.Iter()
+			out, err := root.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != want {
+				t.Errorf("want %s, got %s", want, out)
+			}
+
+			// Source spans of following values are kept.
+			root = pj.Iter()
+			root.Advance()
+			root.Advance()
+			_, second, err := root.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := second.SpanBytes(); err != nil || n != len(`{"e":[true]}`) {
+				t.Errorf("span: want %d, got %d, %v", len(`{"e":[true]}`), n, err)
+			}
+
+			// Serialized output is the same.
+			ser := NewSerializer()
+			pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root = pj2.Iter()
+			out, err = root.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != want {
+				t.Errorf("serialized: want %s, got %s", want, out)
+			}
+
+			// Growing through an iterator from before the tape grew fails.
+			if len(pj.Tape) > tapeLen {
+				if err := old.SetRawJSON([]byte(`[1,2,3,4,5,6,7,8,9]`)); err == nil {
+					t.Error("want error growing through stale iterator")
+				}
+			}
+		})
+	}
+
+	// Replace values while iterating.
+	pj, err := Parse([]byte(`[1,"two",[3],{"four":4}]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := pj.Iter()
+	root.Advance()
+	_, content, err := root.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := content.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := arr.Iter()
+	for n := 0; iter.Advance() != TypeNone; n++ {
+		if err := iter.SetRawJSON([]byte(fmt.Sprintf(`{"v":[%d,%d]}`, n, n))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root = pj.Iter()
+	out, err := root.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `[{"v":[0,0]},{"v":[1,1]},{"v":[2,2]},{"v":[3,3]}]`
+	if string(out) != want {
+		t.Errorf("want %s, got %s", want, out)
+	}
+	if err := iter.SetRawJSON([]byte(`1`)); err == nil {
+		t.Error("want error at end of array")
+	}
+	if err := content.SetRawJSON([]byte(`{`)); err == nil {
+		t.Error("want error for invalid JSON")
+	}
+}
+
 func TestIter_SetNull_ObjArr(t *testing.T) {
 	if !SupportedCPU() {
 		t.SkipNow()
@@ -589,6 +836,81 @@ func TestObject_DeleteElems(t *testing.T) {
 	}
 }
 
+func TestArray_Aggregate(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input            string
+		sum, min, max    float64
+		floatErr         bool
+		sumInt           int64
+		overflow, intErr bool
+	}{
+		{input: `[]`, floatErr: true},
+		{input: `[1,2,3]`, sum: 6, min: 1, max: 3, sumInt: 6},
+		{input: `[-1.5,2,18446744073709551615]`, sum: 18446744073709551615 + 0.5, min: -1.5, max: 18446744073709551615, intErr: true},
+		{input: `[5,-10,2]`, sum: -3, min: -10, max: 5, sumInt: -3},
+		{input: `[9223372036854775807,1]`, sum: 9223372036854775808, min: 1, max: 9223372036854775807, overflow: true},
+		{input: `[-9223372036854775808,-1]`, sum: -9223372036854775809, min: -9223372036854775808, max: -1, overflow: true},
+		{input: `[-9223372036854775808,18446744073709551615]`, sum: 9223372036854775807, min: -9223372036854775808, max: 18446744073709551615, sumInt: 9223372036854775807},
+		{input: `[-10,9223372036854775808]`, sum: 9223372036854775798, min: -10, max: 9223372036854775808, sumInt: 9223372036854775798},
+		{input: `[1,"2"]`, floatErr: true, intErr: true},
+		{input: `[1,[2]]`, floatErr: true, intErr: true},
+		{input: `[null]`, floatErr: true, intErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			pj, err := Parse([]byte(test.input), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := pj.Iter()
+			iter.AdvanceInto()
+			_, root, err := iter.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			arr, err := root.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum, err := arr.SumFloat()
+			if test.floatErr && test.input != `[]` {
+				if err == nil {
+					t.Error("SumFloat: want error")
+				}
+			} else if err != nil || sum != test.sum {
+				t.Errorf("SumFloat: want %v, got %v (err: %v)", test.sum, sum, err)
+			}
+			min, max, err := arr.MinMaxFloat()
+			if test.floatErr {
+				if err == nil {
+					t.Error("MinMaxFloat: want error")
+				}
+			} else if err != nil || min != test.min || max != test.max {
+				t.Errorf("MinMaxFloat: want %v, %v, got %v, %v (err: %v)", test.min, test.max, min, max, err)
+			}
+			sumInt, overflow, err := arr.SumInt()
+			if test.intErr {
+				if err == nil {
+					t.Error("SumInt: want error")
+				}
+			} else if err != nil || sumInt != test.sumInt || overflow != test.overflow {
+				t.Errorf("SumInt: want %v, %v, got %v, %v (err: %v)", test.sumInt, test.overflow, sumInt, overflow, err)
+			}
+
+			// The array is not advanced.
+			if arr.FirstType() == TypeNone {
+				return
+			}
+			if got, err := arr.MarshalJSON(); err != nil || string(got) != test.input {
+				t.Errorf("array was modified: %s (err: %v)", got, err)
+			}
+		})
+	}
+}
+
 func TestArray_DeleteElements(t *testing.T) {
 	if !SupportedCPU() {
 		t.SkipNow()
@@ -665,6 +987,15 @@ func TestArray_DeleteElements(t *testing.T) {
 				idx++
 				return del
 			})
+			wantLen := 8
+			if test.del < 0 {
+				wantLen = 0
+			} else if test.del >= idx {
+				wantLen = idx
+			}
+			if got := arr.Len(); got != wantLen {
+				t.Errorf("want length %d, got %d", wantLen, got)
+			}
 
 			out, err := root.MarshalJSON()
 			if err != nil {
@@ -701,108 +1032,677 @@ func TestArray_DeleteElements(t *testing.T) {
 	}
 }
 
-func TestIter_SetBool(t *testing.T) {
+func TestArray_DeleteElemsAdvance(t *testing.T) {
 	if !SupportedCPU() {
 		t.SkipNow()
 	}
-	input := `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[null,true,false,"astring",-42,9223372036854775808,1.23455]}`
+	pj, err := Parse([]byte(`[1,"two",3,{"a":4},5,[6]]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr.DeleteElems(func(i Iter) bool { return i.Type() != TypeInt })
+	want := []int64{1, 3, 5}
+
+	// Deleted elements must be skipped by both Advance and AdvanceIter.
+	var got []int64
+	it := arr.Iter()
+	for it.Advance() != TypeNone {
+		v, err := it.Int()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Advance: want %v, got %v", want, got)
+	}
+	got = got[:0]
+	it = arr.Iter()
+	var dst Iter
+	for {
+		typ, err := it.AdvanceIter(&dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ == TypeNone {
+			break
+		}
+		v, err := dst.Int()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AdvanceIter: want %v, got %v", want, got)
+	}
+}
+
+func TestArray_Len(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := map[string]int{
+		`[]`:                                  0,
+		`[ ]`:                                 0,
+		`[1]`:                                 1,
+		`["a", "b", null]`:                    3,
+		`[[], {}]`:                            2,
+		`[{"a": [1, 2, 3]}, [[1], [2, [3]]]]`: 2,
+		`[1, 2.5, "x", {"k": {"n": [4, 5]}}, [6, 7], true, false, null]`: 8,
+	}
+	for input, want := range tests {
+		pj, err := Parse([]byte(input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		i.AdvanceInto()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arr, err := root.Array(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := arr.Len(); got != want {
+			t.Errorf("%s: want %d, got %d", input, want, got)
+		}
+		if got, err := arr.Interface(); err != nil || len(got) != want {
+			t.Errorf("%s: Interface returned %d elements, err %v", input, len(got), err)
+		}
+	}
+}
+
+func TestArray_Index(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := `[-122.42, 37.77, {"a": [1, 2, {"b": 3}]}, [[4], 5], "x", null]`
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`-122.42`, `37.77`, `{"a":[1,2,{"b":3}]}`, `[[4],5]`, `"x"`, `null`}
+	var elem Iter
+	for n := len(want) - 1; n >= 0; n-- {
+		typ, err := arr.Index(n, &elem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != elem.Type() {
+			t.Errorf("%d: want type %v, got %v", n, elem.Type(), typ)
+		}
+		got, err := elem.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want[n] {
+			t.Errorf("%d: want %s, got %s", n, want[n], got)
+		}
+	}
+	for _, n := range []int{-1, len(want), 1000} {
+		if typ, err := arr.Index(n, &elem); err == nil || typ != TypeNone {
+			t.Errorf("%d: want error, got %v", n, typ)
+		}
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := arr.Index(4, &elem); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("want no allocations, got %v", allocs)
+	}
+}
+
+func TestArray_ReplaceAt(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `[1, 2.02, "33333", false, {"key": "value", "n": [1,2]}, [1,2,2,3], null, -42]`
 	tests := []struct {
-		setTo bool
-		want  string
+		index   int
+		value   string
+		want    string
+		wantErr error
 	}{
-		{
-			setTo: true,
-			want:  `{"0val":{"true":true,"false":true,"nullval":true},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[true,true,true,"astring",-42,9223372036854775808,1.23455]}`,
-		},
-		{
-			setTo: false,
-			want:  `{"0val":{"true":false,"false":false,"nullval":false},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[false,false,false,"astring",-42,9223372036854775808,1.23455]}`,
-		},
+		{index: 0, value: `7`, want: `[7,2.02,"33333",false,{"key":"value","n":[1,2]},[1,2,2,3],null,-42]`},
+		{index: 1, value: `"new"`, want: `[1,"new","33333",false,{"key":"value","n":[1,2]},[1,2,2,3],null,-42]`},
+		{index: 2, value: `true`, want: `[1,2.02,true,false,{"key":"value","n":[1,2]},[1,2,2,3],null,-42]`},
+		{index: 4, value: `{"a":["b"]}`, want: `[1,2.02,"33333",false,{"a":["b"]},[1,2,2,3],null,-42]`},
+		{index: 4, value: `[]`, want: `[1,2.02,"33333",false,[],[1,2,2,3],null,-42]`},
+		{index: 5, value: `{"x":1.5}`, want: `[1,2.02,"33333",false,{"key":"value","n":[1,2]},{"x":1.5},null,-42]`},
+		{index: 7, value: `null`, want: `[1,2.02,"33333",false,{"key":"value","n":[1,2]},[1,2,2,3],null,null]`},
+		{index: 3, value: `"too large"`, wantErr: ErrReplaceTooLarge},
+		{index: 6, value: `{}`, wantErr: ErrReplaceTooLarge},
+		{index: 8, value: `1`, wantErr: errors.New("out of range")},
 	}
 
-	for _, test := range tests {
-		t.Run(fmt.Sprint(test.setTo), func(t *testing.T) {
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
 			pj, err := Parse([]byte(input), nil)
 			if err != nil {
-				t.Errorf("parseMessage failed\n")
-				return
+				t.Fatal(err)
 			}
-			root := pj.Iter()
-			// Queue root
-			root.AdvanceInto()
+			// Use the element of an array, so scalars can be used.
+			value, err := Parse([]byte("["+test.value+"]"), nil)
 			if err != nil {
-				t.Errorf("root failed: %v", err)
-				return
+				t.Fatal(err)
 			}
-			iter := root
-			for {
-				typ := iter.Type()
-				switch typ {
-				case TypeBool, TypeNull:
-					//t.Logf("setting to %v", test.setTo)
-					err := iter.SetBool(test.setTo)
-					if err != nil {
-						t.Errorf("Unable to set value: %v", err)
-					}
-					val, err := iter.Bool()
-					if err != nil {
-						t.Errorf("Unable to retrieve value: %v", err)
-					}
-
-					if val != test.setTo {
-						t.Errorf("Want value %v, got %v", test.setTo, val)
-					}
-				default:
-					err := iter.SetBool(test.setTo)
-					if err == nil {
-						t.Errorf("Value should not be settable for type %v", typ)
-					}
+			vi := value.Iter()
+			vi.AdvanceInto()
+			vi.AdvanceInto()
+			vi.AdvanceInto()
+			iter := pj.Iter()
+			iter.AdvanceInto()
+			_, root, err := iter.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			arr, err := root.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = arr.ReplaceAt(test.index, &vi)
+			if test.wantErr != nil {
+				if err == nil || !errors.Is(err, test.wantErr) && !strings.Contains(err.Error(), test.wantErr.Error()) {
+					t.Fatalf("want error %v, got %v", test.wantErr, err)
 				}
-				if iter.PeekNextTag() == TagEnd {
-					break
+				out, err := root.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
 				}
-				iter.AdvanceInto()
+				if want := `[1,2.02,"33333",false,{"key":"value","n":[1,2]},[1,2,2,3],null,-42]`; string(out) != want {
+					t.Errorf("modified on error: %s", out)
+				}
+				return
 			}
-			out, err := root.MarshalJSON()
 			if err != nil {
 				t.Fatal(err)
 			}
-			if string(out) != test.want {
-				t.Errorf("want: %s\n got: %s", test.want, string(out))
+			check := func(pj *ParsedJson) {
+				t.Helper()
+				iter := pj.Iter()
+				out, err := iter.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(out) != test.want {
+					t.Errorf("want: %s\n got: %s", test.want, string(out))
+				}
+				// Check iteration over the padding.
+				iter = pj.Iter()
+				got, err := iter.Interface()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var want interface{}
+				if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+					t.Fatal(err)
+				}
+				if fmt.Sprint(got) != fmt.Sprint([]interface{}{want}) {
+					t.Errorf("interface want: %v\n got: %v", want, got)
+				}
 			}
+			check(pj)
 			ser := NewSerializer()
 			pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
 			if err != nil {
 				t.Fatal(err)
 			}
-			iter2 := pj2.Iter()
-			out2, err := iter2.MarshalJSON()
+			check(pj2)
+		})
+	}
+}
+
+func TestArray_Append(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":[1,[2,[]],{"b":[]}],"c":[3]}` + "\n" + `[4]`
+	tests := []struct {
+		pointer, raw, want string
+	}{
+		{pointer: "/a", raw: `5`, want: `{"a":[1,[2,[]],{"b":[]},5],"c":[3]}`},
+		{pointer: "/a", raw: `{"x":["y"]}`, want: `{"a":[1,[2,[]],{"b":[]},{"x":["y"]}],"c":[3]}`},
+		{pointer: "/a/1", raw: `"s"`, want: `{"a":[1,[2,[],"s"],{"b":[]}],"c":[3]}`},
+		{pointer: "/a/1/1", raw: `[null]`, want: `{"a":[1,[2,[[null]]],{"b":[]}],"c":[3]}`},
+		{pointer: "/a/2/b", raw: `true`, want: `{"a":[1,[2,[]],{"b":[true]}],"c":[3]}`},
+		{pointer: "/c", raw: `-1.5`, want: `{"a":[1,[2,[]],{"b":[]}],"c":[3,-1.5]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.pointer+test.raw, func(t *testing.T) {
+			pj, err := ParseND([]byte(input), nil)
 			if err != nil {
 				t.Fatal(err)
 			}
-			if !bytes.Equal(out, out2) {
-				t.Errorf("roundtrip mismatch: %s != %s", out, out2)
+			root := pj.Iter()
+			var elem Element
+			if _, err := root.FindPointer(&elem, test.pointer); err != nil {
+				t.Fatal(err)
+			}
+			arr, err := elem.Iter.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := arr.Len()
+			if err := arr.Append([]byte(test.raw)); err != nil {
+				t.Fatal(err)
+			}
+			// The array is updated.
+			if got := arr.Len(); got != n+1 {
+				t.Errorf("want length %d, got %d", n+1, got)
+			}
+			var last Iter
+			if _, err := arr.Index(n, &last); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := last.Raw(); err != nil || string(got) != test.raw {
+				t.Errorf("last: want %s, got %s (err: %v)", test.raw, got, err)
+			}
+
+			want := test.want + "\n" + `[4]`
+			ser := NewSerializer()
+			pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pj := range []*ParsedJson{pj, pj2} {
+				root = pj.Iter()
+				out, err := root.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(out) != want {
+					t.Errorf("want %s, got %s", want, out)
+				}
 			}
 		})
 	}
 }
 
-func TestIter_SetFloat(t *testing.T) {
+func TestArray_TypedIter(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	arrayOf := func(t *testing.T, input string, opts ...ParserOption) *Array {
+		t.Helper()
+		pj, err := Parse([]byte(input), nil, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		i.AdvanceInto()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arr, err := root.Array(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return arr
+	}
+	floats := func(it FloatIter) (got []float64, err error) {
+		for v, ok := it.Next(); ok; v, ok = it.Next() {
+			got = append(got, v)
+		}
+		return got, it.Err()
+	}
+	ints := func(it IntIter) (got []int64, err error) {
+		for v, ok := it.Next(); ok; v, ok = it.Next() {
+			got = append(got, v)
+		}
+		return got, it.Err()
+	}
+
+	const numbers = `[1.5, -2, 3e2, 18446744073709551615, 0.25]`
+	for _, lazy := range []bool{false, true} {
+		arr := arrayOf(t, numbers, WithLazyNumbers(lazy))
+		got, err := floats(arr.Floats())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []float64{1.5, -2, 300, 18446744073709551615, 0.25}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("lazy=%v: want %v, got %v", lazy, want, got)
+		}
+		// The array must not be advanced.
+		want, err = arr.AsFloat()
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("lazy=%v: AsFloat: want %v, got %v (err: %v)", lazy, want, got, err)
+		}
+	}
+
+	arr := arrayOf(t, `[1, -2, 3.0, 4]`)
+	gotInts, err := ints(arr.Ints())
+	if err != nil || !reflect.DeepEqual(gotInts, []int64{1, -2, 3, 4}) {
+		t.Errorf("ints: got %v (err: %v)", gotInts, err)
+	}
+
+	arr = arrayOf(t, `["a", "b\"c", "", "d"]`)
+	var gotStrings []string
+	it := arr.Strings()
+	for v, ok := it.Next(); ok; v, ok = it.Next() {
+		gotStrings = append(gotStrings, v)
+	}
+	if err := it.Err(); err != nil || !reflect.DeepEqual(gotStrings, []string{"a", `b"c`, "", "d"}) {
+		t.Errorf("strings: got %q (err: %v)", gotStrings, err)
+	}
+
+	// Deleted elements are skipped.
+	arr = arrayOf(t, `[1, [2, 3], 4, {"a": 5}, 6]`)
+	arr.DeleteElems(func(i Iter) bool {
+		return i.Type() == TypeArray || i.Type() == TypeObject
+	})
+	gotInts, err = ints(arr.Ints())
+	if err != nil || !reflect.DeepEqual(gotInts, []int64{1, 4, 6}) {
+		t.Errorf("deleted: got %v (err: %v)", gotInts, err)
+	}
+
+	// Empty arrays have no values.
+	gotInts, err = ints(arrayOf(t, `[]`).Ints())
+	if err != nil || len(gotInts) != 0 {
+		t.Errorf("empty: got %v (err: %v)", gotInts, err)
+	}
+
+	// Mismatched types stop the iteration with an error.
+	gotFloats, err := floats(arrayOf(t, `[1.5, 2, "3", 4]`).Floats())
+	if err == nil || !reflect.DeepEqual(gotFloats, []float64{1.5, 2}) {
+		t.Errorf("mismatch: got %v (err: %v)", gotFloats, err)
+	}
+	gotInts, err = ints(arrayOf(t, `[1, 18446744073709551615]`).Ints())
+	if err == nil || !reflect.DeepEqual(gotInts, []int64{1}) {
+		t.Errorf("overflow: got %v (err: %v)", gotInts, err)
+	}
+	it = arrayOf(t, `["a", null]`).Strings()
+	if v, ok := it.Next(); !ok || v != "a" {
+		t.Errorf("want a, got %q", v)
+	}
+	if _, ok := it.Next(); ok || it.Err() == nil {
+		t.Errorf("want error, got %v", it.Err())
+	}
+}
+
+func BenchmarkArray_Floats(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	// sum adds all numbers in arrays in the value of i.
+	var sum func(i *Iter, typed bool) (float64, error)
+	sum = func(i *Iter, typed bool) (total float64, err error) {
+		var elems Iter
+		switch i.Type() {
+		case TypeObject:
+			obj, err := i.Object(nil)
+			if err != nil {
+				return 0, err
+			}
+			for {
+				_, t, err := obj.NextElement(&elems)
+				if err != nil || t == TypeNone {
+					return total, err
+				}
+				v, err := sum(&elems, typed)
+				if err != nil {
+					return 0, err
+				}
+				total += v
+			}
+		case TypeArray:
+		default:
+			return 0, nil
+		}
+		arr, err := i.Array(nil)
+		if err != nil {
+			return 0, err
+		}
+		if ft := arr.FirstType(); typed && (ft == TypeFloat || ft == TypeInt) {
+			it := arr.Floats()
+			for v, ok := it.Next(); ok; v, ok = it.Next() {
+				total += v
+			}
+			return total, it.Err()
+		}
+		it := arr.Iter()
+		for {
+			t, err := it.AdvanceIter(&elems)
+			if err != nil || t == TypeNone {
+				return total, err
+			}
+			var v float64
+			switch t {
+			case TypeFloat, TypeInt, TypeUint:
+				v, err = elems.Float()
+			default:
+				v, err = sum(&elems, typed)
+			}
+			if err != nil {
+				return 0, err
+			}
+			total += v
+		}
+	}
+
+	for _, name := range []string{"numbers", "canada"} {
+		msg := loadCompressed(b, name)
+		pj, err := Parse(msg, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, typed := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/typed=%v", name, typed), func(b *testing.B) {
+				b.SetBytes(int64(len(msg)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					iter := pj.Iter()
+					iter.AdvanceInto()
+					_, root, err := iter.Root(nil)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := sum(root, typed); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestIter_SpanBytes(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = ` { "a" : [ 1, {"b": "c"}, [] ] , "d":{ }, "e": "x", "f": [` + "\n" + `[[ "long"]] ] }`
+	pj, err := Parse([]byte(input), nil, WithSourceSpans(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	for key, want := range map[string]string{
+		"a": `[ 1, {"b": "c"}, [] ]`,
+		"d": `{ }`,
+		"f": "[\n[[ \"long\"]] ]",
+	} {
+		elem, err := i.FindElement(nil, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := elem.Iter.SpanBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != len(want) {
+			t.Errorf("%s: want %d, got %d", key, len(want), got)
+		}
+	}
+	i = pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := root.SpanBytes(); err != nil || got != len(input)-1 {
+		t.Errorf("root: want %d, got %d (err: %v)", len(input)-1, got, err)
+	}
+	// Nested values are found after the parent has been read.
+	elem, err := i.FindElement(nil, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := elem.Iter.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	arr.ForEach(func(i Iter) {
+		if n, err := i.SpanBytes(); err == nil {
+			sizes = append(sizes, n)
+		}
+	})
+	if !reflect.DeepEqual(sizes, []int{len(`{"b": "c"}`), len(`[]`)}) {
+		t.Errorf("unexpected sizes: %v", sizes)
+	}
+	if elem, err = i.FindElement(nil, "e"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := elem.Iter.SpanBytes(); err == nil {
+		t.Error("want error for string")
+	}
+
+	// Not recorded by default.
+	pj, err = Parse([]byte(input), pj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	if elem, err = i.FindElement(nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := elem.Iter.SpanBytes(); err == nil {
+		t.Error("want error without WithSourceSpans")
+	}
+
+	// Large inputs and NDJSON.
+	line := `{"a":[` + strings.Repeat(`1,`, 1000) + `2]}`
+	pj, err = ParseND([]byte(strings.Repeat(line+"\n", 20)), nil, WithSourceSpans(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	err = pj.ForEach(func(i Iter) error {
+		n++
+		if got, err := i.SpanBytes(); err != nil || got != len(line) {
+			return fmt.Errorf("want %d, got %d (err: %v)", len(line), got, err)
+		}
+		elem, err := i.FindElement(nil, "a")
+		if err != nil {
+			return err
+		}
+		if got, err := elem.Iter.SpanBytes(); err != nil || got != len(line)-6 {
+			return fmt.Errorf("want %d, got %d (err: %v)", len(line)-6, got, err)
+		}
+		return nil
+	})
+	if err != nil || n != 20 {
+		t.Fatalf("%d lines, err: %v", n, err)
+	}
+}
+
+func TestIter_ScalarOrFirst(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: `"x"`, want: `"x"`},
+		{input: `["x"]`, want: `"x"`},
+		{input: `[ 12 ]`, want: `12`},
+		{input: `[null]`, want: `null`},
+		{input: `true`, want: `true`},
+		{input: `-1.5`, want: `-1.5`},
+		{input: `[]`, wantErr: true},
+		{input: `["x","y"]`, wantErr: true},
+		{input: `[["x"]]`, wantErr: true},
+		{input: `[{"a":1}]`, wantErr: true},
+		{input: `{"a":1}`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			// Read the value as an object member.
+			pj, err := Parse([]byte(`{"v":`+test.input+`,"next":"n"}`), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			elem, err := i.FindElement(nil, "v")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := elem.Iter.ScalarOrFirst()
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %v", got.Type())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := got.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.want {
+				t.Errorf("want %s, got %s", test.want, b)
+			}
+		})
+	}
+}
+
+func TestIter_SetBool(t *testing.T) {
 	if !SupportedCPU() {
 		t.SkipNow()
 	}
 	input := `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[null,true,false,"astring",-42,9223372036854775808,1.23455]}`
 	tests := []struct {
-		setTo float64
+		setTo bool
 		want  string
 	}{
 		{
-			setTo: 69.420,
-			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":69.42,"int":69.42,"uint":69.42},"stringval":"initial value","array":[null,true,false,"astring",69.42,69.42,69.42]}`,
+			setTo: true,
+			want:  `{"0val":{"true":true,"false":true,"nullval":true},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[true,true,true,"astring",-42,9223372036854775808,1.23455]}`,
 		},
 		{
-			setTo: 10e30,
-			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":1e+31,"int":1e+31,"uint":1e+31},"stringval":"initial value","array":[null,true,false,"astring",1e+31,1e+31,1e+31]}`,
+			setTo: false,
+			want:  `{"0val":{"true":false,"false":false,"nullval":false},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[false,false,false,"astring",-42,9223372036854775808,1.23455]}`,
 		},
 	}
 
@@ -824,13 +1724,13 @@ func TestIter_SetFloat(t *testing.T) {
 			for {
 				typ := iter.Type()
 				switch typ {
-				case TypeInt, TypeFloat, TypeUint:
+				case TypeBool, TypeNull:
 					//t.Logf("setting to %v", test.setTo)
-					err := iter.SetFloat(test.setTo)
+					err := iter.SetBool(test.setTo)
 					if err != nil {
 						t.Errorf("Unable to set value: %v", err)
 					}
-					val, err := iter.Float()
+					val, err := iter.Bool()
 					if err != nil {
 						t.Errorf("Unable to retrieve value: %v", err)
 					}
@@ -838,10 +1738,8 @@ func TestIter_SetFloat(t *testing.T) {
 					if val != test.setTo {
 						t.Errorf("Want value %v, got %v", test.setTo, val)
 					}
-				case TypeString:
-					// Do not replace strings...
 				default:
-					err := iter.SetFloat(test.setTo)
+					err := iter.SetBool(test.setTo)
 					if err == nil {
 						t.Errorf("Value should not be settable for type %v", typ)
 					}
@@ -875,39 +1773,288 @@ func TestIter_SetFloat(t *testing.T) {
 	}
 }
 
-func TestIter_SetInt(t *testing.T) {
+func TestIter_MarshalIntegralFloats(t *testing.T) {
 	if !SupportedCPU() {
 		t.SkipNow()
 	}
-	input := `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[null,true,false,"astring",-42,9223372036854775808,1.23455]}`
+	const input = `[0.0,42.0,600.0,-3.0,1e20,1e21,1.5,69.42,1e-7,12]`
 	tests := []struct {
-		setTo int64
-		want  string
+		opts []MarshalOption
+		want string
 	}{
 		{
-			setTo: -69,
-			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":-69,"int":-69,"uint":-69},"stringval":"initial value","array":[null,true,false,"astring",-69,-69,-69]}`,
+			want: `[0,42,600,-3,100000000000000000000,1e+21,1.5,69.42,1e-7,12]`,
 		},
 		{
-			setTo: 42,
-			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":42,"int":42,"uint":42},"stringval":"initial value","array":[null,true,false,"astring",42,42,42]}`,
+			opts: []MarshalOption{RenderIntegralFloatsAsInt(true)},
+			want: `[0,42,600,-3,100000000000000000000,1e+21,1.5,69.42,1e-7,12]`,
+		},
+		{
+			opts: []MarshalOption{RenderIntegralFloatsAsInt(false)},
+			want: `[0.0,42.0,600.0,-3.0,100000000000000000000.0,1e+21,1.5,69.42,1e-7,12]`,
 		},
 	}
-
 	for _, test := range tests {
-		t.Run(fmt.Sprint(test.setTo), func(t *testing.T) {
-			pj, err := Parse([]byte(input), nil)
-			if err != nil {
-				t.Errorf("parseMessage failed\n")
-				return
-			}
-			root := pj.Iter()
-			// Queue root
-			root.AdvanceInto()
-			if err != nil {
-				t.Errorf("root failed: %v", err)
-				return
-			}
+		pj, err := Parse([]byte(input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iter := pj.Iter()
+		got, err := iter.MarshalJSONBufferOpts(nil, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("want %s, got %s", test.want, got)
+		}
+	}
+
+	// Set values.
+	pj, err := Parse([]byte(`{"a":1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := pj.Iter()
+	elem, err := iter.FindElement(nil, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := elem.Iter.SetFloat(42); err != nil {
+		t.Fatal(err)
+	}
+	iter = pj.Iter()
+	got, err := iter.MarshalJSONBufferOpts(nil, RenderIntegralFloatsAsInt(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":42.0}`; string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestIter_MarshalLargeIntsAsStrings(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":[9007199254740991,9007199254740992,9007199254740993,-9007199254740992,-9007199254740993,9223372036854775807,-9223372036854775808,9223372036854775808,18446744073709551615,1.5e+300,0]}`
+	tests := []struct {
+		opts []MarshalOption
+		want string
+	}{
+		{
+			want: input,
+		},
+		{
+			opts: []MarshalOption{LargeIntsAsStrings(1 << 53)},
+			want: `{"a":[9007199254740991,9007199254740992,"9007199254740993",-9007199254740992,"-9007199254740993","9223372036854775807","-9223372036854775808","9223372036854775808","18446744073709551615",1.5e+300,0]}`,
+		},
+		{
+			opts: []MarshalOption{LargeIntsAsStrings(math.MaxInt64)},
+			want: `{"a":[9007199254740991,9007199254740992,9007199254740993,-9007199254740992,-9007199254740993,9223372036854775807,"-9223372036854775808","9223372036854775808","18446744073709551615",1.5e+300,0]}`,
+		},
+		{
+			opts: []MarshalOption{LargeIntsAsStrings(0)},
+			want: `{"a":["9007199254740991","9007199254740992","9007199254740993","-9007199254740992","-9007199254740993","9223372036854775807","-9223372036854775808","9223372036854775808","18446744073709551615",1.5e+300,0]}`,
+		},
+	}
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		iter := pj.Iter()
+		got, err := iter.MarshalJSONBufferOpts(nil, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("want %s, got %s", test.want, got)
+		}
+	}
+	iter := pj.Iter()
+	if _, err := iter.MarshalJSONBufferOpts(nil, LargeIntsAsStrings(-1)); err == nil {
+		t.Error("want error for negative threshold")
+	}
+}
+
+func TestIter_MarshalJSONIndent(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	inputs := []string{
+		`{}`,
+		`[]`,
+		`{"a":1}`,
+		`{"a":{},"b":[],"c":[{}],"d":[[]]}`,
+		`{"a":[1,"two",{"three":3,"four":[4.5,null,true,false]}],"b":{"c":{"d":"e"}}}`,
+		`[[1,2],[3,[4,[5,{}]]]]`,
+	}
+	for _, input := range inputs {
+		pj, err := Parse([]byte(input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ind := range [][2]string{{"", "  "}, {"> ", "\t"}, {"", ""}} {
+			var want bytes.Buffer
+			if err := json.Indent(&want, []byte(input), ind[0], ind[1]); err != nil {
+				t.Fatal(err)
+			}
+			iter := pj.Iter()
+			got, err := iter.MarshalJSONIndent([]byte("x"), ind[0], ind[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "x"+want.String() {
+				t.Errorf("%s %q: want\n%s\ngot\n%s", input, ind, want.String(), got[1:])
+			}
+			iter = pj.Iter()
+			got, err = iter.MarshalJSONBufferOpts(nil, MarshalIndent(ind[0], ind[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want.String() {
+				t.Errorf("%s %q: option: want\n%s\ngot\n%s", input, ind, want.String(), got)
+			}
+		}
+	}
+
+	// Each line of NDJSON starts with the prefix.
+	pj, err := ParseND([]byte("{\"a\":[1]}\n[]"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := pj.Iter()
+	got, err := iter.MarshalJSONIndent(nil, "# ", " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "{\n#  \"a\": [\n#   1\n#  ]\n# }\n# []"
+	if string(got) != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestIter_SetFloat(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[null,true,false,"astring",-42,9223372036854775808,1.23455]}`
+	tests := []struct {
+		setTo float64
+		want  string
+	}{
+		{
+			setTo: 69.420,
+			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":69.42,"int":69.42,"uint":69.42},"stringval":"initial value","array":[null,true,false,"astring",69.42,69.42,69.42]}`,
+		},
+		{
+			setTo: 10e30,
+			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":1e+31,"int":1e+31,"uint":1e+31},"stringval":"initial value","array":[null,true,false,"astring",1e+31,1e+31,1e+31]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.setTo), func(t *testing.T) {
+			pj, err := Parse([]byte(input), nil)
+			if err != nil {
+				t.Errorf("parseMessage failed\n")
+				return
+			}
+			root := pj.Iter()
+			// Queue root
+			root.AdvanceInto()
+			if err != nil {
+				t.Errorf("root failed: %v", err)
+				return
+			}
+			iter := root
+			for {
+				typ := iter.Type()
+				switch typ {
+				case TypeInt, TypeFloat, TypeUint:
+					//t.Logf("setting to %v", test.setTo)
+					err := iter.SetFloat(test.setTo)
+					if err != nil {
+						t.Errorf("Unable to set value: %v", err)
+					}
+					val, err := iter.Float()
+					if err != nil {
+						t.Errorf("Unable to retrieve value: %v", err)
+					}
+
+					if val != test.setTo {
+						t.Errorf("Want value %v, got %v", test.setTo, val)
+					}
+				case TypeString:
+					// Do not replace strings...
+				default:
+					err := iter.SetFloat(test.setTo)
+					if err == nil {
+						t.Errorf("Value should not be settable for type %v", typ)
+					}
+				}
+				if iter.PeekNextTag() == TagEnd {
+					break
+				}
+				iter.AdvanceInto()
+			}
+			out, err := root.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != test.want {
+				t.Errorf("want: %s\n got: %s", test.want, string(out))
+			}
+			ser := NewSerializer()
+			pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter2 := pj2.Iter()
+			out2, err := iter2.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, out2) {
+				t.Errorf("roundtrip mismatch: %s != %s", out, out2)
+			}
+		})
+	}
+}
+
+func TestIter_SetInt(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":12.3456,"int":-42,"uint":9223372036854775808},"stringval":"initial value","array":[null,true,false,"astring",-42,9223372036854775808,1.23455]}`
+	tests := []struct {
+		setTo int64
+		want  string
+	}{
+		{
+			setTo: -69,
+			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":-69,"int":-69,"uint":-69},"stringval":"initial value","array":[null,true,false,"astring",-69,-69,-69]}`,
+		},
+		{
+			setTo: 42,
+			want:  `{"0val":{"true":true,"false":false,"nullval":null},"1val":{"float":42,"int":42,"uint":42},"stringval":"initial value","array":[null,true,false,"astring",42,42,42]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.setTo), func(t *testing.T) {
+			pj, err := Parse([]byte(input), nil)
+			if err != nil {
+				t.Errorf("parseMessage failed\n")
+				return
+			}
+			root := pj.Iter()
+			// Queue root
+			root.AdvanceInto()
+			if err != nil {
+				t.Errorf("root failed: %v", err)
+				return
+			}
 			iter := root
 			for {
 				typ := iter.Type()
@@ -1232,67 +2379,564 @@ func TestIter_SetStringBytes(t *testing.T) {
 	}
 }
 
-func ExampleIter_FindElement() {
+func TestIter_SetTimeBytes(t *testing.T) {
 	if !SupportedCPU() {
-		// Fake it
-		fmt.Println("int\n100 <nil>")
-		return
+		t.SkipNow()
 	}
-	input := `{
-    "Image":
-    {
-        "Animated": false,
-        "Height": 600,
-        "IDs":
-        [
-            116,
-            943,
-            234,
-            38793
-        ],
-        "Thumbnail":
-        {
-            "Height": 125,
-            "Url": "http://www.example.com/image/481989943",
-            "Width": 100
-        },
-        "Title": "View from 15th Floor",
-        "Width": 800
-    },
-	"Alt": "Image of city" 
-}`
-	pj, err := Parse([]byte(input), nil)
+	pj, err := Parse([]byte(`{"t":"2020-01-02T03:04:05Z","n":1.5,"b":"aGk=","o":{}}`), nil)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	i := pj.Iter()
-
-	// Find element in path.
-	elem, err := i.FindElement(nil, "Image", "Thumbnail", "Width")
+	i.Advance()
+	_, root, err := i.Root(nil)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Print result:
-	fmt.Println(elem.Type)
-	fmt.Println(elem.Iter.StringCvt())
-
-	// Output:
-	// int
-	// 100 <nil>
-}
-
-func ExampleParsedJson_ForEach() {
-	if !SupportedCPU() {
-		// Fake results
-		fmt.Println("Got iterator for type: object\nFound element: URL Type: string Value: http://example.com/example.gif")
-		return
+		t.Fatal(err)
 	}
-
-	// Parse JSON:
-	pj, err := Parse([]byte(`{"Image":{"URL":"http://example.com/example.gif"}}`), nil)
+	obj, err := root.Object(nil)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
+	}
+	find := func(key string) *Iter {
+		t.Helper()
+		e := obj.FindKey(key, nil)
+		if e == nil {
+			t.Fatalf("key %q not found", key)
+		}
+		return &e.Iter
+	}
+
+	if got, err := find("t").Time(""); err != nil || !got.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Time: got %v (err: %v)", got, err)
+	}
+	if got, err := find("b").Bytes(); err != nil || string(got) != "hi" {
+		t.Errorf("Bytes: got %q (err: %v)", got, err)
+	}
+	if _, err := find("n").Time(""); err == nil {
+		t.Error("Time: want error for number")
+	}
+	if _, err := find("t").Bytes(); err == nil {
+		t.Error("Bytes: want error for invalid base64")
+	}
+
+	ts := time.Date(2021, 5, 6, 7, 8, 9, 123000000, time.FixedZone("", 3600))
+	if err := find("t").SetTime(ts, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := find("n").SetTime(ts, "2006-01-02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := find("b").SetBytes([]byte{0, 1, 2, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	if err := find("o").SetTime(ts, ""); err == nil {
+		t.Error("SetTime: want error for object")
+	}
+	if err := find("o").SetBytes(nil); err == nil {
+		t.Error("SetBytes: want error for object")
+	}
+
+	const want = `{"t":"2021-05-06T07:08:09.123+01:00","n":"2021-05-06","b":"AAEC/w==","o":{}}`
+	ser := NewSerializer()
+	pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pj := range []*ParsedJson{pj, pj2} {
+		i := pj.Iter()
+		out, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != want {
+			t.Errorf("want: %s\n got: %s", want, out)
+		}
+	}
+	if got, err := find("t").Time(time.RFC3339Nano); err != nil || !got.Equal(ts) {
+		t.Errorf("Time: want %v, got %v (err: %v)", ts, got, err)
+	}
+	if got, err := find("b").Bytes(); err != nil || !bytes.Equal(got, []byte{0, 1, 2, 0xff}) {
+		t.Errorf("Bytes: got %v (err: %v)", got, err)
+	}
+}
+
+func TestIter_Num(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`[-1, 18446744073709551615, 1.5, 1e3, "str", null]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		i    int64
+		u    uint64
+		f    float64
+		kind NumberKind
+	}{
+		{i: -1, kind: NumberInt},
+		{u: math.MaxUint64, kind: NumberUint},
+		{f: 1.5, kind: NumberFloat},
+		{f: 1000, kind: NumberFloat},
+		{kind: NumberNone},
+		{kind: NumberNone},
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := arr.Iter()
+	for n := 0; iter.Advance() != TypeNone; n++ {
+		w := want[n]
+		if got := iter.NumberKind(); got != w.kind {
+			t.Errorf("%d: want kind %v, got %v", n, w.kind, got)
+		}
+		gi, gu, gf, kind, err := iter.Num()
+		if (err != nil) != (w.kind == NumberNone) {
+			t.Errorf("%d: unexpected error state: %v", n, err)
+		}
+		if gi != w.i || gu != w.u || gf != w.f || kind != w.kind {
+			t.Errorf("%d: want %v %v %v %v, got %v %v %v %v", n, w.i, w.u, w.f, w.kind, gi, gu, gf, kind)
+		}
+	}
+}
+
+func TestIter_InRange(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`[80, 65535, 65536, -1, 50.5, 18446744073709551615, 100.0, "str", "80"]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		port, percent, even bool
+		err                 bool
+	}
+	want := []result{
+		{port: true, percent: true, even: true},
+		{port: true},
+		{even: true},
+		{},
+		{port: true, percent: true},
+		{},
+		{port: true, percent: true, even: true},
+		{err: true},
+		{err: true},
+	}
+	portRe := regexp.MustCompile(`^[0-9]+$`)
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := arr.Iter()
+	for n := 0; iter.Advance() != TypeNone; n++ {
+		w := want[n]
+		port, err := iter.InRange(1, 65535)
+		if (err != nil) != w.err {
+			t.Fatalf("%d: unexpected error state: %v", n, err)
+		}
+		if w.err {
+			if _, err := iter.MultipleOf(2); err == nil {
+				t.Errorf("%d: want MultipleOf error", n)
+			}
+			ok, err := iter.StringMatches(portRe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s, _ := iter.String(); ok != (s == "80") {
+				t.Errorf("%d: unexpected match result %v", n, ok)
+			}
+			continue
+		}
+		if _, err := iter.StringMatches(portRe); err == nil {
+			t.Errorf("%d: want StringMatches error", n)
+		}
+		percent, _ := iter.InRange(0, 100)
+		even, err := iter.MultipleOf(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := result{port: port, percent: percent, even: even}
+		if got != w {
+			t.Errorf("%d: want %+v, got %+v", n, w, got)
+		}
+	}
+	if _, err := iter.MultipleOf(0); err == nil {
+		t.Error("want error for multiple of zero")
+	}
+}
+
+func TestIter_InterfaceDeep(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Alternate objects and arrays up to the maximum depth.
+	const depth = maxdepth - 2
+	var sb strings.Builder
+	for n := 0; n < depth; n++ {
+		if n&1 == 0 {
+			sb.WriteString(`{"a":1,"b":`)
+		} else {
+			sb.WriteString(`[true,`)
+		}
+	}
+	sb.WriteString(`"x"`)
+	for n := depth - 1; n >= 0; n-- {
+		if n&1 == 0 {
+			sb.WriteString(`,"c":[],"d":{}}`)
+		} else {
+			sb.WriteString(`]`)
+		}
+	}
+	pj, err := Parse([]byte(sb.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := pj.Iter()
+	got, err := iter.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want interface{} = "x"
+	for n := depth - 1; n >= 0; n-- {
+		if n&1 == 0 {
+			want = map[string]interface{}{"a": int64(1), "b": want, "c": []interface{}{}, "d": map[string]interface{}{}}
+		} else {
+			want = []interface{}{true, want}
+		}
+	}
+	if !reflect.DeepEqual(got, []interface{}{want}) {
+		t.Fatal("output mismatch")
+	}
+}
+
+func TestIter_InterfaceWithNumbers(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"id":18446744073709551615,"neg":-9223372036854775808,"f":1.2300,"e":1e5,"arr":[1,"x",null,[2.50]]}`
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var want interface{}
+	if err := dec.Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+	pj, err := Parse([]byte(input), nil, WithLazyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := pj.Iter()
+	got, err := iter.InterfaceWithNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []interface{}{want}) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// Without lazy numbers floats are formatted from their value.
+	pj, err = Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter = pj.Iter()
+	got, err = iter.InterfaceWithNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := got.([]interface{})[0].(map[string]interface{})
+	for key, want := range map[string]json.Number{"id": "18446744073709551615", "neg": "-9223372036854775808", "f": "1.23", "e": "100000"} {
+		if m[key] != want {
+			t.Errorf("%s: want %v, got %#v", key, want, m[key])
+		}
+	}
+	iter = pj.Iter()
+	got, err = iter.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.([]interface{})[0].(map[string]interface{})["id"]; v != uint64(math.MaxUint64) {
+		t.Errorf("Interface: want uint64, got %#v", v)
+	}
+}
+
+func TestIter_InterfaceReuse(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	var scratch InterfaceScratch
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse(loadCompressed(t, tt.name), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := pj.Iter()
+			cpy := iter
+			want, err := cpy.Interface()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Run twice to check reused storage.
+			for n := 0; n < 2; n++ {
+				cpy = iter
+				got, err := cpy.InterfaceReuse(&scratch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Fatal("output mismatch")
+				}
+			}
+		})
+	}
+}
+
+func TestIter_Raw(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `["plain", "esc\u0041\n\"q\"", "\/", 12, true]`
+	want := []string{`"plain"`, `"esc\u0041\n\"q\""`, `"\/"`, `12`, `true`}
+	wantCopied := []string{`"plain"`, `"escA\n\"q\""`, `"/"`, `12`, `true`}
+	for _, test := range []struct {
+		name string
+		opts []ParserOption
+		want []string
+	}{
+		{name: "default", want: wantCopied},
+		{name: "nocopy", opts: []ParserOption{WithCopyStrings(false)}, want: wantCopied},
+		{name: "preserve", opts: []ParserOption{WithPreserveEscapes(true)}, want: want},
+		{name: "preserve-nocopy", opts: []ParserOption{WithPreserveEscapes(true), WithCopyStrings(false)}, want: want},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pj, err := Parse([]byte(input), nil, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			i.AdvanceInto()
+			_, root, err := i.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			arr, err := root.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := arr.Iter()
+			for n := 0; iter.Advance() != TypeNone; n++ {
+				got, err := iter.Raw()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != test.want[n] {
+					t.Errorf("%d: want %s, got %s", n, test.want[n], got)
+				}
+			}
+		})
+	}
+}
+
+func TestIter_NumberBytes(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `[1.2300, 1e5, -0.50E+2, 12, -9223372036854775808, 18446744073709551615, "1", null]`
+	want := []string{`1.2300`, `1e5`, `-0.50E+2`, `12`, `-9223372036854775808`, `18446744073709551615`}
+	pj, err := Parse([]byte(input), nil, WithLazyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := arr.Iter()
+	for n := 0; iter.Advance() != TypeNone; n++ {
+		got, err := iter.NumberBytes()
+		if n >= len(want) {
+			if err == nil {
+				t.Errorf("%d: want error, got %s", n, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want[n] {
+			t.Errorf("%d: want %s, got %s", n, want[n], got)
+		}
+	}
+
+	// Modified floats no longer have a source.
+	iter = arr.Iter()
+	iter.Advance()
+	if err := iter.SetFloat(1.23); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := iter.NumberBytes(); err == nil {
+		t.Error("want error for modified float")
+	}
+
+	// Floats need WithLazyNumbers.
+	pj, err = Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	i.AdvanceInto()
+	if _, root, err = i.Root(nil); err != nil {
+		t.Fatal(err)
+	}
+	if arr, err = root.Array(nil); err != nil {
+		t.Fatal(err)
+	}
+	iter = arr.Iter()
+	iter.Advance()
+	if _, err := iter.NumberBytes(); err == nil {
+		t.Error("want error without WithLazyNumbers")
+	}
+}
+
+func TestIter_RawBuffer(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `[{"a":[1,{"b":null}]}, [], "s", [[2],3], {}, -1.5]`
+	want := []string{`{"a":[1,{"b":null}]}`, `[]`, `"s"`, `[[2],3]`, `{}`, `-1.5`}
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := arr.Iter()
+	var buf []byte
+	for n := 0; iter.Advance() != TypeNone; n++ {
+		buf, err = iter.RawBuffer(buf[:0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != want[n] {
+			t.Errorf("%d: want %s, got %s", n, want[n], buf)
+		}
+		raw, err := iter.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != want[n] {
+			t.Errorf("%d: Raw: want %s, got %s", n, want[n], raw)
+		}
+	}
+
+	// Modifications are included.
+	iter = arr.Iter()
+	iter.Advance()
+	obj, err := iter.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.FindKey("a", nil).Iter.SetNull(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := iter.RawBuffer([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `x{"a":null}` {
+		t.Errorf("want modified value, got %s", got)
+	}
+}
+
+func ExampleIter_FindElement() {
+	if !SupportedCPU() {
+		// Fake it
+		fmt.Println("int\n100 <nil>")
+		return
+	}
+	input := `{
+    "Image":
+    {
+        "Animated": false,
+        "Height": 600,
+        "IDs":
+        [
+            116,
+            943,
+            234,
+            38793
+        ],
+        "Thumbnail":
+        {
+            "Height": 125,
+            "Url": "http://www.example.com/image/481989943",
+            "Width": 100
+        },
+        "Title": "View from 15th Floor",
+        "Width": 800
+    },
+	"Alt": "Image of city" 
+}`
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	i := pj.Iter()
+
+	// Find element in path.
+	elem, err := i.FindElement(nil, "Image", "Thumbnail", "Width")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Print result:
+	fmt.Println(elem.Type)
+	fmt.Println(elem.Iter.StringCvt())
+
+	// Output:
+	// int
+	// 100 <nil>
+}
+
+func ExampleParsedJson_ForEach() {
+	if !SupportedCPU() {
+		// Fake results
+		fmt.Println("Got iterator for type: object\nFound element: URL Type: string Value: http://example.com/example.gif")
+		return
+	}
+
+	// Parse JSON:
+	pj, err := Parse([]byte(`{"Image":{"URL":"http://example.com/example.gif"}}`), nil)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Create an element we can reuse.
@@ -1313,3 +2957,444 @@ func ExampleParsedJson_ForEach() {
 	// Got iterator for type: object
 	// Found element: URL Type: string Value: http://example.com/example.gif
 }
+
+func TestParsedJson_CountKeyOccurrences(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":"a","b":{"a":[{"a":1},"a",{"c":"a"}],"x":{"a":null}},"ab":2.5}
+{"c":["a",{"a":true}]}
+{"b":[]}`
+	pj, err := ParseND([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]int{"a": 5, "b": 2, "c": 2, "x": 1, "ab": 1, "": 0, "z": 0} {
+		if got := pj.CountKeyOccurrences(key); got != want {
+			t.Errorf("%q: want %d, got %d", key, want, got)
+		}
+	}
+	pj, err = Parse([]byte(demo_json), nil, WithLazyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pj.CountKeyOccurrences("Width"); got != 2 {
+		t.Errorf("want 2, got %d", got)
+	}
+}
+
+func TestParsedJson_Column(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"id":1,"user":{"name":"a\"b","tags":["x"]}}
+{"id":2,"user":{"name":null}}
+{"id":3}
+[1,2]
+{"id":4,"user":{"name":12.5,"tags":{}}}
+`
+	pj, err := ParseND([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path []string
+		want []string
+	}{
+		{path: []string{"id"}, want: []string{"1", "2", "3", "", "4"}},
+		{path: []string{"user", "name"}, want: []string{`"a\"b"`, "null", "", "", "12.5"}},
+		{path: []string{"user", "tags"}, want: []string{`["x"]`, "", "", "", "{}"}},
+		{path: []string{"missing"}, want: []string{"", "", "", "", ""}},
+	}
+	var col [][]byte
+	for _, test := range tests {
+		col, err = pj.Column(test.path, col[:0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(col) != len(test.want) {
+			t.Fatalf("%v: want %d values, got %d", test.path, len(test.want), len(col))
+		}
+		for n, want := range test.want {
+			if want == "" && col[n] != nil {
+				t.Errorf("%v: value %d: want nil, got %s", test.path, n, col[n])
+			}
+			if string(col[n]) != want {
+				t.Errorf("%v: value %d: want %s, got %s", test.path, n, want, col[n])
+			}
+		}
+	}
+
+	// Buffers are reused and values are appended.
+	col, err = pj.Column([]string{"id"}, col[:0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &col[0][:1][0]
+	col, err = pj.Column([]string{"id"}, col[:0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &col[0][:1][0] != first {
+		t.Error("buffer was not reused")
+	}
+	col, err = pj.Column([]string{"id"}, col)
+	if err != nil || len(col) != 10 || string(col[9]) != "4" {
+		t.Errorf("want appended values, got %q (err: %v)", col, err)
+	}
+
+	// A single document has one value.
+	pj, err = Parse([]byte(`{"a":{"b":true}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, err = pj.Column([]string{"a", "b"}, nil)
+	if err != nil || len(col) != 1 || string(col[0]) != "true" {
+		t.Errorf("want [true], got %q (err: %v)", col, err)
+	}
+	if _, err := pj.Column(nil, nil); err == nil {
+		t.Error("want error for empty path")
+	}
+}
+
+func TestIter_ForEachScalar(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"a":{"b":[1,{"c":"x"},[true,null]],"d":{}},"e":2.5,"f":[]}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	var got []string
+	err = i.ForEachScalar(func(path string, i Iter) error {
+		v, err := i.Interface()
+		if err != nil {
+			return err
+		}
+		got = append(got, fmt.Sprintf("%s=%v", path, v))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/b/0=1", "a/b/1/c=x", "a/b/2/0=true", "a/b/2/1=<nil>", "e=2.5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	var values []Type
+	err = i.ForEachScalarValue(func(i Iter) error {
+		values = append(values, i.Type())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := []Type{TypeInt, TypeString, TypeBool, TypeNull, TypeFloat}
+	if !reflect.DeepEqual(values, wantTypes) {
+		t.Errorf("want %v, got %v", wantTypes, values)
+	}
+
+	// Errors stop iteration.
+	errStop := errors.New("stop")
+	n := 0
+	err = i.ForEachScalarValue(func(i Iter) error {
+		n++
+		return errStop
+	})
+	if err != errStop || n != 1 {
+		t.Errorf("want error after 1 value, got %v after %d", err, n)
+	}
+
+	// The iterator is not advanced.
+	if i.Type() != TypeRoot || i.PeekNext() != TypeNone {
+		t.Errorf("iterator was advanced")
+	}
+}
+
+func TestIter_CollectAtDepth(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"a":{"b":[1,{"c":"x"}],"d":{}},"e":2.5,"f":[]}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	collect := func(depth int) []string {
+		t.Helper()
+		var got []string
+		err := i.CollectAtDepth(depth, func(path string, i Iter) error {
+			b, err := i.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			got = append(got, path+"="+string(b))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{depth: 0, want: []string{`={"a":{"b":[1,{"c":"x"}],"d":{}},"e":2.5,"f":[]}`}},
+		{depth: 1, want: []string{`a={"b":[1,{"c":"x"}],"d":{}}`, `e=2.5`, `f=[]`}},
+		{depth: 2, want: []string{`a/b=[1,{"c":"x"}]`, `a/d={}`}},
+		{depth: 3, want: []string{`a/b/0=1`, `a/b/1={"c":"x"}`}},
+		{depth: 4, want: []string{`a/b/1/c="x"`}},
+		{depth: 5, want: nil},
+	}
+	for _, test := range tests {
+		got := collect(test.depth)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("depth %d: want %v, got %v", test.depth, test.want, got)
+		}
+	}
+
+	// Fields of each NDJSON record.
+	pj, err = ParseND([]byte("{\"x\":1,\"y\":2}\n{\"x\":3}"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err = pj.ForEach(func(i Iter) error {
+		return i.CollectAtDepth(1, func(path string, i Iter) error {
+			v, err := i.Int()
+			got = append(got, fmt.Sprintf("%s=%d", path, v))
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x=1", "y=2", "x=3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if err := i.CollectAtDepth(-1, nil); err == nil {
+		t.Error("want error for negative depth")
+	}
+}
+
+func TestIter_Reset(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	elem, err := i.FindElement(nil, "Image", "IDs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := elem.Iter.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Count in first pass, sum in second.
+	it := arr.Iter()
+	n := 0
+	for it.Advance() != TypeNone {
+		n++
+	}
+	it.Reset()
+	var sum int64
+	for it.Advance() != TypeNone {
+		v, err := it.Int()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += v
+	}
+	if n != 4 || sum != 116+943+234+38793 {
+		t.Errorf("got count %d, sum %d", n, sum)
+	}
+
+	// Value iterators queue the value again.
+	value := elem.Iter
+	want, err := value.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value.Reset()
+	if value.Type() != TypeArray {
+		t.Fatalf("want array, got %v", value.Type())
+	}
+	got, err := value.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// Root iterators.
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+	root.Reset()
+	got, err = root.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != demo_json {
+		t.Errorf("want %s, got %s", demo_json, got)
+	}
+	i.Reset()
+	if typ := i.Advance(); typ != TypeRoot {
+		t.Errorf("want root, got %v", typ)
+	}
+
+	// Scalar values are found from the start of the tape.
+	elem, err = i.FindElement(nil, "Image", "Thumbnail", "Width")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value = elem.Iter
+	if _, err := value.Int(); err != nil {
+		t.Fatal(err)
+	}
+	value.Advance()
+	value.Reset()
+	if v, err := value.Int(); err != nil || v != 100 {
+		t.Errorf("want 100, got %v (%v)", v, err)
+	}
+
+	pj, err = Parse([]byte(` "scalar" `), nil, WithAllowScalarRoot(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	i.AdvanceInto()
+	_, root, err = i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.Advance()
+	root.Reset()
+	if s, err := root.String(); err != nil || s != "scalar" {
+		t.Errorf("want scalar, got %q (%v)", s, err)
+	}
+}
+
+func TestParsedJson_RootType(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input string
+		want  Type
+	}{
+		{input: `{"a":1}`, want: TypeObject},
+		{input: ` [1,2]`, want: TypeArray},
+		{input: `"str"`, want: TypeString},
+		{input: `-1`, want: TypeInt},
+		{input: `18446744073709551615`, want: TypeUint},
+		{input: `1.5`, want: TypeFloat},
+		{input: `true`, want: TypeBool},
+		{input: `null`, want: TypeNull},
+	}
+	for _, test := range tests {
+		pj, err := Parse([]byte(test.input), nil, WithStrictRFC8259(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pj.RootType(); got != test.want {
+			t.Errorf("%s: want %v, got %v", test.input, test.want, got)
+		}
+	}
+	pj, err := ParseND([]byte("[1]\n{}"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pj.RootType(); got != TypeArray {
+		t.Errorf("ndjson: want %v, got %v", TypeArray, got)
+	}
+	var empty ParsedJson
+	if got := empty.RootType(); got != TypeNone {
+		t.Errorf("empty: want %v, got %v", TypeNone, got)
+	}
+}
+
+func TestParsedJson_MemStats(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	var empty ParsedJson
+	if tape, strs, msg := empty.MemStats(); tape != 0 || strs != 0 || msg != 0 {
+		t.Errorf("want zero for empty, got %d, %d, %d", tape, strs, msg)
+	}
+	big := loadCompressed(t, "twitter")
+	pj, err := Parse(big, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tape, strs, msg := pj.MemStats()
+	if tape < len(pj.Tape)*8 || strs < len(pj.Strings.B) || msg != cap(pj.Message) {
+		t.Errorf("unexpected stats: %d, %d, %d", tape, strs, msg)
+	}
+
+	// Reusing keeps the grown buffers.
+	pj, err = Parse([]byte(`{"a":"b"}`), pj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tape2, strs2, _ := pj.MemStats()
+	if tape2 != tape || strs2 != strs {
+		t.Errorf("want buffers to be kept, got %d, %d, want %d, %d", tape2, strs2, tape, strs)
+	}
+}
+
+func TestParsedJsonPool(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	inputs := [][]byte{[]byte(`{"a":"first","b":[1,2,3]}`), []byte(`{"c":"second"}`)}
+	for n := 0; n < 4; n++ {
+		input := inputs[n%len(inputs)]
+		pj, err := Parse(input, GetParsedJson())
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		got, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Errorf("want %s, got %s", input, got)
+		}
+		PutParsedJson(pj)
+		if pj.Message != nil || len(pj.Tape) != 0 || len(pj.Strings.B) != 0 {
+			t.Errorf("not recycled: message %q, tape %d, strings %d", pj.Message, len(pj.Tape), len(pj.Strings.B))
+		}
+	}
+	PutParsedJson(nil)
+
+	// Parsing small inputs with pooled values does not allocate.
+	// The pool may be cleared by the GC, so allow occasional allocations.
+	allocs := testing.AllocsPerRun(100, func() {
+		pj, err := Parse(inputs[0], GetParsedJson())
+		if err != nil {
+			t.Fatal(err)
+		}
+		PutParsedJson(pj)
+	})
+	if allocs >= 1 {
+		t.Errorf("want no allocations, got %v", allocs)
+	}
+}
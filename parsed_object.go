@@ -17,11 +17,20 @@
 package simdjson
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Object represents a JSON object.
+// Elements are always returned in the order they appear in the source document.
+// This applies to ForEach, NextElement, NextElementBytes, Parse and all other iteration,
+// and is preserved when serializing and deserializing the parsed JSON.
+// Duplicate keys are all returned, in document order.
 type Object struct {
 	// Complete tape
 	tape ParsedJson
@@ -54,6 +63,132 @@ func (o *Object) Map(dst map[string]interface{}) (map[string]interface{}, error)
 	return dst, nil
 }
 
+// RawMap will store the marshaled JSON of each value in a map[string]json.RawMessage.
+// This can be used to defer decoding values, or to hand them off to other decoders.
+// All values share a single buffer, so the number of allocations does not depend on the number of elements.
+// If a key occurs more than once, the last value is stored.
+// The Object will be consumed.
+func (o *Object) RawMap(dst map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	if dst == nil {
+		dst = make(map[string]json.RawMessage)
+	}
+	type rawElem struct {
+		name       string
+		start, end int
+	}
+	var elems []rawElem
+	var buf []byte
+	var tmp Iter
+	for {
+		name, t, err := o.NextElement(&tmp)
+		if err != nil {
+			return nil, err
+		}
+		if t == TypeNone {
+			break
+		}
+		start := len(buf)
+		buf, err = tmp.MarshalJSONBuffer(buf)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling element %q: %w", name, err)
+		}
+		elems = append(elems, rawElem{name: name, start: start, end: len(buf)})
+	}
+	// Values are only sliced when the buffer will no longer be reallocated.
+	for _, e := range elems {
+		dst[e.name] = buf[e.start:e.end:e.end]
+	}
+	return dst, nil
+}
+
+// ToValues will convert the object to url.Values.
+// Numbers, booleans and null are converted to strings using Iter.StringCvt.
+// If a key occurs more than once, all values are added.
+// An error is returned if the object contains objects or arrays.
+// The object will not be advanced.
+func (o *Object) ToValues() (url.Values, error) {
+	return o.toValues(false)
+}
+
+// ToValuesSkipNested works as ToValues, but skips object and array values
+// instead of returning an error.
+func (o *Object) ToValuesSkipNested() (url.Values, error) {
+	return o.toValues(true)
+}
+
+func (o *Object) toValues(skipNested bool) (url.Values, error) {
+	obj := *o
+	dst := make(url.Values)
+	var tmp Iter
+	for {
+		name, t, err := obj.NextElement(&tmp)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case TypeNone:
+			return dst, nil
+		case TypeObject, TypeArray:
+			if skipNested {
+				continue
+			}
+			return nil, fmt.Errorf("element %q: cannot convert %v to value", name, t)
+		}
+		v, err := tmp.StringCvt()
+		if err != nil {
+			return nil, fmt.Errorf("element %q: %w", name, err)
+		}
+		dst.Add(name, v)
+	}
+}
+
+// AppendCSVRow will append the values of the named columns as a CSV row to dst.
+// Values are converted to strings using Iter.StringCvt and quoted as described in RFC 4180
+// if they contain commas, quotes, newlines or leading spaces.
+// Missing keys produce empty fields.
+// The row is terminated by a newline.
+// An error is returned if a column contains an object or an array.
+// The object will not be advanced.
+func (o *Object) AppendCSVRow(dst []byte, columns []string) ([]byte, error) {
+	var e Element
+	for n, col := range columns {
+		if n > 0 {
+			dst = append(dst, ',')
+		}
+		if o.FindKey(col, &e) == nil {
+			continue
+		}
+		if e.Type == TypeObject || e.Type == TypeArray {
+			return dst, fmt.Errorf("element %q: cannot convert %v to CSV field", col, e.Type)
+		}
+		v, err := e.Iter.StringCvt()
+		if err != nil {
+			return dst, fmt.Errorf("element %q: %w", col, err)
+		}
+		dst = appendCSVField(dst, v)
+	}
+	return append(dst, '\n'), nil
+}
+
+// appendCSVField appends s to dst, quoted if needed.
+func appendCSVField(dst []byte, s string) []byte {
+	if s == "" || (s[0] != ' ' && s[0] != '\t' && !strings.ContainsAny(s, ",\"\r\n")) {
+		return append(dst, s...)
+	}
+	dst = append(dst, '"')
+	for {
+		q := strings.IndexByte(s, '"')
+		if q < 0 {
+			break
+		}
+		dst = append(dst, s[:q+1]...)
+		dst = append(dst, '"')
+		s = s[q+1:]
+	}
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
+
 // Parse will return all elements and iterators.
 // An optional destination can be given.
 // The Object will be consumed.
@@ -137,6 +272,270 @@ func (o *Object) FindKey(key string, dst *Element) *Element {
 	}
 }
 
+// FindKeyInsensitive will return a single element with a name that matches key
+// using ASCII case folding, so "url" will match "Url" and "URL".
+// Non-ASCII characters must match exactly.
+// The first matching element in document order is returned,
+// and the name of the returned element is the name as it appears in the document.
+// An optional destination can be given.
+// The method will return nil if the element cannot be found.
+// The object will not be advanced.
+func (o *Object) FindKeyInsensitive(key string, dst *Element) *Element {
+	tmp := o.tape.Iter()
+	tmp.off = o.off
+	for {
+		typ := tmp.Advance()
+		// We want name and at least one value.
+		if typ != TypeString || tmp.off+1 >= len(tmp.tape.Tape) {
+			return nil
+		}
+		offset := tmp.cur
+		length := tmp.tape.Tape[tmp.off]
+		if int(length) != len(key) {
+			// Skip the value.
+			if tmp.Advance() == TypeNone {
+				return nil
+			}
+			continue
+		}
+		name, err := tmp.tape.stringByteAt(offset, length)
+		if err != nil {
+			return nil
+		}
+		if !equalFoldASCII(name, key) {
+			// Skip the value
+			tmp.Advance()
+			continue
+		}
+		if dst == nil {
+			dst = &Element{}
+		}
+		if string(name) == key {
+			dst.Name = key
+		} else {
+			dst.Name = string(name)
+		}
+		dst.Type, err = tmp.AdvanceIter(&dst.Iter)
+		if err != nil {
+			return nil
+		}
+		return dst
+	}
+}
+
+// equalFoldASCII returns whether a and b are equal using ASCII case folding.
+// a and b must have the same length.
+func equalFoldASCII(a []byte, b string) bool {
+	for i := range a {
+		x, y := a[i], b[i]
+		if x == y {
+			continue
+		}
+		if 'A' <= x && x <= 'Z' {
+			x += 'a' - 'A'
+		}
+		if 'A' <= y && y <= 'Z' {
+			y += 'a' - 'A'
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// Rename will change the name of the first element with the key oldName to newName.
+// The new name is added to the string buffer and the key is updated to reference it,
+// so the structure of the tape is not changed.
+// If an element named newName already exists, the object will contain duplicate keys.
+// Returns whether an element with the old name was found.
+// The object will not be advanced.
+func (o *Object) Rename(oldName, newName string) (bool, error) {
+	tmp := o.tape.Iter()
+	tmp.off = o.off
+	for {
+		typ := tmp.Advance()
+		// We want name and at least one value.
+		if typ != TypeString || tmp.off+1 >= len(tmp.tape.Tape) {
+			if typ == TypeNone {
+				return false, nil
+			}
+			return false, fmt.Errorf("object: unexpected name tag %v", tmp.t)
+		}
+		offset := tmp.cur
+		length := tmp.tape.Tape[tmp.off]
+		if int(length) == len(oldName) {
+			name, err := tmp.tape.stringByteAt(offset, length)
+			if err != nil {
+				return false, fmt.Errorf("getting object name: %w", err)
+			}
+			if string(name) == oldName {
+				strs := tmp.tape.Strings
+				tmp.tape.Tape[tmp.off-1] = ((uint64(TagString) << JSONTAGOFFSET) | STRINGBUFBIT) | uint64(len(strs.B))
+				tmp.tape.Tape[tmp.off] = uint64(len(newName))
+				strs.B = append(strs.B, newName...)
+				return true, nil
+			}
+		}
+		// Skip the value
+		if tmp.Advance() == TypeNone {
+			return false, nil
+		}
+	}
+}
+
+// GetString returns the string value of the named element.
+// False is returned if the key cannot be found or the value is not a string.
+// The object will not be advanced.
+func (o *Object) GetString(key string) (string, bool) {
+	var e Element
+	if o.FindKey(key, &e) == nil || e.Type != TypeString {
+		return "", false
+	}
+	v, err := e.Iter.String()
+	return v, err == nil
+}
+
+// GetInt returns the integer value of the named element.
+// False is returned if the key cannot be found, the value is not an integer
+// or the value cannot be represented as an int64.
+// Float values are not converted.
+// The object will not be advanced.
+func (o *Object) GetInt(key string) (int64, bool) {
+	var e Element
+	if o.FindKey(key, &e) == nil || (e.Type != TypeInt && e.Type != TypeUint) {
+		return 0, false
+	}
+	v, err := e.Iter.Int()
+	return v, err == nil
+}
+
+// GetFloat returns the value of the named element as a float.
+// Integer values are converted to float64.
+// False is returned if the key cannot be found or the value is not a number.
+// The object will not be advanced.
+func (o *Object) GetFloat(key string) (float64, bool) {
+	var e Element
+	if o.FindKey(key, &e) == nil {
+		return 0, false
+	}
+	switch e.Type {
+	case TypeFloat, TypeInt, TypeUint:
+	default:
+		return 0, false
+	}
+	v, err := e.Iter.Float()
+	return v, err == nil
+}
+
+// GetBool returns the boolean value of the named element.
+// False is returned as the second value if the key cannot be found or the value is not a boolean.
+// The object will not be advanced.
+func (o *Object) GetBool(key string) (value, ok bool) {
+	var e Element
+	if o.FindKey(key, &e) == nil || e.Type != TypeBool {
+		return false, false
+	}
+	v, err := e.Iter.Bool()
+	return v, err == nil
+}
+
+// RequireKeys returns the keys that are not present in the object,
+// in the order they were given.
+// If all keys are present nil is returned.
+// The object is read once, regardless of the number of keys.
+// The object will not be advanced.
+func (o *Object) RequireKeys(keys ...string) ([]string, error) {
+	found := make([]bool, len(keys))
+	err := o.matchKeys(keys, found, func(idx int, t Type) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for idx, ok := range found {
+		if !ok {
+			missing = append(missing, keys[idx])
+		}
+	}
+	return missing, nil
+}
+
+// RequireTypes returns the keys in types that are not present in the object
+// or where the value has a different type.
+// Only the first element with a given name is checked, like FindKey.
+// The returned keys are sorted. If all keys match nil is returned.
+// The object is read once, regardless of the number of keys.
+// The object will not be advanced.
+func (o *Object) RequireTypes(types map[string]Type) ([]string, error) {
+	keys := make([]string, 0, len(types))
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	found := make([]bool, len(keys))
+	err := o.matchKeys(keys, found, func(idx int, t Type) bool { return types[keys[idx]] == t })
+	if err != nil {
+		return nil, err
+	}
+	var mismatched []string
+	for idx, ok := range found {
+		if !ok {
+			mismatched = append(mismatched, keys[idx])
+		}
+	}
+	return mismatched, nil
+}
+
+// matchKeys will read the object and set found for the first element with each name in keys,
+// if match returns true for the type of the value.
+// Reading stops when all keys have been seen.
+func (o *Object) matchKeys(keys []string, found []bool, match func(idx int, t Type) bool) error {
+	seen := make([]bool, len(keys))
+	remain := len(keys)
+	tmp := *o
+	var elem Iter
+	for remain > 0 {
+		name, t, err := tmp.NextElementBytes(&elem)
+		if err != nil {
+			return err
+		}
+		if t == TypeNone {
+			return nil
+		}
+		for idx, key := range keys {
+			if seen[idx] || len(key) != len(name) || key != string(name) {
+				continue
+			}
+			seen[idx] = true
+			found[idx] = match(idx, t)
+			remain--
+		}
+	}
+	return nil
+}
+
+// Keys appends the keys of the object to dst in document order and returns the result.
+// Values are skipped without being read.
+// Duplicate keys are returned each time they occur.
+// The object will not be advanced.
+func (o *Object) Keys(dst []string) ([]string, error) {
+	obj := *o
+	var tmp Iter
+	for {
+		name, t, err := obj.NextElementBytes(&tmp)
+		if err != nil {
+			return dst, err
+		}
+		if t == TypeNone {
+			if obj.off >= len(obj.tape.Tape) {
+				return dst, errors.New("object: unexpected end of tape")
+			}
+			return dst, nil
+		}
+		dst = append(dst, string(name))
+	}
+}
+
 // ForEach will call back fn for each key.
 // A key filter can be provided for optional filtering.
 func (o *Object) ForEach(fn func(key []byte, i Iter), onlyKeys map[string]struct{}) error {
@@ -242,19 +641,163 @@ func (o *Object) DeleteElems(fn func(key []byte, i Iter) bool, onlyKeys map[stri
 	}
 }
 
+// FilterKeys will build a new document containing an object with only the keys in keep and their values.
+// Keys are kept in the original order, and if a key occurs more than once all occurrences are kept.
+// Strings are copied, so the returned object does not reference the original document,
+// which is not modified.
+// An optional destination can be supplied. Its content will be replaced by the new document,
+// which contains a single root with the object.
+// The object will not be advanced.
+func (o *Object) FilterKeys(keep map[string]struct{}, dst *ParsedJson) (*Object, error) {
+	if dst == nil {
+		dst = &ParsedJson{}
+	}
+	dst.Tape = dst.Tape[:0]
+	if dst.Strings == nil {
+		dst.Strings = &TStrings{}
+	}
+	dst.Strings.B = dst.Strings.B[:0]
+	dst.Message = nil
+	dst.rawStrings = nil
+	dst.spans = nil
+
+	dst.write_tape(0, byte(TagRoot))
+	dst.write_tape(0, byte(TagObjectStart))
+	tmp := o.tape.Iter()
+	tmp.off = o.off
+	for {
+		typ := tmp.Advance()
+		if typ == TypeNone {
+			break
+		}
+		// We want name and at least one value.
+		if typ != TypeString || tmp.off+1 >= len(tmp.tape.Tape) {
+			return nil, fmt.Errorf("object: unexpected name tag %v", tmp.t)
+		}
+		start := tmp.off - 1
+		name, err := tmp.tape.stringByteAt(tmp.cur, tmp.tape.Tape[tmp.off])
+		if err != nil {
+			return nil, fmt.Errorf("getting object name: %w", err)
+		}
+		if tmp.Advance() == TypeNone {
+			return nil, fmt.Errorf("object: missing value for %q", name)
+		}
+		if _, ok := keep[string(name)]; !ok {
+			continue
+		}
+		if err := dst.appendTapeRange(&o.tape, start, tmp.off+tmp.addNext); err != nil {
+			return nil, err
+		}
+	}
+	dst.write_tape(1, byte(TagObjectEnd))
+	dst.annotate_previousloc(1, uint64(len(dst.Tape)))
+	end := len(dst.Tape)
+	dst.write_tape(0, byte(TagRoot))
+	dst.annotate_previousloc(0, uint64(len(dst.Tape)))
+
+	obj := &Object{tape: *dst, off: 2}
+	obj.tape.Tape = dst.Tape[:end]
+	return obj, nil
+}
+
+// ReplaceValue will replace the value of the first element named key with the value of value.
+// value can be from another ParsedJson. If value is a root, its content is used.
+// The new value must fit within the tape entries of the existing value,
+// otherwise ErrReplaceTooLarge is returned and the object is unchanged.
+// Unused entries are filled with nops, like SetNull does for objects and arrays.
+// Strings are copied to the string buffer of the object.
+// ErrPathNotFound is returned if the key cannot be found.
+func (o *Object) ReplaceValue(key string, value *Iter) error {
+	var e Element
+	if o.FindKey(key, &e) == nil {
+		return ErrPathNotFound
+	}
+	start, end, err := e.Iter.valueSpan()
+	if err != nil {
+		return err
+	}
+	return o.tape.replaceValue(start, end, value)
+}
+
+// Set will set the value of the first element named key to the JSON value in raw.
+// If the key exists its value is replaced like Iter.SetRawJSON,
+// otherwise the key and value are added at the end of the object.
+// Only the remaining elements of the object are searched, like FindKey.
+// If the tape must grow, only o and the ParsedJson the object was created from are updated,
+// so other iterators, objects and arrays from the same ParsedJson must be recreated before they are used.
+func (o *Object) Set(key string, raw []byte) error {
+	tmp, err := encodeRawJSON(raw)
+	if err != nil {
+		return err
+	}
+	var e Element
+	if o.FindKey(key, &e) != nil {
+		start, end, err := e.Iter.valueSpan()
+		if err != nil {
+			return err
+		}
+		delta, err := o.tape.spliceValue(start, end, tmp)
+		if err != nil {
+			return err
+		}
+		if o.off >= end {
+			o.off += delta
+		}
+		return nil
+	}
+	objEnd := len(o.tape.Tape) - 1
+	if objEnd < o.off || Tag(o.tape.Tape[objEnd]>>JSONTAGOFFSET) != TagObjectEnd {
+		return errors.New("corrupt input: object end not found")
+	}
+	elem := &ParsedJson{Strings: &TStrings{}}
+	elem.write_tape(STRINGBUFBIT, byte(TagString))
+	elem.Tape = append(elem.Tape, uint64(len(key)))
+	elem.Strings.B = append(elem.Strings.B, key...)
+	if err := elem.appendTapeRange(tmp, 0, len(tmp.Tape)); err != nil {
+		return err
+	}
+	_, err = o.tape.spliceValue(objEnd, objEnd, elem)
+	return err
+}
+
 // ErrPathNotFound is returned
 var ErrPathNotFound = errors.New("path not found")
 
 // FindPath allows searching for fields and objects by path.
-// Separate each object name by /.
-// For example `Image/Url` will search the current object for an "Image"
+// For example "Image", "Url" will search the current object for an "Image"
 // object and return the value of the "Url" element.
-// ErrPathNotFound is returned if any part of the path cannot be found.
+// When a value on the path is an array, the next element of the path
+// must be a decimal index, so "IDs", "1" returns the second element of the "IDs" array.
+// ErrPathNotFound is returned if any part of the path cannot be found,
+// or an index is out of range.
 // If the tape contains an error it will be returned.
 // The object will not be advanced.
 func (o *Object) FindPath(dst *Element, path ...string) (*Element, error) {
+	var i Iter
+	t, err := o.FindPathIter(&i, path...)
+	if err != nil {
+		return dst, err
+	}
+	if dst == nil {
+		dst = &Element{}
+	}
+	dst.Name = path[len(path)-1]
+	dst.Type = t
+	dst.Iter = i
+	return dst, nil
+}
+
+// FindPathIter works like FindPath, but only sets dst to the value found
+// and returns its type. dst must be non-nil.
+// ErrPathNotFound is returned if any part of the path cannot be found.
+// If the tape contains an error it will be returned.
+// The object will not be advanced.
+func (o *Object) FindPathIter(dst *Iter, path ...string) (Type, error) {
 	if len(path) == 0 {
-		return dst, ErrPathNotFound
+		return TypeNone, ErrPathNotFound
+	}
+	if dst == nil {
+		return TypeNone, errors.New("FindPathIter: nil destination")
 	}
 	tmp := o.tape.Iter()
 	tmp.off = o.off
@@ -264,7 +807,7 @@ func (o *Object) FindPath(dst *Element, path ...string) (*Element, error) {
 		typ := tmp.Advance()
 		// We want name and at least one value.
 		if typ != TypeString || tmp.off+1 >= len(tmp.tape.Tape) {
-			return dst, ErrPathNotFound
+			return TypeNone, ErrPathNotFound
 		}
 		// Advance must be string or end of object
 		offset := tmp.cur
@@ -274,14 +817,14 @@ func (o *Object) FindPath(dst *Element, path ...string) (*Element, error) {
 			t := tmp.Advance()
 			if t == TypeNone {
 				// Not found...
-				return dst, ErrPathNotFound
+				return TypeNone, ErrPathNotFound
 			}
 			continue
 		}
 		// Read name
 		name, err := tmp.tape.stringByteAt(offset, length)
 		if err != nil {
-			return dst, err
+			return TypeNone, err
 		}
 
 		if string(name) != key {
@@ -291,23 +834,33 @@ func (o *Object) FindPath(dst *Element, path ...string) (*Element, error) {
 		}
 		// Done...
 		if len(path) == 0 {
-			if dst == nil {
-				dst = &Element{}
-			}
-			dst.Name = key
-			dst.Type, err = tmp.AdvanceIter(&dst.Iter)
-			if err != nil {
-				return dst, err
-			}
-			return dst, nil
+			return tmp.AdvanceIter(dst)
 		}
 
 		t, err := tmp.AdvanceIter(&tmp)
 		if err != nil {
-			return dst, err
+			return TypeNone, err
+		}
+		for t == TypeArray {
+			idx, ok := parseArrayIndex(path[0])
+			if !ok {
+				return TypeNone, fmt.Errorf("path element %q is not an array index", path[0])
+			}
+			var arr Array
+			if _, err := tmp.Array(&arr); err != nil {
+				return TypeNone, err
+			}
+			key = path[0]
+			path = path[1:]
+			if len(path) == 0 {
+				return arr.elementAt(dst, idx)
+			}
+			if t, err = arr.elementAt(&tmp, idx); err != nil {
+				return TypeNone, err
+			}
 		}
 		if t != TypeObject {
-			return dst, fmt.Errorf("value of key %v is not an object", key)
+			return TypeNone, fmt.Errorf("value of key %v is not an object", key)
 		}
 		key = path[0]
 		path = path[1:]
@@ -386,6 +939,34 @@ type Element struct {
 	Iter Iter
 }
 
+// ElementPool recycles Elements, so they can be used as the destination
+// of FindElement, FindKey and FindPath without allocating.
+// An ElementPool is safe for concurrent use.
+type ElementPool struct {
+	pool sync.Pool
+}
+
+// NewElementPool returns a new, empty ElementPool.
+func NewElementPool() *ElementPool {
+	return &ElementPool{pool: sync.Pool{New: func() interface{} { return &Element{} }}}
+}
+
+// Get returns an empty Element from the pool.
+func (p *ElementPool) Get() *Element {
+	return p.pool.Get().(*Element)
+}
+
+// Put will return e to the pool.
+// e is reset, so it no longer references the parsed JSON,
+// and must not be used after it has been returned.
+func (p *ElementPool) Put(e *Element) {
+	if e == nil {
+		return
+	}
+	*e = Element{}
+	p.pool.Put(e)
+}
+
 // Elements contains all elements in an object
 // kept in original order.
 // And index contains lookup for object keys.
@@ -1,8 +1,14 @@
 package simdjson
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -17,6 +23,7 @@ func TestObject_FindPath(t *testing.T) {
 		wantType Type
 		wantVal  string
 		wantErr  bool
+		errIs    error
 	}{
 		{
 			name:     "top",
@@ -60,10 +67,54 @@ func TestObject_FindPath(t *testing.T) {
 			wantType: TypeArray,
 			wantVal:  `[116,943,234,38793]`,
 		},
+		{
+			name:     "index",
+			path:     []string{"Image", "IDs", "1"},
+			wantName: "1",
+			wantType: TypeInt,
+			wantVal:  `943`,
+		},
+		{
+			name:     "index-obj",
+			path:     []string{"Image", "Frames", "1", "Url"},
+			wantName: "Url",
+			wantType: TypeString,
+			wantVal:  `"b"`,
+		},
+		{
+			name:     "index-nested",
+			path:     []string{"Image", "Frames", "1", "Sizes", "0", "1"},
+			wantName: "1",
+			wantType: TypeInt,
+			wantVal:  `2`,
+		},
 		{
 			name:    "404",
 			path:    []string{"Image", "NonEx"},
 			wantErr: true,
+			errIs:   ErrPathNotFound,
+		},
+		{
+			name:    "index-404",
+			path:    []string{"Image", "IDs", "4"},
+			wantErr: true,
+			errIs:   ErrPathNotFound,
+		},
+		{
+			name:    "index-404-nested",
+			path:    []string{"Image", "Frames", "2", "Url"},
+			wantErr: true,
+			errIs:   ErrPathNotFound,
+		},
+		{
+			name:    "index-invalid",
+			path:    []string{"Image", "IDs", "first"},
+			wantErr: true,
+		},
+		{
+			name:    "index-scalar",
+			path:    []string{"Image", "IDs", "0", "x"},
+			wantErr: true,
 		},
 	}
 	input := `{
@@ -84,6 +135,7 @@ func TestObject_FindPath(t *testing.T) {
             "Url": "http://www.example.com/image/481989943",
             "Width": 100
         },
+        "Frames": [{"Url": "a"}, {"Url": "b", "Sizes": [[1, 2], [3]]}],
         "Title": "View from 15th Floor",
         "Width": 800
     },
@@ -111,6 +163,12 @@ func TestObject_FindPath(t *testing.T) {
 				t.Fatal(err)
 			}
 			if tt.wantErr {
+				if err == nil || tt.errIs != nil && !errors.Is(err, tt.errIs) {
+					t.Fatalf("want error %v, got %v", tt.errIs, err)
+				}
+				if tt.errIs == nil && errors.Is(err, ErrPathNotFound) {
+					t.Fatalf("want descriptive error, got %v", err)
+				}
 				return
 			}
 			if elem.Type != tt.wantType {
@@ -126,6 +184,22 @@ func TestObject_FindPath(t *testing.T) {
 			if string(ser) != tt.wantVal {
 				t.Errorf("want '%s', got '%s'", tt.wantVal, string(ser))
 			}
+
+			var iter Iter
+			typ, err := obj.FindPathIter(&iter, tt.path...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ != tt.wantType {
+				t.Errorf("FindPathIter: want type %v, got %v", tt.wantType, typ)
+			}
+			ser, err = iter.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(ser) != tt.wantVal {
+				t.Errorf("FindPathIter: want '%s', got '%s'", tt.wantVal, string(ser))
+			}
 		})
 	}
 }
@@ -330,3 +404,933 @@ func ExampleArray_DeleteElems() {
 	//Found array
 	//Modified: {"Image":{"Animated":false,"Height":600,"IDs":[943,38793]},"Alt":"Image of city"}
 }
+
+func TestObject_FindKeyInsensitive(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := `{"Url":"first","URL":"second","Id":{"n":1},"Ünï":2,"a_b":3}`
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key, name, want string
+	}{
+		{key: "url", name: "Url", want: `"first"`},
+		{key: "URL", name: "Url", want: `"first"`},
+		{key: "Url", name: "Url", want: `"first"`},
+		{key: "iD", name: "Id", want: `{"n":1}`},
+		{key: "Ünï", name: "Ünï", want: `2`},
+		{key: "üNÏ"},
+		{key: "A_B", name: "a_b", want: `3`},
+		{key: "a-b"},
+		{key: "ur"},
+		{key: "n"},
+	}
+	var dst Element
+	for _, test := range tests {
+		elem := obj.FindKeyInsensitive(test.key, &dst)
+		if test.want == "" {
+			if elem != nil {
+				t.Errorf("%s: want no match, got %v", test.key, elem.Name)
+			}
+			continue
+		}
+		if elem == nil {
+			t.Errorf("%s: not found", test.key)
+			continue
+		}
+		got, err := elem.Iter.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elem.Name != test.name || string(got) != test.want {
+			t.Errorf("%s: want %s=%s, got %s=%s", test.key, test.name, test.want, elem.Name, got)
+		}
+	}
+}
+
+func TestObject_RawMap(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := `{"a":1,"b":"two","c":{"d":[1,2,{"e":null}]},"f":[],"a":true,"g":-1.5e+30}`
+	pj, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := map[string]json.RawMessage{"old": json.RawMessage(`0`)}
+	got, err := obj.RawMap(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"old": `0`,
+		"a":   `true`,
+		"b":   `"two"`,
+		"c":   `{"d":[1,2,{"e":null}]}`,
+		"f":   `[]`,
+		"g":   `-1.5e+30`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d elements, got %d", len(want), len(got))
+	}
+	for k, v := range want {
+		if string(got[k]) != v {
+			t.Errorf("%s: want %s, got %s", k, v, got[k])
+		}
+	}
+
+	// Values can be appended to without affecting each other.
+	got["b"] = append(got["b"], "xyz"...)
+	if string(got["c"]) != want["c"] {
+		t.Errorf("append modified another value: %s", got["c"])
+	}
+
+	// Values can be decoded with encoding/json.
+	var c struct {
+		D []interface{}
+	}
+	if err := json.Unmarshal(got["c"], &c); err != nil || len(c.D) != 3 {
+		t.Errorf("unmarshal: %v, %v", c, err)
+	}
+}
+
+func TestObject_Keys(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := map[string][]string{
+		`{}`:      nil,
+		`{"a":1}`: {"a"},
+		`{"z":{"x":1,"y":[1,{"w":2}]},"b":[],"":null,"z":"again"}`: {"z", "b", "", "z"},
+	}
+	for input, want := range tests {
+		pj, err := Parse([]byte(input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		i.AdvanceInto()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := root.Object(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := []string{"keep"}
+		got, err := obj.Keys(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, append([]string{"keep"}, want...)) {
+			t.Errorf("%s: want %q, got %q", input, want, got)
+		}
+
+		// The object is not advanced.
+		cp := *obj
+		elems, err := cp.Parse(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(elems.Elements) != len(want) {
+			t.Errorf("%s: object was advanced", input)
+		}
+
+		// Truncated tapes return an error.
+		if len(want) > 0 {
+			obj.tape.Tape = obj.tape.Tape[:len(obj.tape.Tape)-1]
+			if _, err := obj.Keys(nil); err == nil {
+				t.Errorf("%s: want error for truncated tape", input)
+			}
+		}
+	}
+}
+
+func TestObject_Get(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"s":"str","i":-42,"u":18446744073709551615,"f":1.5,"b":true,"n":null}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := obj.GetString("s"); !ok || v != "str" {
+		t.Errorf("GetString: got %q, %v", v, ok)
+	}
+	if v, ok := obj.GetInt("i"); !ok || v != -42 {
+		t.Errorf("GetInt: got %d, %v", v, ok)
+	}
+	if v, ok := obj.GetFloat("f"); !ok || v != 1.5 {
+		t.Errorf("GetFloat: got %v, %v", v, ok)
+	}
+	if v, ok := obj.GetFloat("i"); !ok || v != -42 {
+		t.Errorf("GetFloat int: got %v, %v", v, ok)
+	}
+	if v, ok := obj.GetBool("b"); !ok || !v {
+		t.Errorf("GetBool: got %v, %v", v, ok)
+	}
+	// Missing keys, wrong types and overflows.
+	for _, key := range []string{"s", "u", "f", "b", "n", "missing"} {
+		if _, ok := obj.GetInt(key); ok {
+			t.Errorf("GetInt(%q): want not ok", key)
+		}
+	}
+	for _, key := range []string{"i", "b", "n", "missing"} {
+		if _, ok := obj.GetString(key); ok {
+			t.Errorf("GetString(%q): want not ok", key)
+		}
+	}
+	for _, key := range []string{"s", "b", "n", "missing"} {
+		if _, ok := obj.GetFloat(key); ok {
+			t.Errorf("GetFloat(%q): want not ok", key)
+		}
+	}
+	for _, key := range []string{"s", "i", "n", "missing"} {
+		if _, ok := obj.GetBool(key); ok {
+			t.Errorf("GetBool(%q): want not ok", key)
+		}
+	}
+}
+
+func TestObject_RequireKeys(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"id":"1","imp":[{"id":"2"}],"device":{"ua":"x"},"tmax":120,"id":7}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		keys []string
+		want []string
+	}{
+		{keys: nil, want: nil},
+		{keys: []string{"id", "imp", "device"}, want: nil},
+		{keys: []string{"site", "id", "app", "ua"}, want: []string{"site", "app", "ua"}},
+		{keys: []string{"tmax", "tmax", "bcat"}, want: []string{"bcat"}},
+	}
+	for _, test := range tests {
+		got, err := obj.RequireKeys(test.keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: want missing %v, got %v", test.keys, test.want, got)
+		}
+	}
+
+	// Only the first "id" is checked.
+	got, err := obj.RequireTypes(map[string]Type{
+		"id":     TypeString,
+		"imp":    TypeArray,
+		"device": TypeObject,
+		"tmax":   TypeFloat,
+		"site":   TypeObject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"site", "tmax"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// The object is not advanced.
+	if _, ok := obj.GetString("id"); !ok {
+		t.Error("object was advanced")
+	}
+}
+
+func TestElementPool(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := ParseND([]byte("{\"a\":{\"b\":\"x\"}}\n{\"a\":{\"b\":\"y\"}}\n{\"c\":1}\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewElementPool()
+	var got []string
+	err = pj.ForEach(func(i Iter) error {
+		elem := pool.Get()
+		defer pool.Put(elem)
+		if elem.Name != "" || elem.Type != TypeNone {
+			t.Errorf("element not reset: %+v", elem)
+		}
+		if _, err := i.FindElement(elem, "a", "b"); err != nil {
+			if !errors.Is(err, ErrPathNotFound) {
+				return err
+			}
+			got = append(got, "")
+			return nil
+		}
+		s, err := elem.Iter.String()
+		got = append(got, s)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x", "y", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		elem := pool.Get()
+		if obj.FindKey("a", elem) == nil {
+			t.Fatal("key not found")
+		}
+		pool.Put(elem)
+	})
+	if allocs > 0 {
+		t.Errorf("want no allocations, got %v", allocs)
+	}
+	pool.Put(nil)
+}
+
+func TestObject_ToValues(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"s":"a b&c","i":-1,"u":18446744073709551615,"f":1.5,"b":false,"n":null,"s":"again","o":{"x":1},"a":[1]}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obj.ToValues(); err == nil {
+		t.Error("want error for nested values")
+	}
+	v, err := obj.ToValuesSkipNested()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "b=false&f=1.5&i=-1&n=null&s=a+b%26c&s=again&u=18446744073709551615"
+	if got := v.Encode(); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// The object is not advanced.
+	if _, ok := obj.GetString("s"); !ok {
+		t.Error("object was advanced")
+	}
+	pj, err = Parse([]byte(`{"a":1,"b":"2"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = pj.Iter()
+	i.Advance()
+	if _, root, err = i.Root(nil); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err = root.Object(nil); err != nil {
+		t.Fatal(err)
+	}
+	if v, err = obj.ToValues(); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Encode(); got != "a=1&b=2" {
+		t.Errorf("want a=1&b=2, got %s", got)
+	}
+}
+
+func TestObject_AppendCSVRow(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := ParseND([]byte(`{"s":"plain","i":-1,"f":1.5,"b":true,"n":null}
+{"s":"a,b","i":2,"q":"say \"hi\"","nl":"x\ny"," ":" lead"}
+{"o":{"x":1}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{"s", "i", "f", "b", "n", "q", "nl", " ", "missing"}
+	var rows []byte
+	var obj *Object
+	i := pj.Iter()
+	for n := 0; i.Advance() == TypeRoot; n++ {
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err = root.Object(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 2 {
+			if _, err := obj.AppendCSVRow(nil, []string{"s", "o"}); err == nil {
+				t.Error("want error for nested value")
+			}
+			continue
+		}
+		rows, err = obj.AppendCSVRow(rows, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "plain,-1,1.5,true,null,,,,\n" +
+		`"a,b",2,,,,"say ""hi""","x` + "\n" + `y"," lead",` + "\n"
+	if string(rows) != want {
+		t.Errorf("want %q, got %q", want, rows)
+	}
+
+	// Read back the rows.
+	records, err := csv.NewReader(bytes.NewReader(rows)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRecords := [][]string{
+		{"plain", "-1", "1.5", "true", "null", "", "", "", ""},
+		{"a,b", "2", "", "", "", `say "hi"`, "x\ny", " lead", ""},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("want %q, got %q", wantRecords, records)
+	}
+}
+
+func TestObject_ReplaceValue(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"a":{"b":[1,2,3],"c":"d"},"s":"str","n":1e5,"z":true}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := Parse([]byte(`{"v":["x",2.5],"w":"long string"}`), nil, WithLazyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := root.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Element
+	vi := value.Iter()
+	vi.Advance()
+	_, vroot, err := vi.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vobj, err := vroot.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.ReplaceValue("a", &vobj.FindKey("v", &e).Iter); err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.ReplaceValue("n", &vobj.FindKey("w", &e).Iter); err != nil {
+		t.Fatal(err)
+	}
+	// Root values are replaced by their content.
+	str, err := Parse([]byte(`[]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := str.Iter()
+	if err := obj.ReplaceValue("s", &si); err != nil {
+		t.Fatal(err)
+	}
+	// Replacing with a larger value must fail.
+	if err := obj.ReplaceValue("z", &vobj.FindKey("w", &e).Iter); !errors.Is(err, ErrReplaceTooLarge) {
+		t.Errorf("want ErrReplaceTooLarge, got %v", err)
+	}
+	if err := obj.ReplaceValue("missing", &vi); err != ErrPathNotFound {
+		t.Errorf("want ErrPathNotFound, got %v", err)
+	}
+	// Replace the padded value again.
+	if err := obj.ReplaceValue("a", &vobj.FindKey("v", &e).Iter); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = `{"a":["x",2.5],"s":[],"n":"long string","z":true}`
+	for _, pj := range []*ParsedJson{pj, func() *ParsedJson {
+		ser := NewSerializer()
+		pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pj2
+	}()} {
+		i := pj.Iter()
+		got, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		i = pj.Iter()
+		equal, err := i.EqualJSON([]byte(want), EqualOptions{})
+		if err != nil || !equal {
+			t.Errorf("want equal, got %v (err: %v)", equal, err)
+		}
+	}
+}
+
+func TestObject_Set(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":{"b":1,"c":{}},"d":[2]}` + "\n" + `{"e":"f"}`
+	tests := []struct {
+		pointer, key, raw, want string
+	}{
+		{pointer: "/a", key: "b", raw: `2`, want: `{"a":{"b":2,"c":{}},"d":[2]}`},
+		{pointer: "/a", key: "b", raw: `{"x":[1,"y"]}`, want: `{"a":{"b":{"x":[1,"y"]},"c":{}},"d":[2]}`},
+		{pointer: "/a", key: "new", raw: `"value"`, want: `{"a":{"b":1,"c":{},"new":"value"},"d":[2]}`},
+		{pointer: "/a", key: "new", raw: `[true,{"z":null}]`, want: `{"a":{"b":1,"c":{},"new":[true,{"z":null}]},"d":[2]}`},
+		{pointer: "/a/c", key: "k", raw: `1.5`, want: `{"a":{"b":1,"c":{"k":1.5}},"d":[2]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.key+test.raw, func(t *testing.T) {
+			pj, err := ParseND([]byte(input), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := pj.Iter()
+			var elem Element
+			if _, err := root.FindPointer(&elem, test.pointer); err != nil {
+				t.Fatal(err)
+			}
+			obj, err := elem.Iter.Object(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := obj.Set(test.key, []byte(test.raw)); err != nil {
+				t.Fatal(err)
+			}
+			// The object is updated.
+			var e Element
+			if obj.FindKey(test.key, &e) == nil {
+				t.Fatalf("key %q not found after Set", test.key)
+			}
+			if got, err := e.Iter.Raw(); err != nil || string(got) != test.raw {
+				t.Errorf("value: want %s, got %s (err: %v)", test.raw, got, err)
+			}
+
+			want := test.want + "\n" + `{"e":"f"}`
+			ser := NewSerializer()
+			pj2, err := ser.Deserialize(ser.Serialize(nil, *pj), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pj := range []*ParsedJson{pj, pj2} {
+				root = pj.Iter()
+				out, err := root.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(out) != want {
+					t.Errorf("want %s, got %s", want, out)
+				}
+			}
+		})
+	}
+	pj, err := Parse([]byte(`{"a":1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := pj.Iter()
+	var elem Element
+	if _, err := root.FindPointer(&elem, ""); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := elem.Iter.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Set("b", []byte(`{invalid`)); err == nil {
+		t.Error("want error setting invalid JSON")
+	}
+}
+
+func TestObject_FilterKeys(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":1,"b":{"c":[1,"x\u00e6",{"d":null}],"e":"y"},"f":"z","a":2.50,"g":[true,false]}`
+	keep := map[string]struct{}{"a": {}, "b": {}, "g": {}, "missing": {}}
+	for _, copyStrings := range []bool{true, false} {
+		msg := []byte(input)
+		pj, err := Parse(msg, nil, WithCopyStrings(copyStrings), WithLazyNumbers(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		i.Advance()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := root.Object(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Remove a nested key, leaving nop entries on the tape.
+		elem := obj.FindKey("b", nil)
+		if elem == nil {
+			t.Fatal("b not found")
+		}
+		b, err := elem.Iter.Object(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.DeleteElems(nil, map[string]struct{}{"e": {}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var dst ParsedJson
+		filtered, err := obj.FilterKeys(keep, &dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The original is not referenced.
+		for n := range msg {
+			msg[n] = 0
+		}
+		want := `{"a":1,"b":{"c":[1,"xæ",{"d":null}]},"a":2.5,"g":[true,false]}`
+		di := dst.Iter()
+		got, err := di.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+
+		// The object can be used and serialized.
+		if v, ok := filtered.GetInt("a"); !ok || v != 1 {
+			t.Errorf("want a=1, got %v, %v", v, ok)
+		}
+		if filtered.FindKey("f", nil) != nil {
+			t.Error("f was not removed")
+		}
+		var s Serializer
+		dser, err := s.Deserialize(s.Serialize(nil, dst), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		di = dser.Iter()
+		if got, err = di.MarshalJSON(); err != nil || string(got) != want {
+			t.Errorf("want %s, got %s (err: %v)", want, got, err)
+		}
+
+		// Filtering everything returns an empty object.
+		if _, err := obj.FilterKeys(nil, &dst); err != nil {
+			t.Fatal(err)
+		}
+		di = dst.Iter()
+		if got, err = di.MarshalJSON(); err != nil || string(got) != `{}` {
+			t.Errorf("want {}, got %s (err: %v)", got, err)
+		}
+	}
+}
+
+func TestObject_Rename(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	getObject := func(t *testing.T, pj *ParsedJson) *Object {
+		t.Helper()
+		i := pj.Iter()
+		i.Advance()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := root.Object(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	for _, copyStrings := range []bool{true, false} {
+		pj, err := Parse([]byte(`{"a":1,"old":{"x":"y"},"b":"old","old":2}`), nil, WithCopyStrings(copyStrings))
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj := getObject(t, pj)
+		found, err := obj.Rename("old", "a much longer name")
+		if err != nil || !found {
+			t.Fatalf("want found, got %v, %v", found, err)
+		}
+		if found, err = obj.Rename("missing", "x"); err != nil || found {
+			t.Fatalf("want not found, got %v, %v", found, err)
+		}
+		i := pj.Iter()
+		got, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		const want = `{"a":1,"a much longer name":{"x":"y"},"b":"old","old":2}`
+		if string(got) != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+
+		// Round trip through serialization.
+		s := NewSerializer()
+		pj2, err := s.Deserialize(s.Serialize(nil, *pj), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj = getObject(t, pj2)
+		if obj.FindKey("a much longer name", nil) == nil {
+			t.Error("renamed key not found")
+		}
+		// Renaming the second key removes "old".
+		if found, err = obj.Rename("old", "o"); err != nil || !found {
+			t.Fatalf("want found, got %v, %v", found, err)
+		}
+		if obj.FindKey("old", nil) != nil {
+			t.Error("old key found after rename")
+		}
+		if v, ok := obj.GetInt("o"); !ok || v != 2 {
+			t.Errorf("want 2, got %v, %v", v, ok)
+		}
+	}
+}
+
+// TestObject_Order checks that elements are returned in document order.
+func TestObject_Order(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Keys are in neither sorted nor hash order.
+	const n = 500
+	var keys []string
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("k%d", (i*7919)%n)
+		if i%50 == 0 {
+			// Add duplicates.
+			key = "dup"
+		}
+		keys = append(keys, key)
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `"%s":[%d]`, key, i)
+	}
+	sb.WriteByte('}')
+
+	pj, err := Parse([]byte(sb.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSerializer()
+	deserialized, err := s.Deserialize(s.Serialize(nil, *pj), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pj := range []*ParsedJson{pj, deserialized} {
+		i := pj.Iter()
+		i.Advance()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := root.Object(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check := func(method string, idx int, key string, i Iter) {
+			t.Helper()
+			if key != keys[idx] {
+				t.Fatalf("%s: element %d, want key %s, got %s", method, idx, keys[idx], key)
+			}
+			arr, err := i.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := arr.AsInteger()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(v) != 1 || v[0] != int64(idx) {
+				t.Fatalf("%s: element %d, got value %v", method, idx, v)
+			}
+		}
+
+		idx := 0
+		err = obj.ForEach(func(key []byte, i Iter) {
+			check("ForEach", idx, string(key), i)
+			idx++
+		}, nil)
+		if err != nil || idx != n {
+			t.Fatalf("ForEach: got %d elements, err: %v", idx, err)
+		}
+
+		cpy := *obj
+		var tmp Iter
+		for idx = 0; ; idx++ {
+			name, typ, err := cpy.NextElement(&tmp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ == TypeNone {
+				break
+			}
+			check("NextElement", idx, name, tmp)
+		}
+		if idx != n {
+			t.Fatalf("NextElement: got %d elements", idx)
+		}
+
+		cpy = *obj
+		elems, err := cpy.Parse(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(elems.Elements) != n {
+			t.Fatalf("Parse: got %d elements", len(elems.Elements))
+		}
+		for idx, e := range elems.Elements {
+			check("Parse", idx, e.Name, e.Iter)
+		}
+
+		got, err := root.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != sb.String() {
+			t.Error("MarshalJSON changed order")
+		}
+	}
+}
+
+// TestArray_Order checks that elements are returned in index order.
+func TestArray_Order(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const n = 1000
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		if i%2 == 0 {
+			fmt.Fprintf(&sb, "%d", i)
+		} else {
+			fmt.Fprintf(&sb, `{"v":%d}`, i)
+		}
+	}
+	sb.WriteByte(']')
+	pj, err := Parse([]byte(sb.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	i.Advance()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := root.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := func(i Iter) int64 {
+		if i.Type() == TypeObject {
+			obj, err := i.Object(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _ := obj.GetInt("v")
+			return v
+		}
+		v, err := i.Int()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	idx := int64(0)
+	arr.ForEach(func(i Iter) {
+		if got := value(i); got != idx {
+			t.Fatalf("ForEach: element %d, got %d", idx, got)
+		}
+		idx++
+	})
+	if idx != n {
+		t.Fatalf("ForEach: got %d elements", idx)
+	}
+	it := arr.Iter()
+	for idx = 0; it.Advance() != TypeNone; idx++ {
+		if got := value(it); got != idx {
+			t.Fatalf("Iter: element %d, got %d", idx, got)
+		}
+	}
+	if idx != n {
+		t.Fatalf("Iter: got %d elements", idx)
+	}
+}
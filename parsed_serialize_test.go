@@ -18,6 +18,9 @@ package simdjson
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"reflect"
 	"sync"
 	"testing"
 )
@@ -68,6 +71,49 @@ func BenchmarkSerialize(b *testing.B) {
 	})
 }
 
+func BenchmarkSerializeConcurrency(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	inputs := []struct {
+		name string
+		data []byte
+	}{
+		{name: "demo", data: []byte(demo_json)},
+		{name: "payload-small", data: loadCompressed(b, "payload-small")},
+		{name: "payload-medium", data: loadCompressed(b, "payload-medium")},
+		{name: "github_events", data: loadCompressed(b, "github_events")},
+		{name: "twitter", data: loadCompressed(b, "twitter")},
+		{name: "citm_catalog", data: loadCompressed(b, "citm_catalog")},
+	}
+	for _, in := range inputs {
+		pj, err := Parse(in.data, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, conc := range []bool{false, true} {
+			name := in.name + "/sync"
+			if conc {
+				name = in.name + "/concurrent"
+			}
+			b.Run(name, func(b *testing.B) {
+				s := NewSerializer()
+				s.ConcurrencyThreshold(-1)
+				if conc {
+					s.ConcurrencyThreshold(0)
+				}
+				output := s.Serialize(nil, *pj)
+				b.SetBytes(int64(len(in.data)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					output = s.Serialize(output[:0], *pj)
+				}
+			})
+		}
+	}
+}
+
 func BenchmarkDeSerialize(b *testing.B) {
 	if !SupportedCPU() {
 		b.SkipNow()
@@ -333,3 +379,359 @@ func TestDeSerializeJSON(t *testing.T) {
 		test(b, s)
 	})
 }
+
+func TestReadSerializedHeader(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mode         CompressMode
+		tags, values BlockCompression
+	}{
+		{mode: CompressNone, tags: BlockUncompressed, values: BlockUncompressed},
+		{mode: CompressFast, tags: BlockS2, values: BlockS2},
+		{mode: CompressDefault, tags: BlockZstd, values: BlockS2},
+		{mode: CompressBest, tags: BlockZstd, values: BlockZstd},
+	}
+	for _, test := range tests {
+		s := NewSerializer()
+		s.CompressMode(test.mode)
+		out := s.Serialize(nil, *pj)
+		// Add another block to check size.
+		out = s.Serialize(out, *pj)
+		h, err := ReadSerializedHeader(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Version != serializedVersion {
+			t.Errorf("want version %d, got %d", serializedVersion, h.Version)
+		}
+		if h.Size != len(out)/2 {
+			t.Errorf("want size %d, got %d", len(out)/2, h.Size)
+		}
+		if h.TapeEntries != len(pj.Tape) {
+			t.Errorf("want %d tape entries, got %d", len(pj.Tape), h.TapeEntries)
+		}
+		if h.Strings.Size != 0 || h.Strings.CompressedSize != 0 {
+			t.Errorf("want empty strings block, got %+v", h.Strings)
+		}
+		if h.Tags.Compression != test.tags || h.Values.Compression != test.values {
+			t.Errorf("%v: want tags %v values %v, got %v, %v", test.mode, test.tags, test.values, h.Tags.Compression, h.Values.Compression)
+		}
+		if h.Tags.Size == 0 || h.Values.Size == 0 || h.Message.Size == 0 {
+			t.Errorf("unexpected empty block: %+v", h)
+		}
+		blocks := h.Strings.CompressedSize + h.Message.CompressedSize + h.Tags.CompressedSize + h.Values.CompressedSize
+		if blocks >= h.Size {
+			t.Errorf("blocks (%d) larger than total (%d)", blocks, h.Size)
+		}
+		if test.mode == CompressNone && h.Tags.Size != h.Tags.CompressedSize {
+			t.Errorf("uncompressed size mismatch: %+v", h.Tags)
+		}
+		if _, err := ReadSerializedHeader(out[:h.Size-1]); err == nil {
+			t.Error("want error on truncated input")
+		}
+	}
+}
+
+func TestSerializeCopyModes(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Whitespace and numbers are not stored, repeated strings are stored once.
+	const input = `{ "key" : "value",    "esc\n": "q\"uo\u0041",
+		"list": [ "value", "value", 1.25e3, 12345678,   "\"", "a\"b", "\"c", "z" ],
+		"key2": { "key": "value" } }`
+	const uniqueStrings = len("key") + len("value") + len("esc\n") + len(`q"uoA`) + len("list") +
+		len(`"`) + len(`a"b`) + len(`"c`) + len("z") + len("key2")
+	for _, test := range []struct {
+		name string
+		opts []ParserOption
+	}{
+		{name: "copy"},
+		{name: "nocopy", opts: []ParserOption{WithCopyStrings(false)}},
+		{name: "nocopy-keys", opts: []ParserOption{WithCopyKeys(false)}},
+		{name: "preserve", opts: []ParserOption{WithPreserveEscapes(true)}},
+		{name: "intern", opts: []ParserOption{WithInternStrings(true)}},
+		{name: "lazy", opts: []ParserOption{WithLazyNumbers(true), WithCopyStrings(false)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pj, err := Parse([]byte(input), nil, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			want, err := i.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewSerializer()
+			s.CompressMode(CompressNone)
+			out := s.Serialize(nil, *pj)
+			h, err := ReadSerializedHeader(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Strings.Size != 0 || h.Message.Size != uniqueStrings || s.LastStats().UniqueStringBytes != uniqueStrings {
+				t.Errorf("want %d string bytes, got strings: %+v, message: %+v", uniqueStrings, h.Strings, h.Message)
+			}
+
+			// Reuse the parsed destination.
+			got, err := s.Deserialize(out, pj.Clone(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Message) != 0 || len(got.Strings.B) != uniqueStrings {
+				t.Errorf("want empty message and %d string bytes, got %d and %d", uniqueStrings, len(got.Message), len(got.Strings.B))
+			}
+			// Lazy numbers are converted, so compare values.
+			i = got.Iter()
+			if equal, err := i.EqualJSON([]byte(input), EqualOptions{}); err != nil || !equal {
+				i = got.Iter()
+				gotJSON, _ := i.MarshalJSON()
+				t.Errorf("want %s\ngot  %s (err: %v)", want, gotJSON, err)
+			}
+			// Raw values must not include neighbouring strings.
+			i = got.Iter()
+			elem, err := i.FindElement(nil, "list")
+			if err != nil {
+				t.Fatal(err)
+			}
+			arr, err := elem.Iter.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var raw []string
+			arr.ForEach(func(i Iter) {
+				if i.Type() == TypeString {
+					b, err := i.Raw()
+					if err != nil {
+						t.Fatal(err)
+					}
+					raw = append(raw, string(b))
+				}
+			})
+			wantRaw := []string{`"value"`, `"value"`, `"\""`, `"a\"b"`, `"\"c"`, `"z"`}
+			if !reflect.DeepEqual(raw, wantRaw) {
+				t.Errorf("want raw %v, got %v", wantRaw, raw)
+			}
+		})
+	}
+}
+
+func TestSerializerLastStats(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := ParseND([]byte(demo_ndjson), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantStrings, wantBytes int
+	uniq := make(map[string]struct{})
+	for idx := 0; idx < len(pj.Tape); idx++ {
+		tag := Tag(pj.Tape[idx] >> JSONTAGOFFSET)
+		switch tag {
+		case TagString:
+			s, err := pj.stringAt(pj.Tape[idx]&JSONVALUEMASK, pj.Tape[idx+1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantStrings++
+			wantBytes += len(s)
+			uniq[s] = struct{}{}
+			idx++
+		case TagInteger, TagUint, TagFloat:
+			idx++
+		}
+	}
+	s := NewSerializer()
+	if got := s.LastStats(); got != (SerializeStats{}) {
+		t.Errorf("want empty stats, got %+v", got)
+	}
+	out := s.Serialize([]byte("prefix"), *pj)
+	st := s.LastStats()
+	if st.Strings != wantStrings || st.StringBytes != wantBytes {
+		t.Errorf("want %d strings with %d bytes, got %d with %d bytes", wantStrings, wantBytes, st.Strings, st.StringBytes)
+	}
+	if st.UniqueStrings != len(uniq) || st.UniqueStrings >= st.Strings {
+		t.Errorf("want %d unique strings, got %d", len(uniq), st.UniqueStrings)
+	}
+	if st.UniqueStringBytes >= st.StringBytes {
+		t.Errorf("unique string bytes %d not less than %d", st.UniqueStringBytes, st.StringBytes)
+	}
+	if st.TagsSize == 0 || st.ValuesSize == 0 || st.TagsCompressed == 0 || st.ValuesCompressed == 0 || st.StringsCompressed == 0 {
+		t.Errorf("unexpected block sizes: %+v", st)
+	}
+	if st.Total != len(out)-len("prefix") {
+		t.Errorf("want total %d, got %d", len(out)-len("prefix"), st.Total)
+	}
+}
+
+func TestSerializer_SerializeTo(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse(loadCompressed(t, tt.name), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewSerializer()
+			want := s.Serialize(nil, *pj)
+			wantStats := s.LastStats()
+			var buf bytes.Buffer
+			if err := s.SerializeTo(&buf, *pj); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output mismatch, got %d bytes, want %d", buf.Len(), len(want))
+			}
+			if got := s.LastStats(); got != wantStats {
+				t.Errorf("want stats %+v, got %+v", wantStats, got)
+			}
+			pj2, err := s.Deserialize(buf.Bytes(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i, i2 := pj.Iter(), pj2.Iter()
+			if equal, err := i.Equal(i2); err != nil || !equal {
+				t.Errorf("deserialized value differs (err: %v)", err)
+			}
+		})
+	}
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errWrite := errors.New("write failed")
+	if err := NewSerializer().SerializeTo(failWriter{err: errWrite}, *pj); err != errWrite {
+		t.Errorf("want %v, got %v", errWrite, err)
+	}
+}
+
+func TestSerializeNDStreamSplitBlocks(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := loadCompressed(t, "parking-citations")
+	// Send several blocks of lines, ending the stream like ParseNDStream.
+	res := make(chan Stream, 10)
+	go func() {
+		defer close(res)
+		lines := bytes.SplitAfter(bytes.TrimSpace(input), []byte{'\n'})
+		for len(lines) > 0 {
+			n := 100
+			if n > len(lines) {
+				n = len(lines)
+			}
+			pj, err := ParseND(bytes.Join(lines[:n], nil), nil)
+			res <- Stream{Value: pj, Error: err}
+			lines = lines[n:]
+		}
+		res <- Stream{Error: io.EOF}
+	}()
+	var buf bytes.Buffer
+	if err := serializeNDStream(&buf, res, nil, 4, CompressFast); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSerializer()
+	blocks := make(chan []byte, 10)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.splitBlocks(bytes.NewReader(buf.Bytes()), blocks)
+	}()
+	var got []byte
+	var pj *ParsedJson
+	n := 0
+	for block := range blocks {
+		var err error
+		pj, err = s.Deserialize(block, pj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 0 {
+			got = append(got, '\n')
+		}
+		i := pj.Iter()
+		got, err = i.MarshalJSONBuffer(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if err := <-errCh; err != io.EOF {
+		t.Fatalf("want io.EOF, got %v", err)
+	}
+	if n < 2 {
+		t.Errorf("want several blocks, got %d", n)
+	}
+	want, err := ParseND(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIter := want.Iter()
+	wantJSON, err := wantIter.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, wantJSON) {
+		t.Errorf("output mismatch, got %d bytes, want %d", len(got), len(wantJSON))
+	}
+
+	// Older versions are accepted, unknown versions are not.
+	block := s.Serialize(nil, *want)
+	block[0] = 2
+	blocks = make(chan []byte, 1)
+	go func() {
+		errCh <- s.splitBlocks(bytes.NewReader(block), blocks)
+	}()
+	for range blocks {
+	}
+	if err := <-errCh; err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+	block[0] = serializedVersion + 1
+	blocks = make(chan []byte, 1)
+	if err := s.splitBlocks(bytes.NewReader(block), blocks); err == nil || err == io.EOF {
+		t.Errorf("want unknown version error, got %v", err)
+	}
+}
+
+func TestDeserializeCorruptBlock(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	s := NewSerializer()
+	s.CompressMode(CompressNone)
+
+	// Uncompressed block with a different size than expected.
+	for _, size := range []int{3, 5} {
+		var wg sync.WaitGroup
+		var dstErr error
+		block := append([]byte{byte(size + 1), blockTypeUncompressed}, make([]byte, size)...)
+		err := s.decBlock(bytes.NewBuffer(block), make([]byte, 4), &wg, &dstErr)
+		wg.Wait()
+		if err == nil {
+			t.Errorf("size %d: want error", size)
+		}
+	}
+
+	pj, err := Parse([]byte(demo_json), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := s.Serialize(nil, *pj)
+	// Truncated input must return an error.
+	for n := 0; n < len(output); n++ {
+		if _, err := s.Deserialize(output[:n], nil); err == nil {
+			t.Errorf("truncated to %d: want error", n)
+		}
+	}
+}
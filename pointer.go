@@ -0,0 +1,203 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FindPointer returns the value referenced by a JSON Pointer (RFC 6901),
+// for example `/Image/Thumbnail/Url` or `/items/0/name`.
+// Object keys are matched exactly with `~1` decoding to `/` and `~0` to `~`.
+// Array elements are referenced by their zero-based index.
+// An empty pointer references the value itself.
+// If no value is queued, the next value is used.
+// If the value is a root, its content is used.
+//
+// ErrPathNotFound is returned if a key does not exist, an index is out of range
+// or a scalar value is reached before the end of the pointer.
+// The index `-` is always out of range.
+// The name of the returned element is the last token of the pointer, decoded.
+// If dst is non-nil it is used and no allocations are made unless the last token contains escapes.
+// The iter will *not* be advanced.
+func (i *Iter) FindPointer(dst *Element, pointer string) (*Element, error) {
+	if err := validatePointer(pointer); err != nil {
+		return dst, err
+	}
+	cp := *i
+	if cp.Type() == TypeNone {
+		cp.Advance()
+	}
+	if cp.Type() == TypeRoot {
+		if _, _, err := cp.Root(&cp); err != nil {
+			return dst, err
+		}
+	}
+	for pointer != "" {
+		var tok string
+		tok, pointer = nextPointerToken(pointer)
+		var err error
+		switch cp.t {
+		case TagObjectStart:
+			var obj Object
+			if _, err = cp.Object(&obj); err == nil {
+				_, err = obj.findPointerToken(&cp, tok)
+			}
+		case TagArrayStart:
+			var arr Array
+			if _, err = cp.Array(&arr); err == nil {
+				_, err = arr.findPointerIndex(&cp, tok)
+			}
+		default:
+			err = ErrPathNotFound
+		}
+		if err != nil {
+			return dst, err
+		}
+		if pointer == "" {
+			return setPointerElement(dst, tok, &cp), nil
+		}
+	}
+	return setPointerElement(dst, "", &cp), nil
+}
+
+// FindPointer returns the value referenced by a JSON Pointer (RFC 6901)
+// relative to the object.
+// See Iter.FindPointer for details.
+// The pointer must not be empty.
+// The object will not be advanced.
+func (o *Object) FindPointer(dst *Element, pointer string) (*Element, error) {
+	if pointer == "" {
+		return dst, ErrPathNotFound
+	}
+	if err := validatePointer(pointer); err != nil {
+		return dst, err
+	}
+	tok, rest := nextPointerToken(pointer)
+	var i Iter
+	if _, err := o.findPointerToken(&i, tok); err != nil {
+		return dst, err
+	}
+	if rest == "" {
+		return setPointerElement(dst, tok, &i), nil
+	}
+	return i.FindPointer(dst, rest)
+}
+
+// validatePointer returns an error if pointer is not a valid JSON Pointer.
+func validatePointer(pointer string) error {
+	if pointer != "" && pointer[0] != '/' {
+		return fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+	for i := 0; i < len(pointer); i++ {
+		if pointer[i] == '~' && (i+1 == len(pointer) || (pointer[i+1] != '0' && pointer[i+1] != '1')) {
+			return fmt.Errorf("invalid JSON pointer %q: invalid escape at offset %d", pointer, i)
+		}
+	}
+	return nil
+}
+
+// nextPointerToken returns the first token of a non-empty, valid pointer
+// and the remaining pointer. The token is not decoded.
+func nextPointerToken(pointer string) (tok, rest string) {
+	pointer = pointer[1:]
+	if end := strings.IndexByte(pointer, '/'); end >= 0 {
+		return pointer[:end], pointer[end:]
+	}
+	return pointer, ""
+}
+
+// pointerTokenEqual returns whether name equals the encoded pointer token tok.
+func pointerTokenEqual(name []byte, tok string) bool {
+	n := 0
+	for i := 0; i < len(tok); i++ {
+		c := tok[i]
+		if c == '~' {
+			i++
+			c = '~'
+			if tok[i] == '1' {
+				c = '/'
+			}
+		}
+		if n >= len(name) || name[n] != c {
+			return false
+		}
+		n++
+	}
+	return n == len(name)
+}
+
+// findPointerToken sets dst to the value of the first key matching the encoded pointer token tok.
+func (o *Object) findPointerToken(dst *Iter, tok string) (Type, error) {
+	obj := *o
+	for {
+		name, t, err := obj.NextElementBytes(dst)
+		if err != nil {
+			return TypeNone, err
+		}
+		if t == TypeNone {
+			return TypeNone, ErrPathNotFound
+		}
+		if pointerTokenEqual(name, tok) {
+			return t, nil
+		}
+	}
+}
+
+// findPointerIndex sets dst to the array element at the index in the pointer token tok.
+func (a *Array) findPointerIndex(dst *Iter, tok string) (Type, error) {
+	idx, ok := parseArrayIndex(tok)
+	if !ok {
+		// Includes '-', which references the element after the last.
+		return TypeNone, ErrPathNotFound
+	}
+	return a.elementAt(dst, idx)
+}
+
+// parseArrayIndex parses a decimal array index without sign or leading zeros.
+// Indexes that overflow an int are returned as -1.
+func parseArrayIndex(s string) (int, bool) {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	idx, err := strconv.Atoi(s)
+	if err != nil {
+		return -1, true
+	}
+	return idx, true
+}
+
+// setPointerElement sets dst to i with the name of the encoded pointer token tok.
+func setPointerElement(dst *Element, tok string, i *Iter) *Element {
+	if dst == nil {
+		dst = &Element{}
+	}
+	if strings.IndexByte(tok, '~') >= 0 {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+	}
+	dst.Name = tok
+	dst.Type = i.Type()
+	dst.Iter = *i
+	return dst
+}
@@ -0,0 +1,145 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindPointer(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Example from RFC 6901, section 5.
+	const doc = `{
+      "foo": ["bar", "baz"],
+      "": 0,
+      "a/b": 1,
+      "c%d": 2,
+      "e^f": 3,
+      "g|h": 4,
+      "i\\j": 5,
+      "k\"l": 6,
+      " ": 7,
+      "m~n": 8,
+      "items": [{"name": "first"}, {"name": "second", "tags": [[], [true]]}]
+   }`
+	pj, err := Parse([]byte(doc), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pointer string
+		want    string
+		name    string
+		err     error
+	}{
+		{pointer: "", want: `{"foo":["bar","baz"],"":0,"a/b":1,"c%d":2,"e^f":3,"g|h":4,"i\\j":5,"k\"l":6," ":7,"m~n":8,"items":[{"name":"first"},{"name":"second","tags":[[],[true]]}]}`},
+		{pointer: "/foo", want: `["bar","baz"]`, name: "foo"},
+		{pointer: "/foo/0", want: `"bar"`, name: "0"},
+		{pointer: "/foo/1", want: `"baz"`, name: "1"},
+		{pointer: "/", want: `0`},
+		{pointer: "/a~1b", want: `1`, name: "a/b"},
+		{pointer: "/c%d", want: `2`, name: "c%d"},
+		{pointer: "/e^f", want: `3`, name: "e^f"},
+		{pointer: "/g|h", want: `4`, name: "g|h"},
+		{pointer: "/i\\j", want: `5`, name: "i\\j"},
+		{pointer: "/k\"l", want: `6`, name: "k\"l"},
+		{pointer: "/ ", want: `7`, name: " "},
+		{pointer: "/m~0n", want: `8`, name: "m~n"},
+		{pointer: "/items/1/name", want: `"second"`, name: "name"},
+		{pointer: "/items/1/tags/1/0", want: `true`, name: "0"},
+		{pointer: "/items/1/tags/0", want: `[]`, name: "0"},
+		{pointer: "/missing", err: ErrPathNotFound},
+		{pointer: "/foo/2", err: ErrPathNotFound},
+		{pointer: "/foo/-", err: ErrPathNotFound},
+		{pointer: "/foo/01", err: ErrPathNotFound},
+		{pointer: "/foo/", err: ErrPathNotFound},
+		{pointer: "/foo/99999999999999999999999", err: ErrPathNotFound},
+		{pointer: "/foo/0/x", err: ErrPathNotFound},
+		{pointer: "/items/1/tags/0/0", err: ErrPathNotFound},
+		{pointer: "/a/b", err: ErrPathNotFound},
+		{pointer: "foo"},
+		{pointer: "/m~2n"},
+		{pointer: "/m~"},
+	}
+	root := pj.Iter()
+	root.Advance()
+	_, content, err := root.Root(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := content.Object(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		i := pj.Iter()
+		var dst Element
+		elem, err := i.FindPointer(&dst, test.pointer)
+		if test.want == "" {
+			if err == nil || test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("%q: want error %v, got %v", test.pointer, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.pointer, err)
+			continue
+		}
+		if elem != &dst {
+			t.Errorf("%q: dst was not used", test.pointer)
+		}
+		got, err := elem.Iter.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want || elem.Name != test.name {
+			t.Errorf("%q: want %s (name %q), got %s (name %q)", test.pointer, test.want, test.name, got, elem.Name)
+		}
+
+		// The same results from the object, except for the empty pointer.
+		if test.pointer == "" {
+			continue
+		}
+		elem, err = obj.FindPointer(nil, test.pointer)
+		if err != nil {
+			t.Errorf("%q: object: %v", test.pointer, err)
+			continue
+		}
+		got, err = elem.Iter.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want || elem.Name != test.name {
+			t.Errorf("%q: object: want %s (name %q), got %s (name %q)", test.pointer, test.want, test.name, got, elem.Name)
+		}
+	}
+
+	// No allocations with a destination.
+	i := pj.Iter()
+	var dst Element
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := i.FindPointer(&dst, "/items/1/tags/1/0"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("want no allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// processBatchSize is the number of input bytes processed as one unit by ProcessNDStream.
+const processBatchSize = 1 << 20
+
+// ProcessNDStream will read newline delimited JSON from in, call fn with each record
+// and write the possibly modified records to out as newline delimited JSON.
+//
+// Each line is parsed separately with the supplied options and fn is called with the top level value.
+// The value can be modified with the Set functions on Iter before it is written.
+// Empty lines are skipped.
+//
+// Lines that cannot be parsed, or where fn returns an error, are written to deadLetter
+// and processing continues with the next line.
+// Each failed line is written as a JSON object followed by a newline:
+//
+//	{"line":3,"error":"...","input":"..."}
+//
+// line is the 1-based line number in the input, error is the error text and
+// input contains the line as a JSON string. Invalid UTF-8 in the input is kept as is.
+// If deadLetter is nil failed lines are discarded.
+//
+// Input is split into batches of lines that are processed on up to concurrency goroutines.
+// If concurrency is <= 0, GOMAXPROCS is used.
+// fn may therefore be called concurrently, but the output is written in input order.
+// The number of batches held in memory is limited to a small multiple of concurrency.
+//
+// Read errors, except io.EOF, and write errors stop processing and are returned.
+// Output for lines before the error may be written.
+func ProcessNDStream(in io.Reader, out, deadLetter io.Writer, concurrency int, fn func(root Iter) error, opts ...ParserOption) error {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan *processBatch, concurrency)
+	queue := make(chan *processBatch, concurrency)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			var pj *ParsedJson
+			for b := range jobs {
+				pj = b.process(pj, fn, opts)
+				close(b.done)
+			}
+		}()
+	}
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- writeProcessed(queue, out, deadLetter, stop)
+	}()
+
+	readErr := readProcessBatches(in, jobs, queue, stop)
+	close(jobs)
+	close(queue)
+	wg.Wait()
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	return readErr
+}
+
+// processBatch contains a number of input lines and the output from processing them.
+type processBatch struct {
+	data  []byte
+	lines []processLine
+
+	out, dead []byte
+	// done is closed when out and dead have been filled.
+	done chan struct{}
+}
+
+// processLine is a line in processBatch.data.
+type processLine struct {
+	n          int
+	start, end int
+}
+
+// readProcessBatches will read batches of lines from in
+// and send each to both the jobs and queue channels.
+// Returns nil if stop is closed.
+func readProcessBatches(in io.Reader, jobs, queue chan<- *processBatch, stop <-chan struct{}) error {
+	br := bufio.NewReaderSize(in, 64<<10)
+	b := &processBatch{done: make(chan struct{})}
+	for n := 1; ; n++ {
+		start := len(b.data)
+		var err error
+		for {
+			var l []byte
+			l, err = br.ReadSlice('\n')
+			b.data = append(b.data, l...)
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(b.data[start:])) > 0 {
+			b.lines = append(b.lines, processLine{n: n, start: start, end: len(b.data)})
+		} else {
+			b.data = b.data[:start]
+		}
+		if len(b.lines) > 0 && (len(b.data) >= processBatchSize || err == io.EOF) {
+			// Send to workers first, so the batch is always processed.
+			select {
+			case jobs <- b:
+			case <-stop:
+				return nil
+			}
+			select {
+			case queue <- b:
+			case <-stop:
+				return nil
+			}
+			b = &processBatch{done: make(chan struct{})}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// process will process all lines in b.
+// pj is reused for parsing and the last parsed value is returned.
+func (b *processBatch) process(pj *ParsedJson, fn func(root Iter) error, opts []ParserOption) *ParsedJson {
+	for _, l := range b.lines {
+		line := b.data[l.start:l.end]
+		parsed, err := Parse(line, pj, opts...)
+		if err != nil {
+			b.dead = appendDeadLetter(b.dead, l.n, err, line)
+			continue
+		}
+		pj = parsed
+		start := len(b.out)
+		err = pj.ForEach(func(i Iter) error {
+			if err := fn(i); err != nil {
+				return err
+			}
+			var err error
+			b.out, err = i.MarshalJSONBuffer(b.out)
+			b.out = append(b.out, '\n')
+			return err
+		})
+		if err != nil {
+			b.out = b.out[:start]
+			b.dead = appendDeadLetter(b.dead, l.n, err, line)
+		}
+	}
+	return pj
+}
+
+// appendDeadLetter appends the dead letter record of a failed line to dst.
+func appendDeadLetter(dst []byte, line int, err error, input []byte) []byte {
+	dst = append(dst, `{"line":`...)
+	dst = strconv.AppendInt(dst, int64(line), 10)
+	dst = append(dst, `,"error":"`...)
+	dst = escapeBytes(dst, []byte(err.Error()))
+	dst = append(dst, `","input":"`...)
+	dst = escapeBytes(dst, bytes.TrimRight(input, "\r\n"))
+	return append(dst, "\"}\n"...)
+}
+
+// writeProcessed will write the output of batches from queue in order.
+// If writing fails stop is closed, and the remaining batches are discarded.
+func writeProcessed(queue <-chan *processBatch, out, deadLetter io.Writer, stop chan<- struct{}) error {
+	var err error
+	for b := range queue {
+		if err != nil {
+			continue
+		}
+		<-b.done
+		if len(b.out) > 0 {
+			_, err = out.Write(b.out)
+		}
+		if err == nil && len(b.dead) > 0 && deadLetter != nil {
+			_, err = deadLetter.Write(b.dead)
+		}
+		if err != nil {
+			close(stop)
+		}
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestProcessNDStream(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Enough lines to span several batches.
+	const lines = 40000
+	var input, want strings.Builder
+	for n := 1; n <= lines; n++ {
+		switch {
+		case n%1000 == 7:
+			input.WriteString("{\"id\":" + fmt.Sprint(n) + ",\"v\":}\n")
+		case n%1000 == 500:
+			input.WriteString("\r\n")
+		default:
+			fmt.Fprintf(&input, "{\"id\":%d,\"v\":%d,\"pad\":\"%s\"}\n", n, n, strings.Repeat("x", n%50))
+			if n%1000 != 3 {
+				fmt.Fprintf(&want, "{\"id\":%d,\"v\":%d,\"pad\":\"%s\"}\n", n, 2*n, strings.Repeat("x", n%50))
+			}
+		}
+	}
+	errOdd := errors.New("rejected")
+	fn := func(root Iter) error {
+		obj, err := root.Object(nil)
+		if err != nil {
+			return err
+		}
+		id, err := obj.FindKey("id", nil).Iter.Int()
+		if err != nil {
+			return err
+		}
+		if id%1000 == 3 {
+			return errOdd
+		}
+		v := obj.FindKey("v", nil)
+		n, err := v.Iter.Int()
+		if err != nil {
+			return err
+		}
+		return v.Iter.SetInt(2 * n)
+	}
+
+	for _, conc := range []int{1, 4, 0} {
+		t.Run(fmt.Sprint(conc), func(t *testing.T) {
+			var out, dead bytes.Buffer
+			err := ProcessNDStream(iotest.HalfReader(strings.NewReader(input.String())), &out, &dead, conc, fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != want.String() {
+				t.Fatalf("output mismatch, got %d bytes, want %d", out.Len(), want.Len())
+			}
+			deadLines := strings.Split(strings.TrimSpace(dead.String()), "\n")
+			if len(deadLines) != 2*lines/1000 {
+				t.Fatalf("want %d dead letters, got %d", 2*lines/1000, len(deadLines))
+			}
+			for i, l := range deadLines {
+				var rec struct {
+					Line  int
+					Error string
+					Input string
+				}
+				if err := json.Unmarshal([]byte(l), &rec); err != nil {
+					t.Fatalf("%s: %v", l, err)
+				}
+				wantLine := (i/2)*1000 + 3
+				if i%2 == 1 {
+					wantLine += 4
+				}
+				if rec.Line != wantLine || rec.Error == "" || !strings.HasPrefix(rec.Input, `{"id":`+fmt.Sprint(wantLine)+`,`) {
+					t.Errorf("dead letter %d: unexpected %s", i, l)
+				}
+				if i%2 == 0 && rec.Error != errOdd.Error() {
+					t.Errorf("dead letter %d: want error %v, got %v", i, errOdd, rec.Error)
+				}
+			}
+		})
+	}
+
+	// Write errors stop processing.
+	errWrite := errors.New("write failed")
+	err := ProcessNDStream(strings.NewReader(input.String()), failWriter{err: errWrite}, nil, 2, fn)
+	if !errors.Is(err, errWrite) {
+		t.Errorf("want %v, got %v", errWrite, err)
+	}
+
+	// Read errors are returned.
+	err = ProcessNDStream(iotest.ErrReader(errWrite), &bytes.Buffer{}, nil, 2, fn)
+	if !errors.Is(err, errWrite) {
+		t.Errorf("want %v, got %v", errWrite, err)
+	}
+}
@@ -22,11 +22,13 @@ package simdjson
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"runtime"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/klauspost/cpuid/v2"
 )
@@ -47,12 +49,30 @@ func newInternalParsedJson(reuse *ParsedJson, opts []ParserOption) (*internalPar
 		pj = reuse.internal
 		pj.ParsedJson = *reuse
 		pj.ParsedJson.internal = nil
-		reuse = &ParsedJson{}
 	}
 	if pj == nil {
 		pj = &internalParsedJson{}
 	}
-	pj.copyStrings = true
+	pj.copyKeys, pj.copyValues = true, true
+	pj.preserveEscapes = false
+	pj.skipBadLines = false
+	pj.strict = false
+	pj.duplicateKeys = DuplicateKeysAllow
+	pj.aborted = false
+	pj.internStrings = false
+	pj.leadingPlus = false
+	pj.inputPadding = 0
+	pj.singleAlloc = false
+	pj.lazyNumbers = false
+	pj.maxElements = 0
+	pj.sourceSpans = false
+	pj.rejectEmptyKeys = false
+	pj.rejectEmptyContainers = false
+	pj.singleQuotes = false
+	pj.collectWarnings = false
+	pj.validateUTF8 = false
+	pj.allowScalarRoot = false
+	pj.bigNumbers = false
 	for _, opt := range opts {
 		if err := opt(pj); err != nil {
 			return nil, err
@@ -62,13 +82,51 @@ func newInternalParsedJson(reuse *ParsedJson, opts []ParserOption) (*internalPar
 }
 
 // Parse an object or array from a block of data and return the parsed JSON.
+// Other values are accepted at the top level when using WithStrictRFC8259.
+// Whitespace before and after the value is ignored.
+// If b is empty or only contains whitespace ErrEmptyInput is returned.
 // An optional block of previously parsed json can be supplied to reduce allocations.
+// If reuse was returned by a previous call to Parse or ParseND the internal
+// stage 1 index buffers and channels are also kept, so setup cost is avoided
+// when parsing many small documents in sequence.
+// A reused ParsedJson must not be used concurrently or after it has been supplied.
 func Parse(b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	return ParseContext(context.Background(), b, reuse, opts...)
+}
+
+// ParseContext will parse like Parse, but stop parsing if the context is cancelled.
+// The context is checked for every block of structural indexes found,
+// and every 1024 values while the tape is built.
+// ctx.Err() is returned if parsing was stopped.
+func ParseContext(ctx context.Context, b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	pj, err := newInternalParsedJson(reuse, opts)
 	if err != nil {
 		return nil, err
 	}
-	err = pj.parseMessage(b, false)
+	if err := pj.checkInputPadding(b); err != nil {
+		return nil, err
+	}
+	if pj.validateUTF8 && !utf8.Valid(b) {
+		return nil, errInvalidUTF8
+	}
+	pj.done = ctx.Done()
+	if pj.strict {
+		err = pj.parseMessageStrict(b)
+	} else if pj.allowScalarRoot {
+		err = pj.parseMessageScalar(trimJSONSpace(b))
+	} else {
+		err = pj.parseMessage(b, false)
+	}
+	pj.done = nil
+	if pj.aborted {
+		return nil, ctx.Err()
+	}
+	if err == nil && pj.duplicateKeys == DuplicateKeysReject {
+		err = checkDuplicateKeys(&pj.ParsedJson)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -78,17 +136,36 @@ func Parse(b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, erro
 }
 
 // ParseND will parse newline delimited JSON objects or arrays.
+// If b is empty or only contains whitespace ErrEmptyInput is returned.
 // An optional block of previously parsed json can be supplied to reduce allocations.
+// See Parse for how reuse is handled.
 func ParseND(b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
 	pj, err := newInternalParsedJson(reuse, opts)
 	if err != nil {
 		return nil, err
 	}
-	err = pj.parseMessage(bytes.TrimSpace(b), true)
-	if err != nil {
+	if err := pj.checkInputPadding(b); err != nil {
 		return nil, err
 	}
-	return &pj.ParsedJson, nil
+	if err := pj.parseNDMessage(bytes.TrimSpace(b)); err != nil {
+		return nil, err
+	}
+	parsed := &pj.ParsedJson
+	parsed.internal = pj
+	return parsed, nil
+}
+
+// parseNDMessage will parse newline delimited JSON.
+// Input validation and checks requested by options are applied.
+func (pj *internalParsedJson) parseNDMessage(msg []byte) error {
+	if pj.validateUTF8 && !utf8.Valid(msg) {
+		return errInvalidUTF8
+	}
+	err := pj.parseMessage(msg, true)
+	if err == nil && pj.duplicateKeys == DuplicateKeysReject {
+		err = checkDuplicateKeys(&pj.ParsedJson)
+	}
+	return err
 }
 
 // A Stream is used to stream back results.
@@ -113,7 +190,14 @@ type Stream struct {
 // An optional channel for returning consumed results can be provided.
 // There is no guarantee that elements will be consumed, so always use
 // non-blocking writes to the reuse channel.
-func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
+//
+// If WithSkipBadLines(true) is supplied, lines that cannot be parsed are
+// returned as a *LineError and parsing continues with the following lines.
+// A *LineError does not finish the stream.
+//
+// Options are applied to each result as they are by ParseND,
+// including the checks added by WithValidateUTF8 and WithDuplicateKeys.
+func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson, opts ...ParserOption) {
 	if !SupportedCPU() {
 		go func() {
 			res <- Stream{
@@ -124,6 +208,19 @@ func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
 		}()
 		return
 	}
+	var settings internalParsedJson
+	for _, opt := range opts {
+		if err := opt(&settings); err != nil {
+			go func() {
+				res <- Stream{
+					Value: nil,
+					Error: err,
+				}
+				close(res)
+			}()
+			return
+		}
+	}
 	const tmpSize = 10 << 20
 	buf := bufio.NewReaderSize(r, tmpSize)
 	tmpPool := sync.Pool{New: func() interface{} {
@@ -136,22 +233,24 @@ func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
 		defer close(res)
 		end := false
 		for items := range queue {
-			i := <-items
-			select {
-			case res <- i:
-			default:
-				if !end {
-					// Block if we haven't returned an error
-					res <- i
+			for i := range items {
+				select {
+				case res <- i:
+				default:
+					if !end {
+						// Block if we haven't returned an error
+						res <- i
+					}
+				}
+				if _, skipped := i.Error.(*LineError); i.Error != nil && !skipped {
+					end = true
 				}
-			}
-			if i.Error != nil {
-				end = true
 			}
 		}
 	}()
 	go func() {
 		defer close(queue)
+		line := 1
 		for {
 			tmp := tmpPool.Get().([]byte)
 			tmp = tmp[:tmpSize]
@@ -174,11 +273,19 @@ func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
 			}
 
 			if len(tmp) > 0 {
+				firstLine := line
+				if settings.skipBadLines {
+					line += bytes.Count(tmp, []byte{'\n'})
+				}
 				result := make(chan Stream, 0)
 				queue <- result
 				go func() {
+					defer close(result)
 					var pj internalParsedJson
-					pj.copyStrings = true
+					pj.copyKeys, pj.copyValues = true, true
+					for _, opt := range opts {
+						_ = opt(&pj)
+					}
 					select {
 					case v := <-reuse:
 						if cap(v.Message) >= tmpSize+1024 {
@@ -189,7 +296,15 @@ func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
 
 					default:
 					}
-					parseErr := pj.parseMessage(tmp, true)
+					parseErr := pj.parseNDMessage(tmp)
+					if parseErr != nil && pj.skipBadLines {
+						var found bool
+						found, parseErr = pj.parseSkipBadLines(tmp, firstLine, result, opts)
+						if parseErr == nil && !found {
+							// All lines were bad.
+							return
+						}
+					}
 					if parseErr != nil {
 						result <- Stream{
 							Value: nil,
@@ -215,6 +330,47 @@ func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
 	}()
 }
 
+// parseSkipBadLines will parse each line of msg separately.
+// Lines that fail to parse are sent to result as a *LineError.
+// The remaining lines are parsed into pj.
+// firstLine is the line number of the first line in msg.
+// Returns false if no lines could be parsed.
+func (pj *internalParsedJson) parseSkipBadLines(msg []byte, firstLine int, result chan<- Stream, opts []ParserOption) (bool, error) {
+	good := make([]byte, 0, len(msg))
+	// Lines must be checked with the same options as the final parse.
+	var tmp internalParsedJson
+	tmp.copyKeys, tmp.copyValues = true, true
+	for _, opt := range opts {
+		_ = opt(&tmp)
+	}
+	line := firstLine
+	for len(msg) > 0 {
+		l := msg
+		if end := bytes.IndexByte(msg, '\n'); end >= 0 {
+			l = msg[:end]
+			msg = msg[end+1:]
+		} else {
+			msg = nil
+		}
+		if len(bytes.TrimSpace(l)) > 0 {
+			if err := tmp.parseNDMessage(l); err != nil {
+				result <- Stream{
+					Value: nil,
+					Error: &LineError{Line: line, Err: err},
+				}
+			} else {
+				good = append(good, l...)
+				good = append(good, '\n')
+			}
+		}
+		line++
+	}
+	if len(good) == 0 {
+		return false, nil
+	}
+	return true, pj.parseMessage(good, true)
+}
+
 func queueError(queue chan chan Stream, err error) {
 	result := make(chan Stream, 0)
 	queue <- result
@@ -222,4 +378,5 @@ func queueError(queue chan chan Stream, err error) {
 		Value: nil,
 		Error: err,
 	}
+	close(result)
 }
@@ -20,7 +20,12 @@
 package simdjson
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
@@ -1015,3 +1020,1141 @@ func TestParsePassCases(t *testing.T) {
 		})
 	}
 }
+
+func TestParseNDStreamSkipBadLines(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"a":1}
+{"a":2,}
+{"a":3}
+
+["bad"
+{"a":4}`
+	run := func(input string, opts ...ParserOption) (values []string, lines []int, err error) {
+		res := make(chan Stream, 10)
+		ParseNDStream(strings.NewReader(input), res, nil, opts...)
+		for got := range res {
+			if got.Error != nil {
+				var lErr *LineError
+				if errors.As(got.Error, &lErr) {
+					lines = append(lines, lErr.Line)
+					continue
+				}
+				if got.Error != io.EOF {
+					err = got.Error
+				}
+				break
+			}
+			i := got.Value.Iter()
+			for i.Advance() == TypeRoot {
+				_, obj, err := i.Root(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := obj.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+				values = append(values, string(b))
+			}
+		}
+		return values, lines, err
+	}
+
+	values, lines, err := run(input, WithSkipBadLines(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 5}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("want bad lines %v, got %v", want, lines)
+	}
+	if want := []string{`{"a":1}`, `{"a":3}`, `{"a":4}`}; !reflect.DeepEqual(values, want) {
+		t.Errorf("want values %v, got %v", want, values)
+	}
+
+	// Default should abort.
+	values, lines, err = run(input)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(lines) != 0 || len(values) != 0 {
+		t.Errorf("unexpected output: %v %v", values, lines)
+	}
+
+	// Lines must be checked with the parser options.
+	values, lines, err = run(`{"a":1}
+{'a':2}
+{"":3}
+{"a":4}`, WithSkipBadLines(true), WithSingleQuoteStrings(true), WithRejectEmptyKeys(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{3}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("want bad lines %v, got %v", want, lines)
+	}
+	if want := []string{`{"a":1}`, `{"a":2}`, `{"a":4}`}; !reflect.DeepEqual(values, want) {
+		t.Errorf("want values %v, got %v", want, values)
+	}
+}
+
+func TestParseNDStreamOptions(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	run := func(input string, opts ...ParserOption) (lines []int, err error) {
+		res := make(chan Stream, 10)
+		ParseNDStream(strings.NewReader(input), res, nil, opts...)
+		for got := range res {
+			var lErr *LineError
+			if errors.As(got.Error, &lErr) {
+				lines = append(lines, lErr.Line)
+				continue
+			}
+			if got.Error != nil && got.Error != io.EOF {
+				err = got.Error
+			}
+		}
+		return lines, err
+	}
+	const invalidUTF8 = "{\"a\":1}\n{\"a\":\"\xff\"}\n{\"a\":3}"
+	const duplicate = "{\"a\":1}\n{\"a\":2,\"a\":2}\n{\"a\":3}"
+
+	if _, err := run(invalidUTF8); err != nil {
+		t.Errorf("want no error without option, got %v", err)
+	}
+	if _, err := run(invalidUTF8, WithValidateUTF8(true)); !errors.Is(err, errInvalidUTF8) {
+		t.Errorf("want %v, got %v", errInvalidUTF8, err)
+	}
+	if _, err := run(duplicate); err != nil {
+		t.Errorf("want no error without option, got %v", err)
+	}
+	if _, err := run(duplicate, WithDuplicateKeys(DuplicateKeysReject)); err == nil {
+		t.Error("want duplicate key error")
+	}
+
+	// Bad lines are found with the same checks.
+	for _, tt := range []struct {
+		input string
+		opt   ParserOption
+	}{
+		{input: invalidUTF8, opt: WithValidateUTF8(true)},
+		{input: duplicate, opt: WithDuplicateKeys(DuplicateKeysReject)},
+	} {
+		lines, err := run(tt.input, tt.opt, WithSkipBadLines(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int{2}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("want bad lines %v, got %v", want, lines)
+		}
+	}
+}
+
+func TestStrictRFC8259(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Selection of cases from JSONTestSuite, named as in the suite.
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{name: "y_structure_lonely_string", input: `"asd"`, valid: true},
+		{name: "y_structure_lonely_int", input: `42`, valid: true},
+		{name: "y_structure_lonely_negative_real", input: `-0.1`, valid: true},
+		{name: "y_structure_lonely_true", input: `true`, valid: true},
+		{name: "y_structure_lonely_false", input: `false`, valid: true},
+		{name: "y_structure_lonely_null", input: `null`, valid: true},
+		{name: "y_structure_string_empty", input: `""`, valid: true},
+		{name: "y_structure_whitespace_array", input: " [] ", valid: true},
+		{name: "y_structure_trailing_newline", input: "[\"a\"]\n", valid: true},
+		{name: "y_object_duplicated_key", input: `{"a":"b","a":"c"}`, valid: true},
+		{name: "y_string_escaped_control_character", input: `["\u0012"]`, valid: true},
+		{name: "y_string_utf8", input: `["€𝄞"]`, valid: true},
+		{name: "y_number_real_capital_e", input: `[1E22]`, valid: true},
+		{name: "n_structure_no_data", input: ``},
+		{name: "n_structure_whitespace_formfeed", input: "[\f]"},
+		{name: "n_structure_trailing_formfeed", input: "[1]\f"},
+		{name: "n_structure_double_array", input: `[][]`},
+		{name: "n_structure_lonely_values", input: `1 2`},
+		{name: "n_structure_number_with_trailing_garbage", input: `2@`},
+		{name: "n_structure_unclosed_array", input: `[1`},
+		{name: "n_string_invalid_utf8_after_escape", input: "[\"\\\xe5\"]"},
+		{name: "n_string_invalid_utf8", input: "[\"\xff\"]"},
+		{name: "n_string_invalid_utf8_lonely", input: "\"\xff\""},
+		{name: "n_string_escape_x", input: `["\x00"]`},
+		{name: "n_string_incomplete_escaped_character", input: `["\u00A"]`},
+		{name: "n_string_unescaped_tab", input: "[\"\t\"]"},
+		{name: "n_string_single_quote", input: `['single quote']`},
+		{name: "n_number_with_leading_zero", input: `[012]`},
+		{name: "n_number_plus_1", input: `[+1]`},
+		{name: "n_number_real_without_fractional_part", input: `[1.]`},
+		{name: "n_number_NaN", input: `[NaN]`},
+		{name: "n_object_trailing_comma", input: `{"id":0,}`},
+		{name: "n_array_extra_comma", input: `["",]`},
+		{name: "n_incomplete_true", input: `[tru]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse([]byte(tt.input), nil, WithStrictRFC8259(true))
+			if tt.valid != (err == nil) {
+				t.Fatalf("want valid %v, got error %v", tt.valid, err)
+			}
+			if err != nil {
+				return
+			}
+			// Check round trip.
+			iter := pj.Iter()
+			got, err := iter.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.input), &want); err != nil {
+				t.Fatal(err)
+			}
+			wantJS, _ := json.Marshal(want)
+			var gotV interface{}
+			if err := json.Unmarshal(got, &gotV); err != nil {
+				t.Fatal(err)
+			}
+			gotJS, _ := json.Marshal(gotV)
+			if !bytes.Equal(gotJS, wantJS) {
+				t.Errorf("want %s, got %s", wantJS, gotJS)
+			}
+		})
+	}
+	t.Run("duplicate-keys", func(t *testing.T) {
+		const input = `{"a":{"b":1,"c":[{"d":1,"e":2}]},"b":{"b":2}}`
+		if _, err := Parse([]byte(input), nil, WithDuplicateKeys(DuplicateKeysReject)); err != nil {
+			t.Fatal(err)
+		}
+		for _, input := range []string{
+			`{"a":1,"a":2}`,
+			`{"a":{"b":1,"b":2}}`,
+			`[{"a":1},{"b":[{"c":1,"c":1}]}]`,
+		} {
+			if _, err := Parse([]byte(input), nil, WithDuplicateKeys(DuplicateKeysReject)); err == nil {
+				t.Errorf("%s: want error", input)
+			}
+			if _, err := Parse([]byte(input), nil, WithStrictRFC8259(true)); err != nil {
+				t.Errorf("%s: want no error, got %v", input, err)
+			}
+		}
+		if _, err := ParseND([]byte("{\"a\":1}\n{\"a\":1,\"a\":2}"), nil, WithDuplicateKeys(DuplicateKeysReject)); err == nil {
+			t.Error("ParseND: want error")
+		}
+	})
+	t.Run("nd-utf8", func(t *testing.T) {
+		input := []byte("{\"a\":\"b\"}\n{\"a\":\"\xff\"}")
+		if _, err := ParseND(input, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseND(input, nil, WithStrictRFC8259(true)); err == nil {
+			t.Error("want error")
+		}
+	})
+}
+
+func TestAllowScalarRoot(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input string
+		want  Type
+		valid bool
+	}{
+		{input: `44`, want: TypeInt, valid: true},
+		{input: ` -1.5 `, want: TypeFloat, valid: true},
+		{input: `18446744073709551615`, want: TypeUint, valid: true},
+		{input: `"foo"`, want: TypeString, valid: true},
+		{input: `true`, want: TypeBool, valid: true},
+		{input: "null\n", want: TypeNull, valid: true},
+		{input: `{"a":1}`, want: TypeObject, valid: true},
+		{input: `[1]`, want: TypeArray, valid: true},
+		{input: `1 2`},
+		{input: `1,2`},
+		{input: `tru`},
+		{input: `"foo`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if tt.want != TypeObject && tt.want != TypeArray {
+				if _, err := Parse([]byte(tt.input), nil); err == nil {
+					t.Fatal("want error without option")
+				}
+			}
+			pj, err := Parse([]byte(tt.input), nil, WithAllowScalarRoot(true))
+			if tt.valid != (err == nil) {
+				t.Fatalf("want valid %v, got error %v", tt.valid, err)
+			}
+			if err != nil {
+				return
+			}
+			iter := pj.Iter()
+			if typ := iter.Advance(); typ != TypeRoot {
+				t.Fatalf("want root, got %v", typ)
+			}
+			typ, root, err := iter.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ != tt.want {
+				t.Fatalf("want %v, got %v", tt.want, typ)
+			}
+			got, err := root.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.TrimSpace(tt.input); string(got) != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+			if typ := iter.Advance(); typ != TypeNone {
+				t.Errorf("want end, got %v", typ)
+			}
+		})
+	}
+}
+
+func TestValidateUTF8(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	input := []byte("[\"a\x80b\"]")
+	tests := []struct {
+		name    string
+		opts    []ParserOption
+		wantErr bool
+	}{
+		{name: "default"},
+		{name: "disabled", opts: []ParserOption{WithValidateUTF8(false)}},
+		{name: "enabled", opts: []ParserOption{WithValidateUTF8(true)}, wantErr: true},
+		{name: "strict", opts: []ParserOption{WithStrictRFC8259(true)}, wantErr: true},
+		{name: "strict-disabled", opts: []ParserOption{WithStrictRFC8259(true), WithValidateUTF8(false)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse(input, nil, tt.opts...)
+			if tt.wantErr {
+				if err != errInvalidUTF8 {
+					t.Fatalf("want %v, got %v", errInvalidUTF8, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := pj.Iter()
+			i.AdvanceInto()
+			_, root, err := i.Root(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			arr, err := root.Array(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			it := arr.Iter()
+			if it.Advance() != TypeString {
+				t.Fatal("want string")
+			}
+			b, err := it.StringBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "a\x80b" {
+				t.Errorf("want %q, got %q", "a\x80b", b)
+			}
+			out, err := root.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, input) {
+				t.Errorf("want %q, got %q", input, out)
+			}
+		})
+	}
+	// Reused parsers must not keep the setting.
+	pj, err := Parse(input, nil, WithValidateUTF8(true))
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if _, err = Parse(input, pj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyKeysValues(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"key":"value","arr":["v2"]}`
+	// Tape offsets of keys and values.
+	keys := []int{2, 6}
+	values := []int{4, 9}
+	tests := []struct {
+		name       string
+		opts       []ParserOption
+		copyKeys   bool
+		copyValues bool
+	}{
+		{name: "default", copyKeys: true, copyValues: true},
+		{name: "nocopy", opts: []ParserOption{WithCopyStrings(false)}},
+		{name: "keys", opts: []ParserOption{WithCopyStrings(false), WithCopyKeys(true)}, copyKeys: true},
+		{name: "values", opts: []ParserOption{WithCopyKeys(false)}, copyValues: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse([]byte(input), nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(offsets []int, wantCopy bool) {
+				for _, off := range offsets {
+					v := pj.Tape[off]
+					if Tag(v>>JSONTAGOFFSET) != TagString {
+						t.Fatalf("offset %d: want string tag, got %v", off, Tag(v>>JSONTAGOFFSET))
+					}
+					if copied := v&STRINGBUFBIT != 0; copied != wantCopy {
+						t.Errorf("offset %d: want copied %v, got %v", off, wantCopy, copied)
+					}
+				}
+			}
+			check(keys, tt.copyKeys)
+			check(values, tt.copyValues)
+			iter := pj.Iter()
+			got, err := iter.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != input {
+				t.Errorf("want %s, got %s", input, got)
+			}
+		})
+	}
+}
+
+func TestParseSurroundingWhitespace(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	large := `{"a":"` + strings.Repeat("x", 10<<10) + `"}`
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "object-newline", input: "{\"a\":1}\n", want: `{"a":1}`},
+		{name: "array-newlines", input: "[1,2]\n\n", want: `[1,2]`},
+		{name: "spaces", input: "  {\"a\":1}  ", want: `{"a":1}`},
+		{name: "crlf", input: "\t{\"a\":1}\r\n", want: `{"a":1}`},
+		{name: "mixed", input: "\r\n[]\n \t\r\n ", want: `[]`},
+		{name: "large", input: large + "\r\n\r\n", want: large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pj, err := Parse([]byte(tt.input), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := pj.Iter()
+			got, err := iter.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+	// Multiple documents are still rejected.
+	if _, err := Parse([]byte("{}\n{}\n"), nil); err == nil {
+		t.Error("want error for multiple documents")
+	}
+}
+
+// cancelledDuringParse is a context that is cancelled once parsing has started.
+type cancelledDuringParse struct {
+	context.Context
+	checked bool
+}
+
+func (c *cancelledDuringParse) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (c *cancelledDuringParse) Err() error {
+	if !c.checked {
+		c.checked = true
+		return nil
+	}
+	return context.Canceled
+}
+
+func TestParseContext(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	large := loadCompressed(t, "twitter")
+	ctx, cancel := context.WithCancel(context.Background())
+	pj, err := ParseContext(ctx, large, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := ParseContext(ctx, large, pj); err != context.Canceled {
+		t.Fatalf("want %v, got %v", context.Canceled, err)
+	}
+	for _, input := range [][]byte{large, []byte(demo_json)} {
+		_, err := ParseContext(&cancelledDuringParse{Context: context.Background()}, input, pj)
+		if err != context.Canceled {
+			t.Fatalf("want %v, got %v", context.Canceled, err)
+		}
+		// The reused parser must still work.
+		pj, err = Parse(input, pj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iter := pj.Iter()
+		if _, err := iter.MarshalJSON(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseNumberError(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		js     string
+		strict bool
+		want   NumberErrorCode
+	}{
+		{js: `{"Numbers cannot have leading zeroes": 013}`, want: NumberErrLeadingZero},
+		{js: `04`, strict: true, want: NumberErrLeadingZero},
+		{js: `[1e+]`, want: NumberErrExponent},
+		{js: `{"a":[1, -1e999]}`, want: NumberErrRange},
+		{js: `[12abc]`, want: NumberErrTrailing},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.js), nil, WithStrictRFC8259(test.strict))
+		var nErr *NumberError
+		if !errors.As(err, &nErr) {
+			t.Errorf("%s: want NumberError, got %v", test.js, err)
+			continue
+		}
+		if nErr.Code != test.want {
+			t.Errorf("%s: want %v, got %v", test.js, test.want, nErr.Code)
+		}
+		if !strings.Contains(err.Error(), test.want.String()) {
+			t.Errorf("%s: error %q does not contain reason", test.js, err)
+		}
+	}
+}
+
+func TestParseLiteralError(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		js     string
+		offset int
+		want   string
+		got    string
+	}{
+		{js: `[truth]`, offset: 1, want: "true", got: "truth"},
+		{js: `{"a": falsy}`, offset: 6, want: "false", got: "falsy"},
+		{js: `[falsefalse]`, offset: 1, want: "false", got: "falsefalse"},
+		{js: `{"a": [1, nul]}`, offset: 10, want: "null", got: "nul"},
+		{js: `[tru]`, offset: 1, want: "true", got: "tru"},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.js), nil)
+		var lErr *LiteralError
+		if !errors.As(err, &lErr) {
+			t.Errorf("%s: want LiteralError, got %v", test.js, err)
+			continue
+		}
+		if lErr.Offset != test.offset || lErr.Want != test.want || lErr.Got != test.got {
+			t.Errorf("%s: want offset %d, want %q, got %q; got %+v", test.js, test.offset, test.want, test.got, *lErr)
+		}
+		want := fmt.Sprintf("near offset %d: expected '%s'", test.offset, test.want)
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %q does not contain %q", test.js, err, want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		js        string
+		nd        bool
+		strict    bool
+		offset    int
+		line, col int
+	}{
+		{js: `{"a":1,}`, offset: 7, line: 1, col: 8},
+		{js: `[1 2]`, offset: 3, line: 1, col: 4},
+		{js: `{"a":1 "b":2}`, offset: 7, line: 1, col: 8},
+		{js: "{\"a\":1}\n{\"b\":2}\n{\"c\":,}", nd: true, offset: 21, line: 3, col: 6},
+		{js: "[\n1,\n[2,,3]]", offset: 8, line: 3, col: 4},
+		{js: `1 2`, strict: true, offset: 2, line: 1, col: 3},
+	}
+	for _, test := range tests {
+		var err error
+		switch {
+		case test.nd:
+			_, err = ParseND([]byte(test.js), nil)
+		case test.strict:
+			_, err = Parse([]byte(test.js), nil, WithStrictRFC8259(true))
+		default:
+			_, err = Parse([]byte(test.js), nil)
+		}
+		var pErr *ParseError
+		if !errors.As(err, &pErr) {
+			t.Errorf("%q: want ParseError, got %v", test.js, err)
+			continue
+		}
+		if pErr.Offset != test.offset || pErr.Line != test.line || pErr.Col != test.col {
+			t.Errorf("%q: want offset %d, line %d, column %d; got %+v", test.js, test.offset, test.line, test.col, *pErr)
+		}
+		want := fmt.Sprintf("(line %d, column %d, offset %d)", test.line, test.col, test.offset)
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error %q does not contain %q", test.js, err, want)
+		}
+	}
+
+	// The reason is kept.
+	_, err := Parse([]byte("{\"a\":\n[tru]}"), nil)
+	var pErr *ParseError
+	var lErr *LiteralError
+	if !errors.As(err, &pErr) || !errors.As(err, &lErr) {
+		t.Fatalf("want ParseError and LiteralError, got %v", err)
+	}
+	if pErr.Line != 2 || pErr.Col != 2 || lErr.Offset != 7 {
+		t.Errorf("want line 2, column 2, literal offset 7, got %+v, %+v", *pErr, *lErr)
+	}
+}
+
+func TestInputPadding(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// padded returns a copy of s with n bytes of capacity after the end.
+	// The padding is filled with bytes that would confuse string parsing.
+	padded := func(s string, n int) []byte {
+		b := make([]byte, len(s), len(s)+n)
+		copy(b, s)
+		pad := b[len(s) : len(s)+n]
+		for i := range pad {
+			pad[i] = `"\`[i%2]
+		}
+		return b
+	}
+	inputs := []string{
+		`["` + strings.Repeat("a", 100) + `"]`,
+		`{"key":"value","esc":"\"\\\næ"}`,
+		`{"a":"` + strings.Repeat("b", 1000) + `"}`,
+	}
+	for _, in := range inputs {
+		for _, copyStrings := range []bool{true, false} {
+			want, err := Parse([]byte(in), nil, WithCopyStrings(copyStrings))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wi := want.Iter()
+			wantJSON, err := wi.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Parse(padded(in, RequiredPadding), nil, WithCopyStrings(copyStrings), WithInputPadding(RequiredPadding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gi := got.Iter()
+			gotJSON, err := gi.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+		}
+	}
+
+	// Insufficient padding must be caught.
+	short := padded(inputs[0], RequiredPadding-1)
+	if _, err := Parse(short, nil, WithInputPadding(RequiredPadding)); err == nil {
+		t.Error("Parse: want error for insufficient padding")
+	}
+	if _, err := ParseND(short, nil, WithInputPadding(RequiredPadding)); err == nil {
+		t.Error("ParseND: want error for insufficient padding")
+	}
+	if _, err := ParseLazy(short, WithInputPadding(RequiredPadding)); err == nil {
+		t.Error("ParseLazy: want error for insufficient padding")
+	}
+	if _, err := Parse([]byte(inputs[0]), nil, WithInputPadding(-1)); err == nil {
+		t.Error("want error for negative padding")
+	}
+
+	// Scalars are copied in strict mode, so the padding isn't used.
+	if _, err := Parse(padded(`"abc"`, RequiredPadding), nil, WithInputPadding(RequiredPadding), WithStrictRFC8259(true)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxElements(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// root, [, {, }, {, }, ], root
+	const small = `[{},{}]`
+	if _, err := Parse([]byte(small), nil, WithMaxElements(8)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse([]byte(small), nil, WithMaxElements(7)); !errors.Is(err, ErrTooManyElements) {
+		t.Errorf("want ErrTooManyElements, got %v", err)
+	}
+	// root, {, "a", 1, }, root
+	if _, err := Parse([]byte(`{"a":1}`), nil, WithMaxElements(7)); !errors.Is(err, ErrTooManyElements) {
+		t.Errorf("want ErrTooManyElements, got %v", err)
+	}
+
+	// Large inputs are parsed asynchronously.
+	large := []byte("[" + strings.Repeat(`{},`, 100000) + `{}]`)
+	if _, err := Parse(large, nil, WithMaxElements(1000)); !errors.Is(err, ErrTooManyElements) {
+		t.Errorf("want ErrTooManyElements, got %v", err)
+	}
+	pj, err := Parse(large, nil, WithMaxElements(1000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limit is reset when reusing.
+	if _, err = Parse(large, pj); err != nil {
+		t.Fatal(err)
+	}
+
+	nd := []byte(strings.Repeat("{}\n", 1000))
+	if _, err := ParseND(nd, nil, WithMaxElements(100)); !errors.Is(err, ErrTooManyElements) {
+		t.Errorf("want ErrTooManyElements, got %v", err)
+	}
+	if _, err := ParseND(nd, nil, WithMaxElements(4000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse([]byte(small), nil, WithMaxElements(-1)); err == nil {
+		t.Error("want error for negative limit")
+	}
+}
+
+func TestRejectEmpty(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input string
+		opts  []ParserOption
+		want  *EmptyError
+	}{
+		{input: `{"":1}`},
+		{input: `{"a":{},"b":[]}`},
+		{input: `{"":1}`, opts: []ParserOption{WithRejectEmptyKeys(true)}, want: &EmptyError{Offset: 1, Kind: "key"}},
+		{input: `{"a":1, "" :2}`, opts: []ParserOption{WithRejectEmptyKeys(true)}, want: &EmptyError{Offset: 8, Kind: "key"}},
+		{input: `{"a":1, "" :2}`, opts: []ParserOption{WithRejectEmptyKeys(true), WithCopyStrings(false)}, want: &EmptyError{Offset: 8, Kind: "key"}},
+		{input: `{"a":1, "" :2}`, opts: []ParserOption{WithRejectEmptyKeys(true), WithInternStrings(true)}, want: &EmptyError{Offset: 8, Kind: "key"}},
+		{input: `{"a":"", "b":{"c":[""]}}`, opts: []ParserOption{WithRejectEmptyKeys(true)}},
+		{input: `{"a":{},"b":[]}`, opts: []ParserOption{WithRejectEmptyKeys(true)}},
+		{input: `{"a":{},"b":[]}`, opts: []ParserOption{WithRejectEmptyContainers(true)}, want: &EmptyError{Offset: 5, Kind: "object"}},
+		{input: `{"a":[1],"b":[ ]}`, opts: []ParserOption{WithRejectEmptyContainers(true)}, want: &EmptyError{Offset: 13, Kind: "array"}},
+		{input: `[[1], [ {  } ]]`, opts: []ParserOption{WithRejectEmptyContainers(true)}, want: &EmptyError{Offset: 8, Kind: "object"}},
+		{input: `  [ ]`, opts: []ParserOption{WithRejectEmptyContainers(true)}, want: &EmptyError{Offset: 0, Kind: "array"}},
+		{input: `{"":{}}`, opts: []ParserOption{WithRejectEmptyContainers(true)}, want: &EmptyError{Offset: 4, Kind: "object"}},
+		{input: `{"a":[0],"":{"b":null}}`, opts: []ParserOption{WithRejectEmptyContainers(true)}},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.input), nil, test.opts...)
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.input, err)
+			}
+			continue
+		}
+		var got *EmptyError
+		if !errors.As(err, &got) || *got != *test.want {
+			t.Errorf("%s: want %v, got %v", test.input, test.want, err)
+		}
+	}
+
+	// The options are reset when reusing.
+	nd := []byte("{\"a\":1}\n{\"\":[]}\n")
+	pj, err := ParseND(nd, nil, WithRejectEmptyKeys(true))
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if _, err := ParseND(nd, pj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectWarnings(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	deep := strings.Repeat("[", WarnNestingDepth+2) + strings.Repeat("]", WarnNestingDepth+2)
+	tests := []struct {
+		input string
+		want  []Warning
+	}{
+		{input: `{"a":"b\\n\u00e9","c":[1,2.5,-3]}`},
+		{input: `{"a":1,"b":{"a":2,"a":3},"a":4}`, want: []Warning{
+			{Code: WarningDuplicateKey, Offset: 11},
+			{Code: WarningDuplicateKey, Offset: 0},
+		}},
+		{input: `[18446744073709551615, 18446744073709551616, -9223372036854775809]`, want: []Warning{
+			{Code: WarningPrecisionLoss, Offset: 23},
+			{Code: WarningPrecisionLoss, Offset: 45},
+		}},
+		{input: `{"a\/b":"\u0041", "c":["\u000a", "\u001f\n"]}`, want: []Warning{
+			{Code: WarningNonCanonicalEscape, Offset: 1},
+			{Code: WarningNonCanonicalEscape, Offset: 8},
+			{Code: WarningNonCanonicalEscape, Offset: 23},
+		}},
+		{input: deep, want: []Warning{
+			{Code: WarningDeepNesting, Offset: WarnNestingDepth},
+		}},
+	}
+	for _, test := range tests {
+		pj, err := Parse([]byte(test.input), nil, WithCollectWarnings(true))
+		if err != nil {
+			t.Fatalf("%s: %v", test.input, err)
+		}
+		got := pj.Warnings()
+		if len(got) != len(test.want) {
+			t.Errorf("%s: want %d warnings, got %v", test.input, len(test.want), got)
+			continue
+		}
+		for i, w := range got {
+			if w.Code != test.want[i].Code || w.Offset != test.want[i].Offset || w.Message == "" {
+				t.Errorf("%s: warning %d: want %v at %d, got %v", test.input, i, test.want[i].Code, test.want[i].Offset, w)
+			}
+		}
+		if pj.spans != nil {
+			t.Errorf("%s: source spans were kept", test.input)
+		}
+
+		// Not collected by default.
+		pj, err = Parse([]byte(test.input), pj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := pj.Warnings(); len(w) != 0 {
+			t.Errorf("%s: got warnings without option: %v", test.input, w)
+		}
+	}
+}
+
+func TestSingleQuoteStrings(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `{'a':'b'}`, want: `{"a":"b"}`},
+		{input: `{'a': 'it\'s "x"', "b": ['c', "d'e", 'f\nA\\']}`, want: `{"a":"it's \"x\"","b":["c","d'e","f\nA\\"]}`},
+		{input: `['', "\"'", '\\\'']`, want: `["","\"'","\\'"]`},
+		{input: `{"no":"quotes"}`, want: `{"no":"quotes"}`},
+	}
+	for _, test := range tests {
+		for _, copyStrings := range []bool{true, false} {
+			pj, err := Parse([]byte(test.input), nil, WithSingleQuoteStrings(true), WithCopyStrings(copyStrings))
+			if err != nil {
+				t.Fatalf("%s: %v", test.input, err)
+			}
+			i := pj.Iter()
+			got, err := i.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("%s: want %s, got %s", test.input, test.want, got)
+			}
+		}
+	}
+
+	for _, input := range []string{`{'a':'b}`, `{'a':1'}`, `{'a':"b'}`} {
+		if _, err := Parse([]byte(input), nil, WithSingleQuoteStrings(true)); err == nil {
+			t.Errorf("%s: want error", input)
+		}
+	}
+	const input = `{'a':'b'}`
+	if _, err := Parse([]byte(input), nil); err == nil {
+		t.Error("single quotes accepted by default")
+	}
+	if _, err := Parse([]byte(input), nil, WithSingleQuoteStrings(true), WithStrictRFC8259(true)); err == nil {
+		t.Error("single quotes accepted in strict mode")
+	}
+
+	// The input is not modified and the conversion buffer is reused.
+	nd := []byte("{'a':1}\n{'b':'c'}\n")
+	orig := string(nd)
+	pj, err := ParseND(nd, nil, WithSingleQuoteStrings(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pj, err = ParseND(nd, pj, WithSingleQuoteStrings(true), WithCopyStrings(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := pj.Iter()
+	got, err := i.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":1}\n{\"b\":\"c\"}"; string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	if string(nd) != orig {
+		t.Errorf("input modified: %s", nd)
+	}
+}
+
+func TestSingleAllocation(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	inputs := []string{
+		`{"a":1}`,
+		demo_json,
+		// Strings exceed the initial estimate and must grow.
+		`["` + strings.Repeat("a", 1000) + `","` + strings.Repeat("b", 1000) + `"]`,
+		// Tape exceeds the initial estimate and must grow.
+		`[` + strings.Repeat(`1,`, 1000) + `1]`,
+	}
+	var reuse *ParsedJson
+	for _, in := range inputs {
+		want, err := Parse([]byte(in), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wi := want.Iter()
+		wantJSON, err := wi.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range []*ParsedJson{nil, reuse} {
+			got, err := Parse([]byte(in), r, WithSingleAllocation(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gi := got.Iter()
+			gotJSON, err := gi.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+			reuse = got
+		}
+	}
+}
+
+func TestParseEmptyInput(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	for _, in := range []string{"", " ", "\n\t\r "} {
+		if _, err := Parse([]byte(in), nil); !errors.Is(err, ErrEmptyInput) {
+			t.Errorf("Parse(%q): want ErrEmptyInput, got %v", in, err)
+		}
+		if _, err := Parse([]byte(in), nil, WithStrictRFC8259(true)); !errors.Is(err, ErrEmptyInput) {
+			t.Errorf("Parse(%q) strict: want ErrEmptyInput, got %v", in, err)
+		}
+		if _, err := ParseND([]byte(in), nil); !errors.Is(err, ErrEmptyInput) {
+			t.Errorf("ParseND(%q): want ErrEmptyInput, got %v", in, err)
+		}
+		if _, err := ParseLazy([]byte(in)); !errors.Is(err, ErrEmptyInput) {
+			t.Errorf("ParseLazy(%q): want ErrEmptyInput, got %v", in, err)
+		}
+		if err := ValidateReader(strings.NewReader(in), 0); !errors.Is(err, ErrEmptyInput) {
+			t.Errorf("ValidateReader(%q): want ErrEmptyInput, got %v", in, err)
+		}
+	}
+	// Malformed input is not reported as empty.
+	for _, in := range []string{"\x00", "[", " ]"} {
+		if _, err := Parse([]byte(in), nil); err == nil || errors.Is(err, ErrEmptyInput) {
+			t.Errorf("Parse(%q): want parse error, got %v", in, err)
+		}
+	}
+}
+
+func TestLazyNumbers(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const input = `{"f":[1.5,-0.25,1.50,1E2,2e-3,-0.0e+0,5e+30],"i":[1,-2,18446744073709551615,18446744073709551616],"small":[1e-400],"s":"1.5"}`
+	want, err := Parse([]byte(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse([]byte(input), nil, WithLazyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wi, gi := want.Iter(), got.Iter()
+	wantV, err := wi.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotV, err := gi.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotV, wantV) {
+		t.Errorf("want %v, got %v", wantV, gotV)
+	}
+
+	// Floats are marshaled as they appeared in the input.
+	gi = got.Iter()
+	b, err := gi.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `[1.5,-0.25,1.50,1E2,2e-3,-0.0e+0,5e+30]`) {
+		t.Errorf("floats were not preserved: %s", b)
+	}
+
+	// Typed access converts values.
+	gi = got.Iter()
+	elem, err := gi.FindElement(nil, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err := elem.Iter.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	floats, err := arr.AsFloat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantF := []float64{1.5, -0.25, 1.5, 100, 0.002, 0, 5e30}; !reflect.DeepEqual(floats, wantF) {
+		t.Errorf("want %v, got %v", wantF, floats)
+	}
+	arr, err = elem.Iter.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := arr.Iter()
+	elems.Advance()
+	if elems.Type() != TypeFloat {
+		t.Errorf("want float, got %v", elems.Type())
+	}
+	if v, err := elems.Int(); err != nil || v != 1 {
+		t.Errorf("want 1, got %v (err: %v)", v, err)
+	}
+	if raw, err := elems.Raw(); err != nil || string(raw) != "1.5" {
+		t.Errorf("want 1.5, got %s (err: %v)", raw, err)
+	}
+	_, _, f, kind, err := elems.Num()
+	if err != nil || kind != NumberFloat || f != 1.5 {
+		t.Errorf("want float 1.5, got %v %v (err: %v)", kind, f, err)
+	}
+
+	// Overflowed integers keep their flag.
+	gi = got.Iter()
+	elem, err = gi.FindElement(nil, "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err = elem.Iter.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems = arr.Iter()
+	for elems.Advance() != TypeNone {
+		if elems.Type() == TypeFloat {
+			if _, flags, err := elems.FloatFlags(); err != nil || !flags.Contains(FloatOverflowedInteger) {
+				t.Errorf("want overflowed integer, got %v (err: %v)", flags, err)
+			}
+		}
+	}
+
+	// Values can be replaced.
+	gi = got.Iter()
+	elem, err = gi.FindElement(nil, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, err = elem.Iter.Array(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems = arr.Iter()
+	elems.Advance()
+	if err := elems.SetFloat(2.5); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := elems.Float(); err != nil || v != 2.5 {
+		t.Errorf("want 2.5, got %v (err: %v)", v, err)
+	}
+	gi = got.Iter()
+	if b, err := gi.MarshalJSON(); err != nil || !strings.Contains(string(b), `[2.5,-0.25,`) {
+		t.Errorf("want replaced value, got %s (err: %v)", b, err)
+	}
+
+	// Serialized data contains the converted values.
+	var s Serializer
+	dser, err := s.Deserialize(s.Serialize(nil, *got), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	di := dser.Iter()
+	dv, err := di.Interface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantV.([]interface{})[0].(map[string]interface{})["f"].([]interface{})[0] = 2.5
+	if !reflect.DeepEqual(dv, wantV) {
+		t.Errorf("want %v, got %v", wantV, dv)
+	}
+
+	// Invalid numbers are rejected.
+	for _, in := range []string{"1.", "1.e5", "01.5", "-.5", "1.5.3", "1e", "1e+", "1.5e5e", "1.5x", "1.5-", "1e400"} {
+		if _, err := Parse([]byte(`[`+in+`]`), nil, WithLazyNumbers(true)); err == nil {
+			t.Errorf("%s: want error", in)
+		}
+	}
+}
+
+func BenchmarkLazyNumbers(b *testing.B) {
+	if !SupportedCPU() {
+		b.SkipNow()
+	}
+	msg := loadCompressed(b, "canada")
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprint("lazy=", lazy), func(b *testing.B) {
+			var pj *ParsedJson
+			b.SetBytes(int64(len(msg)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var err error
+				pj, err = Parse(msg, pj, WithLazyNumbers(lazy))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
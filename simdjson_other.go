@@ -20,6 +20,7 @@
 package simdjson
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -32,12 +33,51 @@ func SupportedCPU() bool {
 
 // Parse an object or array from a block of data and return the parsed JSON.
 // An optional block of previously parsed json can be supplied to reduce allocations.
+// If reuse was returned by a previous call to Parse or ParseND the internal
+// stage 1 index buffers and channels are also kept, so setup cost is avoided
+// when parsing many small documents in sequence.
+// A reused ParsedJson must not be used concurrently or after it has been supplied.
 func Parse(b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
 	return nil, errors.New("Unsupported platform")
 }
 
+// ParseContext will parse like Parse, but stop parsing if the context is cancelled.
+// The context is checked for every block of structural indexes found,
+// and every 1024 values while the tape is built.
+// ctx.Err() is returned if parsing was stopped.
+func ParseContext(ctx context.Context, b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	return nil, errors.New("Unsupported platform")
+}
+
+// ParseLazy will find the structure of an object or array in b,
+// but defer building the tape until the document is accessed.
+func ParseLazy(b []byte, opts ...ParserOption) (*LazyParsedJson, error) {
+	return nil, errors.New("Unsupported platform")
+}
+
+func (l *LazyParsedJson) materialize() (*ParsedJson, error) {
+	return nil, errors.New("Unsupported platform")
+}
+
+// ParseReader will read r until io.EOF and parse the content as a single object or array like Parse.
+func ParseReader(r io.Reader, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
+	return nil, errors.New("Unsupported platform")
+}
+
+// ValidateReader will read a single JSON object or array from r until io.EOF
+// and return an error if it isn't valid JSON.
+func ValidateReader(r io.Reader, maxBytes int64) error {
+	return errors.New("Unsupported platform")
+}
+
+// ExtractField will return the value of a single top-level key in the object in data.
+func ExtractField(data []byte, key string) (Iter, *ParsedJson, error) {
+	return Iter{}, nil, errors.New("Unsupported platform")
+}
+
 // ParseND will parse newline delimited JSON objects or arrays.
 // An optional block of previously parsed json can be supplied to reduce allocations.
+// See Parse for how reuse is handled.
 func ParseND(b []byte, reuse *ParsedJson, opts ...ParserOption) (*ParsedJson, error) {
 	return nil, errors.New("Unsupported platform")
 }
@@ -64,7 +104,11 @@ type Stream struct {
 // An optional channel for returning consumed results can be provided.
 // There is no guarantee that elements will be consumed, so always use
 // non-blocking writes to the reuse channel.
-func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson) {
+//
+// If WithSkipBadLines(true) is supplied, lines that cannot be parsed are
+// returned as a *LineError and parsing continues with the following lines.
+// A *LineError does not finish the stream.
+func ParseNDStream(r io.Reader, res chan<- Stream, reuse <-chan *ParsedJson, opts ...ParserOption) {
 	go func() {
 		res <- Stream{
 			Value: nil,
@@ -0,0 +1,74 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+// convertSingleQuotes appends src to dst with single quoted strings converted to double quoted strings.
+// Double quotes inside single quoted strings are escaped and escaped single quotes are unescaped.
+// Double quoted strings are copied unchanged.
+// Invalid input is copied as well as possible, so it is rejected when parsed.
+func convertSingleQuotes(dst, src []byte) []byte {
+	const (
+		outside = iota
+		inDouble
+		inSingle
+	)
+	state := outside
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch state {
+		case outside:
+			switch c {
+			case '"':
+				state = inDouble
+			case '\'':
+				state = inSingle
+				c = '"'
+			}
+			dst = append(dst, c)
+		case inDouble:
+			switch c {
+			case '\\':
+				if i+1 < len(src) {
+					dst = append(dst, c)
+					i++
+					c = src[i]
+				}
+			case '"':
+				state = outside
+			}
+			dst = append(dst, c)
+		case inSingle:
+			switch c {
+			case '\\':
+				if i+1 < len(src) {
+					i++
+					if src[i] != '\'' {
+						dst = append(dst, c)
+					}
+					c = src[i]
+				}
+			case '"':
+				dst = append(dst, '\\')
+			case '\'':
+				state = outside
+				c = '"'
+			}
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
@@ -38,111 +38,163 @@ func jsonMarkup(b byte) bool {
 	return jsonMarkupTable[b]
 }
 
-func (pj *internalParsedJson) findStructuralIndices() bool {
-	avx512 := cpuid.CPU.Has(cpuid.AVX512F)
-	buf := pj.Message
-	// persistent state across loop
+// stage1 contains the state of finding structural indexes
+// that is carried from one block of input to the next.
+type stage1 struct {
+	avx512 bool
+	ndjson uint64
+
 	// does the last iteration end with an odd-length sequence of backslashes?
 	// either 0 or 1, but a 64-bit value
-	prev_iter_ends_odd_backslash := uint64(0)
+	prevIterEndsOddBackslash uint64
 
 	// does the previous iteration end inside a double-quote pair?
-	prev_iter_inside_quote := uint64(0) // either all zeros or all ones
+	prevIterInsideQuote uint64 // either all zeros or all ones
 
 	// does the previous iteration end on something that is a predecessor of a
 	// pseudo-structural character - i.e. whitespace or a structural character
 	// effectively the very first char is considered to follow "whitespace" for the
 	// purposes of pseudo-structural character detection so we initialize to 1
-	prev_iter_ends_pseudo_pred := uint64(1)
+	prevIterEndsPseudoPred uint64
 
-	error_mask := uint64(0) // for unescaped characters within strings (ASCII code points < 0x20)
-
-	indexTotal := 0
+	errorMask uint64 // for unescaped characters within strings (ASCII code points < 0x20)
 
 	// empty bits that are carried over to the next call to flatten_bits_incremental
-	carried := uint64(0)
+	carried uint64
+
+	// position of the last index, relative to the start of the next block.
+	position uint64
+
+	// index stripped from the previous index buffer, see findIndexes.
+	strippedIndex uint64
+	indexTotal    int
+}
+
+func newStage1(ndjson uint64) stage1 {
+	return stage1{
+		avx512:                 cpuid.CPU.Has(cpuid.AVX512F),
+		ndjson:                 ndjson,
+		prevIterEndsPseudoPred: 1,
+		position:               ^uint64(0),
+		strippedIndex:          ^uint64(0),
+	}
+}
+
+// find will add the structural indexes in buf to indexes.
+// Indexes are stored as the distance to the previous index.
+// Unless final is set, only whole blocks of 64 bytes are processed.
+// Fewer bytes are processed if indexes is filled.
+// The number of processed bytes is returned.
+// The caller must subtract it from s.position before the next call.
+func (s *stage1) find(buf []byte, indexes *[indexSize]uint32, length *int, final bool) (processed uint64) {
+	processed = s.findSlice(buf[:len(buf) & ^63], indexes, length)
+
+	// Check if we have at most a single iteration of 64 bytes left, tag on to previous invocation
+	if final && uint64(len(buf))-processed <= 64 {
+		// Process last 64 bytes in larger buffer (to safeguard against reading beyond the end of the buffer)
+		paddedBuf := [128]byte{}
+		copy(paddedBuf[:], buf[processed:])
+		paddedBytes := uint64(len(buf)) - processed
+		processed += s.findSlice(paddedBuf[:paddedBytes], indexes, length)
+	}
+	return processed
+}
+
+func (s *stage1) findSlice(buf []byte, indexes *[indexSize]uint32, length *int) uint64 {
+	if s.avx512 {
+		return find_structural_bits_in_slice_avx512(buf, &s.prevIterEndsOddBackslash,
+			&s.prevIterInsideQuote, &s.errorMask,
+			&s.prevIterEndsPseudoPred,
+			indexes, length, &s.carried, &s.position, s.ndjson)
+	}
+	return find_structural_bits_in_slice(buf, &s.prevIterEndsOddBackslash,
+		&s.prevIterInsideQuote, &s.errorMask,
+		&s.prevIterEndsPseudoPred,
+		indexes, length, &s.carried, &s.position, s.ndjson)
+}
+
+func (pj *internalParsedJson) findStructuralIndices() bool {
+	s := newStage1(pj.ndjson)
+	_, ok := pj.findIndexes(&s, pj.Message, true)
+	pj.indexChans <- indexChan{index: -1}
 
-	// absolute position into message buffer
-	position := ^uint64(0)
-	stripped_index := ^uint64(0)
+	// a valid JSON file cannot have zero structural indexes - we should have found something
+	return ok && s.indexTotal > 0
+}
 
+// findIndexes will find the structural indexes in buf and send them to stage 2.
+// buf must continue the input where the previous call stopped.
+// Unless final is set, only whole blocks of 64 bytes are processed,
+// leaving at least one byte, so the end of the input is checked by the final call.
+// The number of processed bytes is returned.
+// The end of stage 1 is not sent.
+func (pj *internalParsedJson) findIndexes(s *stage1, buf []byte, final bool) (int, bool) {
+	if !final && len(buf) > 0 {
+		buf = buf[:(len(buf)-1) & ^63]
+	}
+	total := 0
 	for len(buf) > 0 {
+		if pj.cancelled() {
+			// Discard queued indexes, so stage 2 stops at the end of its current buffer.
+			pj.aborted = true
+			pj.discardIndexes()
+			s.errorMask = ^uint64(0)
+			break
+		}
 
 		index := indexChan{}
 		offset := atomic.AddUint64(&pj.buffersOffset, 1)
 		index.indexes = &pj.buffers[offset%indexSlots]
 
 		// In case last index during previous round was stripped back, put it back
-		if stripped_index != ^uint64(0) {
-			position += stripped_index
-			index.indexes[0] = uint32(stripped_index)
+		if s.strippedIndex != ^uint64(0) {
+			s.position += s.strippedIndex
+			index.indexes[0] = uint32(s.strippedIndex)
 			index.length = 1
-			stripped_index = ^uint64(0)
+			s.strippedIndex = ^uint64(0)
 		}
 
-		var processed uint64
-		if avx512 {
-			processed = find_structural_bits_in_slice_avx512(buf[:len(buf) & ^63], &prev_iter_ends_odd_backslash,
-				&prev_iter_inside_quote, &error_mask,
-				&prev_iter_ends_pseudo_pred,
-				index.indexes, &index.length, &carried, &position, pj.ndjson)
-		} else {
-			processed = find_structural_bits_in_slice(buf[:len(buf) & ^63], &prev_iter_ends_odd_backslash,
-				&prev_iter_inside_quote, &error_mask,
-				&prev_iter_ends_pseudo_pred,
-				index.indexes, &index.length, &carried, &position, pj.ndjson)
-		}
+		processed := s.find(buf, index.indexes, &index.length, final)
 
-		// Check if we have at most a single iteration of 64 bytes left, tag on to previous invocation
-		if uint64(len(buf))-processed <= 64 {
-			// Process last 64 bytes in larger buffer (to safeguard against reading beyond the end of the buffer)
-			paddedBuf := [128]byte{}
-			copy(paddedBuf[:], buf[processed:])
-			paddedBytes := uint64(len(buf)) - processed
-			if avx512 {
-				processed += find_structural_bits_in_slice_avx512(paddedBuf[:paddedBytes], &prev_iter_ends_odd_backslash,
-					&prev_iter_inside_quote, &error_mask,
-					&prev_iter_ends_pseudo_pred,
-					index.indexes, &index.length, &carried, &position, pj.ndjson)
-			} else {
-				processed += find_structural_bits_in_slice(paddedBuf[:paddedBytes], &prev_iter_ends_odd_backslash,
-					&prev_iter_inside_quote, &error_mask,
-					&prev_iter_ends_pseudo_pred,
-					index.indexes, &index.length, &carried, &position, pj.ndjson)
+		if index.length == 0 {
+			if final { // No structural chars found, so error out
+				s.errorMask = ^uint64(0)
+				break
 			}
+			// More input is needed.
+			buf = buf[processed:]
+			s.position -= processed
+			total += int(processed)
+			continue
 		}
 
-		if index.length == 0 { // No structural chars found, so error out
-			error_mask = ^uint64(0)
-			break
-		}
-
-		if uint64(len(buf)) == processed { // message processing completed?
+		if final && uint64(len(buf)) == processed { // message processing completed?
 			// break out if either
 			// - is there an unmatched quote at the end
 			// - the ending structural char is not either a '}' (normal json) or a ']' (array style)
-			if prev_iter_inside_quote != 0 ||
-				position >= uint64(len(buf)) ||
-				!(buf[position] == '}' || buf[position] == ']') {
-				error_mask = ^uint64(0)
+			if s.prevIterInsideQuote != 0 ||
+				s.position >= uint64(len(buf)) ||
+				!(buf[s.position] == '}' || buf[s.position] == ']') {
+				s.errorMask = ^uint64(0)
 				break
 			}
-		} else if !jsonMarkup(buf[position]) {
+		} else if int64(s.position) < 0 || !jsonMarkup(buf[s.position]) {
 			// There may be a dangling quote at the end of the index buffer
-			// Strip it from current index buffer and save for next round
-			stripped_index = uint64(index.indexes[index.length-1])
-			position -= stripped_index
+			// Strip it from current index buffer and save for next round.
+			// If no indexes were found in buf, this is the index stripped before.
+			s.strippedIndex = uint64(index.indexes[index.length-1])
+			s.position -= s.strippedIndex
 			index.length -= 1
 		}
 
-		pj.indexChans <- index
-		indexTotal += index.length
+		if index.length > 0 {
+			pj.indexChans <- index
+			s.indexTotal += index.length
+		}
 
 		buf = buf[processed:]
-		position -= processed
+		s.position -= processed
+		total += int(processed)
 	}
-	pj.indexChans <- indexChan{index: -1}
-
-	// a valid JSON file cannot have zero structural indexes - we should have found something
-	return error_mask == 0 && indexTotal > 0
+	return total, s.errorMask == 0
 }
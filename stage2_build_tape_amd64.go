@@ -69,9 +69,13 @@ func peekSize(pj *internalParsedJson) uint64 {
 	return uint64(pj.indexesChan.indexes[pj.indexesChan.index])
 }
 
-func parseString(pj *ParsedJson, idx uint64, maxStringSize uint64, needCopy bool) bool {
+func parseString(pj *ParsedJson, idx uint64, maxStringSize uint64, padding int, needCopy, preserve bool, intern *internTable) bool {
 	size := uint64(0)
 	buf := pj.Message[idx:]
+	if padding > 0 {
+		// Padding after the message can be read.
+		buf = pj.Message[idx : len(pj.Message)+padding]
+	}
 	// Make sure that we have at least one full YMM word available after maxStringSize into the buffer
 	if len(buf)-int(maxStringSize) < 64 {
 		if len(buf) > 512-64 { // only allocated if needed
@@ -104,19 +108,88 @@ func parseString(pj *ParsedJson, idx uint64, maxStringSize uint64, needCopy bool
 		}
 		start := len(strs)
 		_ = parseStringSimd(buf, &pj.Strings.B) // We can safely ignore the result since we validate above
-		pj.write_tape(uint64(STRINGBUFBIT+start), '"')
 		size = uint64(len(pj.Strings.B) - start)
+		if intern != nil {
+			if off, ok := intern.lookup(pj.Strings.B, start); ok {
+				// Use the existing string and remove the copy.
+				pj.Strings.B = pj.Strings.B[:start]
+				start = off
+			}
+		}
+		pj.write_tape(uint64(STRINGBUFBIT+start), '"')
+		if preserve {
+			pj.rawStrings = append(pj.rawStrings, rawString{
+				strOffset: uint64(start),
+				srcStart:  idx,
+				srcEnd:    idx + rawStringLen(pj.Message[idx:]),
+			})
+		}
 	}
 	// put length onto the tape
 	pj.Tape = append(pj.Tape, size)
 	return true
 }
 
-func addNumber(buf []byte, pj *ParsedJson) bool {
+// rawStringLen returns the length of the string starting at buf[0],
+// including quotes. The string must have been validated.
+func rawStringLen(buf []byte) uint64 {
+	for i := 1; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '"':
+			return uint64(i + 1)
+		}
+	}
+	return uint64(len(buf))
+}
+
+func addNumber(buf []byte, pj *internalParsedJson) bool {
+	if pj.lazyNumbers {
+		if n := lazyFloatLen(buf); n > 0 {
+			// buf is a suffix of the message, so the offset can be derived from the length.
+			offset := uint64(len(pj.Message) - len(buf))
+			pj.writeTapeTagValFlags(uint64(TagFloat)<<JSONTAGOFFSET|rawNumberBit|offset, uint64(n))
+			return true
+		}
+	}
 	tag, val := parseNumber(buf)
 	if tag == 0 {
+		pj.stage2Err = numberError(buf)
 		return false
 	}
+	if pj.bigNumbers && Tag(tag>>JSONTAGOFFSET) == TagFloat && tag&uint64(FloatOverflowedInteger) != 0 {
+		// Keep the source, so the exact value can be read.
+		n := 0
+		for n < len(buf) && isNumberRune[buf[n]]&isPartOfNumberFlag != 0 {
+			n++
+		}
+		offset := uint64(len(pj.Message) - len(buf))
+		pj.writeTapeTagValFlags(uint64(TagFloat)<<JSONTAGOFFSET|rawNumberBit|offset, uint64(n))
+		return true
+	}
+	if pj.collectWarnings {
+		pj.checkPrecision(buf, tag)
+	}
+	pj.writeTapeTagValFlags(tag, val)
+	return true
+}
+
+// addPlusNumber adds a number with a leading plus sign.
+// The plus sign must be followed by a digit.
+func addPlusNumber(buf []byte, pj *internalParsedJson) bool {
+	if len(buf) < 2 || isNumberRune[buf[1]]&isDigitFlag == 0 {
+		pj.stage2Err = numberError(buf)
+		return false
+	}
+	tag, val := parseNumber(buf[1:])
+	if tag == 0 {
+		pj.stage2Err = numberError(buf)
+		return false
+	}
+	if pj.collectWarnings {
+		pj.checkPrecision(buf, tag)
+	}
 	pj.writeTapeTagValFlags(tag, val)
 	return true
 }
@@ -157,6 +230,106 @@ func isValidNullAtom(buf []byte) bool {
 	return false
 }
 
+// literalError returns the error for the invalid literal at buf[idx:].
+func literalError(buf []byte, idx uint64, want string) *LiteralError {
+	end := idx
+	for end < uint64(len(buf)) && isNotStructuralOrWhitespace(buf[end]) != 0 && end-idx < 32 {
+		end++
+	}
+	return &LiteralError{Offset: int(idx), Want: want, Got: string(buf[idx:end])}
+}
+
+// openSpan records the start of the object or array at buf[idx].
+// Must be called before the start is written to the tape.
+func (pj *internalParsedJson) openSpan(idx uint64) {
+	if pj.collectWarnings && len(pj.spanStack) == WarnNestingDepth {
+		// Only reported for the first container, since all its children would also be reported.
+		if !pj.warnedDepth {
+			pj.warnedDepth = true
+			pj.addWarning(WarningDeepNesting, int(idx), "nesting depth exceeds %d", WarnNestingDepth)
+		}
+	}
+	pj.spanStack = append(pj.spanStack, len(pj.spans))
+	pj.spans = append(pj.spans, containerSpan{tapeOffset: pj.get_current_loc(), srcStart: idx})
+}
+
+// closeSpan records the end of the innermost open object or array at buf[idx].
+func (pj *internalParsedJson) closeSpan(idx uint64) {
+	n := pj.spanStack[len(pj.spanStack)-1]
+	pj.spanStack = pj.spanStack[:len(pj.spanStack)-1]
+	pj.spans[n].srcEnd = idx + 1
+}
+
+// emptyKey returns whether the key just added at buf[idx] is empty and rejected by WithRejectEmptyKeys.
+func (pj *internalParsedJson) emptyKey(idx uint64) bool {
+	if pj.rejectEmptyKeys && pj.Tape[len(pj.Tape)-1] == 0 {
+		pj.stage2Err = &EmptyError{Offset: int(idx), Kind: "key"}
+		return true
+	}
+	return false
+}
+
+// checkEscapes adds a warning if the string at buf[idx] contains a non-canonical escape.
+func (pj *internalParsedJson) checkEscapes(idx uint64) {
+	raw := pj.Message[idx : idx+rawStringLen(pj.Message[idx:])]
+	if esc := nonCanonicalEscape(raw); esc != nil {
+		pj.addWarning(WarningNonCanonicalEscape, int(idx), "non-canonical escape %q in string", esc)
+	}
+}
+
+// checkPrecision adds a warning if the number in buf was stored with the tag
+// of an integer that did not fit in 64 bits.
+func (pj *internalParsedJson) checkPrecision(buf []byte, tag uint64) {
+	if Tag(tag>>JSONTAGOFFSET) == TagFloat && tag&uint64(FloatOverflowedInteger) != 0 {
+		pj.addWarning(WarningPrecisionLoss, len(pj.Message)-len(buf), "integer does not fit in 64 bits and is stored as a float")
+	}
+}
+
+// emptyContainer returns whether the object or array ending at buf[idx] is rejected by WithRejectEmptyContainers.
+// Must only be called when the end immediately follows the start.
+func (pj *internalParsedJson) emptyContainer(buf []byte, idx uint64) bool {
+	if !pj.rejectEmptyContainers {
+		return false
+	}
+	// Only whitespace can separate the start and end.
+	start := idx
+	for start > 0 && buf[start] != '{' && buf[start] != '[' {
+		start--
+	}
+	kind := "object"
+	if buf[idx] == ']' {
+		kind = "array"
+	}
+	pj.stage2Err = &EmptyError{Offset: int(start), Kind: kind}
+	return true
+}
+
+// tooManyElements returns whether the tape exceeds the limit set by WithMaxElements.
+func (pj *internalParsedJson) tooManyElements() bool {
+	if pj.maxElements > 0 && len(pj.Tape) > pj.maxElements {
+		pj.stage2Err = ErrTooManyElements
+		return true
+	}
+	return false
+}
+
+// cancelCheckInterval is the number of values stage 2 will parse between checks for cancellation.
+const cancelCheckInterval = 1024
+
+// stage2Cancelled returns whether parsing has been cancelled.
+// The cancellation is only checked every cancelCheckInterval calls.
+func (pj *internalParsedJson) stage2Cancelled() bool {
+	if pj.done == nil {
+		return false
+	}
+	pj.cancelChecks++
+	if pj.cancelChecks%cancelCheckInterval != 0 || !pj.cancelled() {
+		return false
+	}
+	pj.aborted = true
+	return true
+}
+
 func (pj *internalParsedJson) unifiedMachine() (ok, done bool) {
 	buf := pj.Message
 	const addOneForRoot = 1
@@ -176,10 +349,16 @@ func (pj *internalParsedJson) unifiedMachine() (ok, done bool) {
 continueRoot:
 	switch buf[idx] {
 	case '{':
+		if pj.trackContainers {
+			pj.openSpan(idx)
+		}
 		pj.containingScopeOffset = append(pj.containingScopeOffset, (pj.get_current_loc()<<retAddressShift)|retAddressStartConst)
 		pj.write_tape(0, '{')
 		goto object_begin
 	case '[':
+		if pj.trackContainers {
+			pj.openSpan(idx)
+		}
 		pj.containingScopeOffset = append(pj.containingScopeOffset, (pj.get_current_loc()<<retAddressShift)|retAddressStartConst)
 		pj.write_tape(0, '[')
 		goto arrayBegin
@@ -188,6 +367,9 @@ continueRoot:
 	}
 
 startContinue:
+	if pj.tooManyElements() || pj.stage2Cancelled() {
+		goto fail
+	}
 	// We are back at the top, read the next char and we should be done
 	if done, idx = updateChar(pj, idx); done {
 		goto succeed
@@ -228,11 +410,20 @@ object_begin:
 	}
 	switch buf[idx] {
 	case '"':
-		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.copyStrings) {
+		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.messagePadding, pj.copyKeys, pj.preserveEscapes, pj.intern) {
+			goto fail
+		}
+		if pj.collectWarnings {
+			pj.checkEscapes(idx)
+		}
+		if pj.emptyKey(idx) {
 			goto fail
 		}
 		goto object_key_state
 	case '}':
+		if pj.emptyContainer(buf, idx) {
+			goto fail
+		}
 		goto scopeEnd // could also go to object_continue
 	default:
 		goto fail
@@ -250,40 +441,57 @@ object_key_state:
 	}
 	switch buf[idx] {
 	case '"':
-		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.copyStrings) {
+		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.messagePadding, pj.copyValues, pj.preserveEscapes, pj.intern) {
 			goto fail
 		}
+		if pj.collectWarnings {
+			pj.checkEscapes(idx)
+		}
 
 	case 't':
 		if !isValidTrueAtom(buf[idx:]) {
+			pj.stage2Err = literalError(buf, idx, "true")
 			goto fail
 		}
 		pj.write_tape(0, 't')
 
 	case 'f':
 		if !isValidFalseAtom(buf[idx:]) {
+			pj.stage2Err = literalError(buf, idx, "false")
 			goto fail
 		}
 		pj.write_tape(0, 'f')
 
 	case 'n':
 		if !isValidNullAtom(buf[idx:]) {
+			pj.stage2Err = literalError(buf, idx, "null")
 			goto fail
 		}
 		pj.write_tape(0, 'n')
 
 	case '-':
-		if !addNumber(buf[idx:], &pj.ParsedJson) {
+		if !addNumber(buf[idx:], pj) {
+			goto fail
+		}
+
+	case '+':
+		if !pj.leadingPlus || pj.strict || !addPlusNumber(buf[idx:], pj) {
 			goto fail
 		}
 
 	case '{':
+		if pj.trackContainers {
+			pj.openSpan(idx)
+		}
 		pj.containingScopeOffset = append(pj.containingScopeOffset, (pj.get_current_loc()<<retAddressShift)|retAddressObjectConst)
 		pj.write_tape(0, '{')
 		// we have not yet encountered } so we need to come back for it
 		goto object_begin
 
 	case '[':
+		if pj.trackContainers {
+			pj.openSpan(idx)
+		}
 		pj.containingScopeOffset = append(pj.containingScopeOffset, (pj.get_current_loc()<<retAddressShift)|retAddressObjectConst)
 		pj.write_tape(0, '[')
 		// we have not yet encountered } so we need to come back for it
@@ -291,7 +499,7 @@ object_key_state:
 
 	default:
 		if buf[idx] >= '0' && buf[idx] <= '9' {
-			if !addNumber(buf[idx:], &pj.ParsedJson) {
+			if !addNumber(buf[idx:], pj) {
 				goto fail
 			}
 			break
@@ -300,6 +508,9 @@ object_key_state:
 	}
 
 objectContinue:
+	if pj.tooManyElements() || pj.stage2Cancelled() {
+		goto fail
+	}
 	if done, idx = updateChar(pj, idx); done {
 		goto succeed
 	}
@@ -311,7 +522,13 @@ objectContinue:
 		if buf[idx] != '"' {
 			goto fail
 		}
-		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.copyStrings) {
+		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.messagePadding, pj.copyKeys, pj.preserveEscapes, pj.intern) {
+			goto fail
+		}
+		if pj.collectWarnings {
+			pj.checkEscapes(idx)
+		}
+		if pj.emptyKey(idx) {
 			goto fail
 		}
 		goto object_key_state
@@ -332,6 +549,9 @@ scopeEnd:
 
 	pj.write_tape(offset>>retAddressShift, buf[idx])
 	pj.annotate_previousloc(offset>>retAddressShift, pj.get_current_loc())
+	if pj.trackContainers {
+		pj.closeSpan(idx)
+	}
 
 	/* goto saved_state*/
 	switch offset & ((1 << retAddressShift) - 1) {
@@ -349,6 +569,9 @@ arrayBegin:
 		goto succeed
 	}
 	if buf[idx] == ']' {
+		if pj.emptyContainer(buf, idx) {
+			goto fail
+		}
 		goto scopeEnd // could also go to array_continue
 	}
 
@@ -357,40 +580,57 @@ mainArraySwitch:
 	// on paths that can accept a close square brace (post-, and at start)
 	switch buf[idx] {
 	case '"':
-		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.copyStrings) {
+		if !parseString(&pj.ParsedJson, idx, peekSize(pj), pj.messagePadding, pj.copyValues, pj.preserveEscapes, pj.intern) {
 			goto fail
 		}
+		if pj.collectWarnings {
+			pj.checkEscapes(idx)
+		}
 	case 't':
 		if !isValidTrueAtom(buf[idx:]) {
+			pj.stage2Err = literalError(buf, idx, "true")
 			goto fail
 		}
 		pj.write_tape(0, 't')
 
 	case 'f':
 		if !isValidFalseAtom(buf[idx:]) {
+			pj.stage2Err = literalError(buf, idx, "false")
 			goto fail
 		}
 		pj.write_tape(0, 'f')
 
 	case 'n':
 		if !isValidNullAtom(buf[idx:]) {
+			pj.stage2Err = literalError(buf, idx, "null")
 			goto fail
 		}
 		pj.write_tape(0, 'n')
 		/* goto array_continue */
 
 	case '-':
-		if !addNumber(buf[idx:], &pj.ParsedJson) {
+		if !addNumber(buf[idx:], pj) {
+			goto fail
+		}
+
+	case '+':
+		if !pj.leadingPlus || pj.strict || !addPlusNumber(buf[idx:], pj) {
 			goto fail
 		}
 
 	case '{':
+		if pj.trackContainers {
+			pj.openSpan(idx)
+		}
 		// we have not yet encountered ] so we need to come back for it
 		pj.containingScopeOffset = append(pj.containingScopeOffset, (pj.get_current_loc()<<retAddressShift)|retAddressArrayConst)
 		pj.write_tape(0, '{') //  here the compilers knows what c is so this gets optimized
 		goto object_begin
 
 	case '[':
+		if pj.trackContainers {
+			pj.openSpan(idx)
+		}
 		// we have not yet encountered ] so we need to come back for it
 		pj.containingScopeOffset = append(pj.containingScopeOffset, (pj.get_current_loc()<<retAddressShift)|retAddressArrayConst)
 		pj.write_tape(0, '[') // here the compilers knows what c is so this gets optimized
@@ -398,7 +638,7 @@ mainArraySwitch:
 
 	default:
 		if buf[idx] >= '0' && buf[idx] <= '9' {
-			if !addNumber(buf[idx:], &pj.ParsedJson) {
+			if !addNumber(buf[idx:], pj) {
 				goto fail
 			}
 			break
@@ -407,6 +647,9 @@ mainArraySwitch:
 	}
 
 arrayContinue:
+	if pj.tooManyElements() || pj.stage2Cancelled() {
+		goto fail
+	}
 	if done, idx = updateChar(pj, idx); done {
 		goto succeed
 	}
@@ -432,16 +675,25 @@ succeed:
 
 	// Sanity checks
 	if len(pj.containingScopeOffset) != 0 {
-		return false, done
+		goto fail
 	}
 
 	pj.annotate_previousloc(offset>>retAddressShift, pj.get_current_loc()+addOneForRoot)
 	pj.write_tape(offset>>retAddressShift, 'r') // r is root
+	if pj.tooManyElements() {
+		goto fail
+	}
 
 	pj.isvalid = true
 	return true, done
 
 fail:
+	if done {
+		// The input ended before the value was complete.
+		pj.failOffset = len(buf)
+	} else {
+		pj.failOffset = int(idx)
+	}
 	return false, done
 }
 
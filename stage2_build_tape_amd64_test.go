@@ -20,6 +20,7 @@
 package simdjson
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -185,7 +186,7 @@ func TestStage2BuildTape(t *testing.T) {
 			//}
 			//fmt.Printf("{%s, 0x%x},\n", c, tp&0xffffffffffffff)
 			expected := tc.expected[ii].val | (uint64(tc.expected[ii].c) << 56)
-			if !pj.copyStrings && tp != expected {
+			if !pj.copyKeys && !pj.copyValues && tp != expected {
 				t.Errorf("TestStage2BuildTape(%d): got: %d want: %d", ii, tp, expected)
 			}
 		}
@@ -260,3 +261,42 @@ func TestIsValidNullAtom(t *testing.T) {
 		}
 	}
 }
+
+func TestStage2Cancelled(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	// Small enough to run stage 1 to completion before stage 2,
+	// with enough values for stage 2 to check for cancellation.
+	msg := []byte("[" + strings.Repeat(`1,{"a":[2]},`, 300) + "3]")
+	if len(msg) > 8<<10 {
+		t.Fatalf("input too large for synchronous parsing: %d", len(msg))
+	}
+	pj := internalParsedJson{}
+	pj.Message = msg
+	pj.initialize(len(msg))
+	pj.indexChans = make(chan indexChan, indexSlots-2)
+	pj.buffersOffset = ^uint64(0)
+	if !pj.findStructuralIndices() {
+		t.Fatal("stage 1 failed")
+	}
+
+	// Cancel after stage 1 has completed.
+	done := make(chan struct{})
+	close(done)
+	pj.done = done
+	if ok, _ := pj.unifiedMachine(); ok {
+		t.Fatal("want stage 2 to stop")
+	}
+	if !pj.aborted {
+		t.Error("want aborted")
+	}
+
+	// Parsing the same input without cancellation must succeed.
+	pj.discardIndexes()
+	pj.done = nil
+	pj.aborted = false
+	if err := pj.parseMessage(msg, false); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errInvalidUTF8 = errors.New("input is not valid UTF-8")
+
+// trimJSONSpace removes leading and trailing JSON whitespace.
+func trimJSONSpace(b []byte) []byte {
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+	}
+	for len(b) > 0 && isSpace(b[0]) {
+		b = b[1:]
+	}
+	for len(b) > 0 && isSpace(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// unwrapScalarRoot will convert a tape containing a single root
+// with an array containing a single scalar value to a root containing the value.
+func (pj *ParsedJson) unwrapScalarRoot() error {
+	tape := pj.Tape
+	if len(tape) < 5 || Tag(tape[1]>>JSONTAGOFFSET) != TagArrayStart {
+		return errors.New("expected single value")
+	}
+	size := 1
+	switch Tag(tape[2] >> JSONTAGOFFSET) {
+	case TagString, TagInteger, TagUint, TagFloat:
+		size = 2
+	case TagNull, TagBoolTrue, TagBoolFalse:
+	default:
+		return errors.New("expected single value")
+	}
+	// The array must end right after the value, followed by the root end.
+	end := 2 + size
+	if tape[1]&JSONVALUEMASK != uint64(end+1) || len(tape) != end+2 {
+		return errors.New("expected single value")
+	}
+	// The root points to the entry after the closing root.
+	copy(tape[1:], tape[2:end])
+	tape[0] = (uint64(TagRoot) << JSONTAGOFFSET) | uint64(size+2)
+	tape[size+1] = uint64(TagRoot) << JSONTAGOFFSET
+	pj.Tape = tape[:size+2]
+	return nil
+}
+
+// checkDuplicateKeys returns an error if any object contains duplicate keys.
+func checkDuplicateKeys(pj *ParsedJson) error {
+	var c duplicateChecker
+	i := pj.Iter()
+	var root Iter
+	for i.Advance() == TypeRoot {
+		typ, r, err := i.Root(&root)
+		if err != nil {
+			return err
+		}
+		if typ == TypeObject || typ == TypeArray {
+			if err := c.check(r, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// duplicateChecker keeps a set of seen keys for each level of nesting.
+type duplicateChecker struct {
+	seen []map[string]struct{}
+
+	// warn is called with duplicate keys and the object if set,
+	// otherwise an error is returned for the first duplicate.
+	warn func(name []byte, obj *Iter)
+}
+
+func (c *duplicateChecker) check(i *Iter, depth int) error {
+	var tmp Iter
+	switch i.Type() {
+	case TypeObject:
+		var obj Object
+		if _, err := i.Object(&obj); err != nil {
+			return err
+		}
+		for depth >= len(c.seen) {
+			c.seen = append(c.seen, make(map[string]struct{}))
+		}
+		seen := c.seen[depth]
+		for k := range seen {
+			delete(seen, k)
+		}
+		for {
+			name, t, err := obj.NextElementBytes(&tmp)
+			if err != nil {
+				return err
+			}
+			if t == TypeNone {
+				return nil
+			}
+			if _, ok := seen[string(name)]; ok {
+				if c.warn == nil {
+					return fmt.Errorf("duplicate key %q in object", name)
+				}
+				c.warn(name, i)
+			}
+			seen[string(name)] = struct{}{}
+			if t == TypeObject || t == TypeArray {
+				if err := c.check(&tmp, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	case TypeArray:
+		var arr Array
+		if _, err := i.Array(&arr); err != nil {
+			return err
+		}
+		tmp = arr.Iter()
+		for {
+			t := tmp.Advance()
+			if t == TypeNone {
+				return nil
+			}
+			if t == TypeObject || t == TypeArray {
+				if err := c.check(&tmp, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// UnmarshalTo will parse the JSON in data and store the result in the value pointed to by v.
+// The value in data can be any JSON value, including a scalar at the root,
+// so it can be used with json.RawMessage fragments.
+//
+// Values are decoded similar to encoding/json:
+// Struct fields are matched by their `json` tag name or field name,
+// preferring an exact match but also accepting a case-insensitive match.
+// Unknown keys are ignored. Pointers are allocated as needed.
+// Values decoded into an interface{} follow the rules of Iter.Interface().
+//
+// Nested values implementing json.Unmarshaler will have their UnmarshalJSON method called.
+// UnmarshalJSON is never called on v itself, so UnmarshalTo can be used to implement
+// json.Unmarshaler for a type:
+//
+//	func (t *T) UnmarshalJSON(b []byte) error {
+//		return simdjson.UnmarshalTo(b, t)
+//	}
+func UnmarshalTo(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal: destination must be a non-nil pointer, got %T", v)
+	}
+	i, _, err := parseValue(data, WithCopyStrings(false))
+	if err != nil {
+		return err
+	}
+	return decodeValue(&i, rv.Elem(), false)
+}
+
+// As will decode the current value of the iterator into the value pointed to by v.
+// Values are decoded as described in UnmarshalTo, so for example
+// integers, unsigned integers and floats can all be decoded into an int64,
+// and objects can be decoded into structs and maps.
+// If no value is queued, the next value is read.
+// If the value is a root, its content is decoded.
+// The iterator is not advanced.
+func (i *Iter) As(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal: destination must be a non-nil pointer, got %T", v)
+	}
+	it := *i
+	if it.Type() == TypeNone {
+		it.Advance()
+	}
+	if it.Type() == TypeRoot {
+		_, root, err := it.Root(nil)
+		if err != nil {
+			return err
+		}
+		it = *root
+	}
+	if it.Type() == TypeNone {
+		return errors.New("no value queued in iterator")
+	}
+	return decodeValue(&it, rv.Elem(), true)
+}
+
+// Unmarshal will decode the elements of the array into the slice pointed to by v.
+// Elements are decoded as described in UnmarshalTo.
+// Existing slice capacity is reused, and the slice is grown as needed.
+// If an element cannot be decoded the returned error will contain its index.
+// The array is not modified.
+func (a *Array) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("unmarshal: destination must be a non-nil pointer to a slice, got %T", v)
+	}
+	return decodeSliceElems(a, rv.Elem())
+}
+
+// parseValue will parse a single JSON value and return an iterator with the value queued,
+// as well as the parsed JSON containing it.
+// Scalar values at the root are supported.
+func parseValue(data []byte, opts ...ParserOption) (Iter, *ParsedJson, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Iter{}, nil, errors.New("unexpected end of JSON input")
+	}
+	pj, err := Parse(data, nil, append(opts[:len(opts):len(opts)], WithAllowScalarRoot(true))...)
+	if err != nil {
+		return Iter{}, nil, err
+	}
+	i := pj.Iter()
+	i.AdvanceInto()
+	_, root, err := i.Root(nil)
+	if err != nil {
+		return Iter{}, nil, err
+	}
+	return *root, pj, nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decodeValue will decode the value queued in i into v.
+// If checkUnmarshaler is false json.Unmarshaler will not be used for v,
+// but will still be used for any values contained in v.
+func decodeValue(i *Iter, v reflect.Value, checkUnmarshaler bool) error {
+	typ := i.Type()
+	for {
+		if checkUnmarshaler && v.Kind() != reflect.Pointer && v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+			cpy := *i
+			b, err := cpy.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			return v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b)
+		}
+		checkUnmarshaler = true
+		if v.Kind() != reflect.Pointer {
+			break
+		}
+		if typ == TypeNull {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if typ == TypeNull {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			break
+		}
+		val, err := i.Interface()
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(val))
+		}
+		return nil
+	case reflect.Bool:
+		if typ != TypeBool {
+			break
+		}
+		v.SetBool(i.t == TagBoolTrue)
+		return nil
+	case reflect.String:
+		if typ != TypeString {
+			break
+		}
+		s, err := i.String()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ != TypeInt && typ != TypeUint && typ != TypeFloat {
+			break
+		}
+		n, err := i.Int()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %v", n, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if typ != TypeInt && typ != TypeUint && typ != TypeFloat {
+			break
+		}
+		n, err := i.Uint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %v", n, v.Type())
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if typ != TypeInt && typ != TypeUint && typ != TypeFloat {
+			break
+		}
+		f, err := i.Float()
+		if err != nil {
+			return err
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows %v", f, v.Type())
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if typ == TypeString && v.Type().Elem().Kind() == reflect.Uint8 {
+			sb, err := i.StringBytes()
+			if err != nil {
+				return err
+			}
+			b := make([]byte, base64.StdEncoding.DecodedLen(len(sb)))
+			n, err := base64.StdEncoding.Decode(b, sb)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b[:n])
+			return nil
+		}
+		if typ != TypeArray {
+			break
+		}
+		return decodeSlice(i, v)
+	case reflect.Array:
+		if typ != TypeArray {
+			break
+		}
+		return decodeArray(i, v)
+	case reflect.Map:
+		if typ != TypeObject {
+			break
+		}
+		return decodeMap(i, v)
+	case reflect.Struct:
+		if typ != TypeObject {
+			break
+		}
+		return decodeStruct(i, v)
+	}
+	return fmt.Errorf("cannot unmarshal %v into Go value of type %v", typ, v.Type())
+}
+
+func decodeSlice(i *Iter, v reflect.Value) error {
+	arr, err := i.Array(nil)
+	if err != nil {
+		return err
+	}
+	return decodeSliceElems(arr, v)
+}
+
+// decodeSliceElems will decode the elements of arr into the slice v.
+func decodeSliceElems(arr *Array, v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	} else {
+		v.SetLen(0)
+	}
+	zero := reflect.Zero(v.Type().Elem())
+	ai := arr.Iter()
+	var elem Iter
+	for n := 0; ; n++ {
+		t, err := ai.AdvanceIter(&elem)
+		if err != nil {
+			return err
+		}
+		if t == TypeNone {
+			return nil
+		}
+		if n < v.Cap() {
+			v.SetLen(n + 1)
+			v.Index(n).Set(zero)
+		} else {
+			v.Set(reflect.Append(v, zero))
+		}
+		if err := decodeValue(&elem, v.Index(n), true); err != nil {
+			return fmt.Errorf("array index %d: %w", n, err)
+		}
+	}
+}
+
+func decodeArray(i *Iter, v reflect.Value) error {
+	arr, err := i.Array(nil)
+	if err != nil {
+		return err
+	}
+	ai := arr.Iter()
+	var elem Iter
+	n := 0
+	for ; ; n++ {
+		t, err := ai.AdvanceIter(&elem)
+		if err != nil {
+			return err
+		}
+		if t == TypeNone {
+			break
+		}
+		if n >= v.Len() {
+			// Ignore extra elements
+			continue
+		}
+		if err := decodeValue(&elem, v.Index(n), true); err != nil {
+			return fmt.Errorf("array index %d: %w", n, err)
+		}
+	}
+	// Zero remaining.
+	zero := reflect.Zero(v.Type().Elem())
+	for ; n < v.Len(); n++ {
+		v.Index(n).Set(zero)
+	}
+	return nil
+}
+
+func decodeMap(i *Iter, v reflect.Value) error {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot unmarshal object into Go value of type %v", t)
+	}
+	obj, err := i.Object(nil)
+	if err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	var elem Iter
+	for {
+		name, typ, err := obj.NextElement(&elem)
+		if err != nil {
+			return err
+		}
+		if typ == TypeNone {
+			return nil
+		}
+		val := reflect.New(t.Elem()).Elem()
+		if err := decodeValue(&elem, val, true); err != nil {
+			return fmt.Errorf("parsing element %q: %w", name, err)
+		}
+		v.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), val)
+	}
+}
+
+func decodeStruct(i *Iter, v reflect.Value) error {
+	fields := cachedStructFields(v.Type())
+	obj, err := i.Object(nil)
+	if err != nil {
+		return err
+	}
+	var elem Iter
+	for {
+		name, typ, err := obj.NextElementBytes(&elem)
+		if err != nil {
+			return err
+		}
+		if typ == TypeNone {
+			return nil
+		}
+		f := fields.find(name)
+		if f == nil {
+			continue
+		}
+		fv, err := fieldByIndex(v, f.index)
+		if err != nil {
+			return err
+		}
+		if err := decodeValue(&elem, fv, true); err != nil {
+			return fmt.Errorf("parsing field %q: %w", f.name, err)
+		}
+	}
+}
+
+// fieldByIndex returns the nested field of v,
+// allocating embedded struct pointers as needed.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for n, idx := range index {
+		if n > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, fmt.Errorf("cannot set embedded pointer to unexported struct: %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+type structFields []structField
+
+// find returns the field matching name.
+// An exact match is preferred, otherwise a case-insensitive match is returned.
+func (s structFields) find(name []byte) *structField {
+	for n := range s {
+		if s[n].name == string(name) {
+			return &s[n]
+		}
+	}
+	for n := range s {
+		if strings.EqualFold(s[n].name, string(name)) {
+			return &s[n]
+		}
+	}
+	return nil
+}
+
+var structFieldCache sync.Map // map[reflect.Type]structFields
+
+func cachedStructFields(t reflect.Type) structFields {
+	if f, ok := structFieldCache.Load(t); ok {
+		return f.(structFields)
+	}
+	f, _ := structFieldCache.LoadOrStore(t, typeFields(t, nil, make(map[reflect.Type]bool)))
+	return f.(structFields)
+}
+
+// typeFields returns the fields that should be recognized for the given type.
+// Fields of embedded structs are promoted, unless shadowed by a field at a lower depth.
+// visited contains the types being expanded, so a type embedding itself,
+// directly or through other types, is not expanded again.
+func typeFields(t reflect.Type, index []int, visited map[reflect.Type]bool) structFields {
+	visited[t] = true
+	defer delete(visited, t)
+	var fields, embedded structFields
+	seen := make(map[string]struct{})
+	for n := 0; n < t.NumField(); n++ {
+		sf := t.Field(n)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			name = tag[:idx]
+		}
+		idx := append(append(make([]int, 0, len(index)+1), index...), n)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !visited[ft] {
+					embedded = append(embedded, typeFields(ft, idx, visited)...)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		seen[name] = struct{}{}
+		fields = append(fields, structField{name: name, index: idx})
+	}
+	for _, f := range embedded {
+		if _, ok := seen[f.name]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
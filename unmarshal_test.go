@@ -0,0 +1,349 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testThumbnail struct {
+	Url    string
+	Height int
+	Width  int `json:"Width"`
+}
+
+type testImage struct {
+	Width     int
+	Height    uint16
+	Title     string `json:"title"`
+	Thumbnail *testThumbnail
+	Animated  bool
+	IDs       []int64
+	Ignored   string `json:"-"`
+}
+
+// testWrapped uses UnmarshalTo to implement json.Unmarshaler.
+type testWrapped struct {
+	Image testImage
+	calls int
+}
+
+func (t *testWrapped) UnmarshalJSON(b []byte) error {
+	t.calls++
+	return UnmarshalTo(b, t)
+}
+
+// testRecursive embeds a pointer to itself.
+type testRecursive struct {
+	*testRecursive
+	A int
+	B *testRecursiveB
+}
+
+type testRecursiveB struct {
+	*testRecursiveC
+	B int
+}
+
+type testRecursiveC struct {
+	*testRecursiveB
+	C int
+}
+
+func TestUnmarshalTo(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	t.Run("struct", func(t *testing.T) {
+		var got struct {
+			Image testImage
+		}
+		if err := UnmarshalTo([]byte(demo_json), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := testImage{
+			Width:     800,
+			Height:    600,
+			Title:     "View from 15th Floor",
+			Thumbnail: &testThumbnail{Url: "http://www.example.com/image/481989943", Height: 125, Width: 100},
+			IDs:       []int64{116, 943, 234, 38793},
+		}
+		if !reflect.DeepEqual(got.Image, want) {
+			t.Errorf("got %+v, want %+v", got.Image, want)
+		}
+	})
+	t.Run("unmarshaler", func(t *testing.T) {
+		var got testWrapped
+		if err := json.Unmarshal([]byte(demo_json), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.calls != 1 || got.Image.Width != 800 || got.Image.Thumbnail.Height != 125 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+	t.Run("rawmessage", func(t *testing.T) {
+		var raw struct {
+			Image map[string]json.RawMessage
+		}
+		if err := json.Unmarshal([]byte(demo_json), &raw); err != nil {
+			t.Fatal(err)
+		}
+		var width int
+		if err := UnmarshalTo(raw.Image["Width"], &width); err != nil {
+			t.Fatal(err)
+		}
+		if width != 800 {
+			t.Errorf("want 800, got %d", width)
+		}
+		var title string
+		if err := UnmarshalTo(raw.Image["Title"], &title); err != nil {
+			t.Fatal(err)
+		}
+		if title != "View from 15th Floor" {
+			t.Errorf("unexpected title %q", title)
+		}
+		var animated *bool
+		if err := UnmarshalTo(raw.Image["Animated"], &animated); err != nil {
+			t.Fatal(err)
+		}
+		if animated == nil || *animated {
+			t.Errorf("unexpected animated %v", animated)
+		}
+		// Nested RawMessage.
+		var nested struct {
+			Image struct {
+				Thumbnail json.RawMessage
+			}
+		}
+		if err := UnmarshalTo([]byte(demo_json), &nested); err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"Url":"http://www.example.com/image/481989943","Height":125,"Width":100}`; string(nested.Image.Thumbnail) != want {
+			t.Errorf("got %s, want %s", nested.Image.Thumbnail, want)
+		}
+	})
+	t.Run("recursive-embedded", func(t *testing.T) {
+		var got testRecursive
+		// Fields of the embedded pointers are recognized, but cannot be set,
+		// since the embedded types are unexported.
+		if err := UnmarshalTo([]byte(`{"A":1,"B":{"B":2}}`), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.A != 1 || got.testRecursive != nil || got.B == nil || got.B.B != 2 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		var v int8
+		for _, in := range []string{`1000`, `"str"`, `1 2`, ``, `[1]`} {
+			if err := UnmarshalTo([]byte(in), &v); err == nil {
+				t.Errorf("%q: want error, got none", in)
+			}
+		}
+		if err := UnmarshalTo([]byte(`1`), v); err == nil {
+			t.Error("want error for non-pointer")
+		}
+	})
+}
+
+func TestArray_Unmarshal(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	getArray := func(t *testing.T, js string) *Array {
+		t.Helper()
+		pj, err := Parse([]byte(js), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := pj.Iter()
+		i.AdvanceInto()
+		_, root, err := i.Root(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arr, err := root.Array(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return arr
+	}
+	arr := getArray(t, `[{"Url":"a","Height":1,"Width":2},{"Url":"b","Height":3},{}]`)
+	want := []testThumbnail{{Url: "a", Height: 1, Width: 2}, {Url: "b", Height: 3}, {}}
+
+	var got []testThumbnail
+	if err := arr.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Existing capacity is reused and old values are cleared.
+	reuse := make([]testThumbnail, 1, 10)
+	reuse[0].Width = 100
+	if err := arr.Unmarshal(&reuse); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reuse, want) || cap(reuse) != 10 {
+		t.Errorf("got %+v (cap %d), want %+v", reuse, cap(reuse), want)
+	}
+
+	var ptrs []*testThumbnail
+	if err := arr.Unmarshal(&ptrs); err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 3 || *ptrs[1] != want[1] {
+		t.Errorf("got %+v", ptrs)
+	}
+
+	// Element errors contain the index.
+	err := getArray(t, `[{"Height":1},{"Height":"x"}]`).Unmarshal(&got)
+	if err == nil || !strings.Contains(err.Error(), "array index 1") {
+		t.Errorf("want error for index 1, got %v", err)
+	}
+
+	for _, dst := range []interface{}{got, &struct{}{}, (*[]int)(nil), nil} {
+		if err := arr.Unmarshal(dst); err == nil {
+			t.Errorf("want error for %T", dst)
+		}
+	}
+}
+
+func TestIter_As(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	pj, err := Parse([]byte(`{"i":-5,"u":18446744073709551615,"f":2.0,"s":"str","b":true,"n":null,"a":[1,2],"o":{"Url":"x","Height":3}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := pj.Iter()
+	get := func(key string) Iter {
+		t.Helper()
+		e, err := root.FindElement(nil, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e.Iter
+	}
+	var n int64
+	for key, want := range map[string]int64{"i": -5, "f": 2} {
+		it := get(key)
+		if err := it.As(&n); err != nil || n != want {
+			t.Errorf("%s: want %d, got %d (err: %v)", key, want, n, err)
+		}
+	}
+	it := get("u")
+	if err := it.As(&n); err == nil {
+		t.Error("want overflow error")
+	}
+	var u uint64
+	if err := it.As(&u); err != nil || u != 18446744073709551615 {
+		t.Errorf("got %d (err: %v)", u, err)
+	}
+	var f float64
+	it = get("i")
+	if err := it.As(&f); err != nil || f != -5 {
+		t.Errorf("got %v (err: %v)", f, err)
+	}
+	var s string
+	it = get("s")
+	if err := it.As(&s); err != nil || s != "str" {
+		t.Errorf("got %q (err: %v)", s, err)
+	}
+	if err := it.As(&n); err == nil {
+		t.Error("want error for string into int")
+	}
+	var b bool
+	it = get("b")
+	if err := it.As(&b); err != nil || !b {
+		t.Errorf("got %v (err: %v)", b, err)
+	}
+	p := &s
+	it = get("n")
+	if err := it.As(&p); err != nil || p != nil {
+		t.Errorf("got %v (err: %v)", p, err)
+	}
+	var arr []int
+	it = get("a")
+	if err := it.As(&arr); err != nil || !reflect.DeepEqual(arr, []int{1, 2}) {
+		t.Errorf("got %v (err: %v)", arr, err)
+	}
+	var th testThumbnail
+	it = get("o")
+	if err := it.As(&th); err != nil || th != (testThumbnail{Url: "x", Height: 3}) {
+		t.Errorf("got %+v (err: %v)", th, err)
+	}
+	var m map[string]interface{}
+	if err := it.As(&m); err != nil || m["Url"] != "x" {
+		t.Errorf("got %v (err: %v)", m, err)
+	}
+
+	// The iterator is not advanced.
+	var again testThumbnail
+	if err := it.As(&again); err != nil || again != th {
+		t.Errorf("got %+v (err: %v)", again, err)
+	}
+
+	// Roots are decoded, and values are read if not queued.
+	var all map[string]interface{}
+	if err := root.As(&all); err != nil || len(all) != 8 {
+		t.Errorf("got %v (err: %v)", all, err)
+	}
+	var iface interface{}
+	it = pj.Iter()
+	it.Advance()
+	if err := it.As(&iface); err != nil || len(iface.(map[string]interface{})) != 8 {
+		t.Errorf("got %v (err: %v)", iface, err)
+	}
+	if err := it.As(n); err == nil {
+		t.Error("want error for non-pointer")
+	}
+	var empty Iter
+	if err := empty.As(&iface); err == nil {
+		t.Error("want error for empty iterator")
+	}
+}
+
+func ExampleUnmarshalTo() {
+	if !SupportedCPU() {
+		// Fake it
+		fmt.Println("800 600 http://www.example.com/image/481989943")
+		return
+	}
+	var dst struct {
+		Image struct {
+			Width, Height int
+			Thumbnail     struct {
+				Url string
+			}
+		}
+	}
+	err := UnmarshalTo([]byte(demo_json), &dst)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(dst.Image.Width, dst.Image.Height, dst.Image.Thumbnail.Url)
+	// Output:
+	// 800 600 http://www.example.com/image/481989943
+}
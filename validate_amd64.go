@@ -0,0 +1,310 @@
+//go:build !noasm && !appengine && gc
+// +build !noasm,!appengine,gc
+
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// validateReadSize is the number of bytes ValidateReader reads at the time.
+const validateReadSize = 32 << 10
+
+// ValidateReader will read a single JSON object or array from r until io.EOF
+// and return an error if it isn't valid JSON.
+// If more than maxBytes are read ErrInputTooLarge is returned.
+// If maxBytes is <= 0 there is no limit.
+//
+// The input is validated while it is read, and reading stops at the first error.
+// Stage 1 is run on each block read, carrying its state across blocks,
+// and the structure and values are checked as structural indexes are found.
+// Input is only kept until the value it belongs to has been checked,
+// so memory use does not depend on the input size, except for long strings and numbers.
+// Values are validated as by Parse with default options.
+func ValidateReader(r io.Reader, maxBytes int64) error {
+	if !SupportedCPU() {
+		return errors.New("Host CPU does not meet target specs")
+	}
+	v := streamValidator{
+		s:     newStage1(0),
+		last:  -1,
+		value: -1,
+	}
+	var read int64
+	for {
+		if cap(v.buf)-len(v.buf) < validateReadSize {
+			buf := make([]byte, len(v.buf), 2*cap(v.buf)+validateReadSize)
+			copy(buf, v.buf)
+			v.buf = buf
+		}
+		n, err := r.Read(v.buf[len(v.buf) : len(v.buf)+validateReadSize])
+		if n > 0 {
+			read += int64(n)
+			if maxBytes > 0 && read > maxBytes {
+				return ErrInputTooLarge
+			}
+			v.buf = v.buf[:len(v.buf)+n]
+			if err := v.next(false); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return v.next(true)
+}
+
+// validateState is the next input expected by streamValidator.
+type validateState uint8
+
+const (
+	validateRoot        validateState = iota // object or array at the top level
+	validateValue                            // any value
+	validateObjectStart                      // key or end of object
+	validateArrayStart                       // value or end of array
+	validateKey                              // key
+	validateColon                            // colon after key
+	validateNext                             // comma or end of object or array
+	validateDone                             // end of input
+)
+
+// streamValidator validates JSON that is supplied in blocks.
+type streamValidator struct {
+	s       stage1
+	indexes [indexSize]uint32
+
+	// buf contains the input from offset start.
+	buf   []byte
+	start int64
+	// scanned is the number of bytes in buf processed by stage 1.
+	scanned int
+
+	// last is the offset of the last structural index.
+	last int64
+	// value is the offset of a string or scalar that must be checked
+	// when the next index is found, or -1.
+	value int64
+
+	// stack contains the open objects and arrays.
+	stack []byte
+	state validateState
+
+	// scratch is used for checking values.
+	scratch []byte
+}
+
+// next will find structural indexes in the input that hasn't been scanned and check them.
+// Unless final is set, only whole blocks of 64 bytes are scanned.
+func (v *streamValidator) next(final bool) error {
+	for {
+		buf := v.buf[v.scanned:]
+		if !final && len(buf) < 64 {
+			break
+		}
+		length := 0
+		processed := v.s.find(buf, &v.indexes, &length, final)
+		if v.s.errorMask != 0 {
+			return fmt.Errorf("offset %d: unescaped control character in string", v.start+int64(v.scanned))
+		}
+		for _, idx := range v.indexes[:length] {
+			v.last += int64(idx)
+			if err := v.structural(v.last); err != nil {
+				return err
+			}
+		}
+		v.scanned += int(processed)
+		v.s.position -= processed
+		if final && v.scanned == len(v.buf) {
+			return v.end()
+		}
+	}
+
+	// Remove input that is no longer needed.
+	keep := v.start + int64(v.scanned)
+	if v.value >= 0 {
+		keep = v.value
+	}
+	if n := int(keep - v.start); n > 0 {
+		v.buf = v.buf[:copy(v.buf, v.buf[n:])]
+		v.scanned -= n
+		v.start = keep
+	}
+	return nil
+}
+
+// structural will check the structural character at offset.
+func (v *streamValidator) structural(offset int64) error {
+	if v.value >= 0 {
+		if err := v.checkValue(v.value, v.buf[v.value-v.start:offset-v.start]); err != nil {
+			return err
+		}
+		v.value = -1
+	}
+	c := v.buf[offset-v.start]
+	switch c {
+	case '{', '[':
+		if v.state != validateRoot && v.state != validateValue && v.state != validateArrayStart {
+			break
+		}
+		v.stack = append(v.stack, c)
+		v.state = validateArrayStart
+		if c == '{' {
+			v.state = validateObjectStart
+		}
+		return nil
+	case '}', ']':
+		open := byte('{')
+		if c == ']' {
+			open = '['
+		}
+		if len(v.stack) == 0 || v.stack[len(v.stack)-1] != open {
+			break
+		}
+		if v.state != validateNext && v.state != validateObjectStart && v.state != validateArrayStart {
+			break
+		}
+		if (v.state == validateObjectStart && c != '}') || (v.state == validateArrayStart && c != ']') {
+			break
+		}
+		v.stack = v.stack[:len(v.stack)-1]
+		v.afterValue()
+		return nil
+	case ',':
+		if v.state != validateNext {
+			break
+		}
+		v.state = validateValue
+		if v.stack[len(v.stack)-1] == '{' {
+			v.state = validateKey
+		}
+		return nil
+	case ':':
+		if v.state != validateColon {
+			break
+		}
+		v.state = validateValue
+		return nil
+	case '"':
+		switch v.state {
+		case validateObjectStart, validateKey:
+			v.value = offset
+			v.state = validateColon
+			return nil
+		case validateValue, validateArrayStart:
+			v.value = offset
+			v.afterValue()
+			return nil
+		}
+	default:
+		if v.state == validateValue || v.state == validateArrayStart {
+			v.value = offset
+			v.afterValue()
+			return nil
+		}
+	}
+	if v.state == validateRoot {
+		return fmt.Errorf("offset %d: expected object or array, got %q", offset, c)
+	}
+	return fmt.Errorf("offset %d: unexpected %q", offset, c)
+}
+
+// afterValue updates the state after a complete value.
+func (v *streamValidator) afterValue() {
+	if len(v.stack) == 0 {
+		v.state = validateDone
+		return
+	}
+	v.state = validateNext
+}
+
+// end checks the state at the end of the input.
+func (v *streamValidator) end() error {
+	if v.s.prevIterInsideQuote != 0 {
+		return errors.New("unexpected end of JSON input: unterminated string")
+	}
+	if v.value >= 0 {
+		if err := v.checkValue(v.value, v.buf[v.value-v.start:]); err != nil {
+			return err
+		}
+	}
+	switch v.state {
+	case validateRoot:
+		return ErrEmptyInput
+	case validateDone:
+		return nil
+	}
+	return errors.New("unexpected end of JSON input")
+}
+
+// checkValue will check the string or scalar value in b found at offset.
+// b contains the input until the next structural index.
+func (v *streamValidator) checkValue(offset int64, b []byte) error {
+	// Like the message, values are followed by a separator and padding.
+	v.scratch = append(append(v.scratch[:0], b...), make([]byte, 64)...)
+	v.scratch[len(b)] = ' '
+	buf := v.scratch
+	raw := bytes.TrimRight(b, " \t\n\r")
+	switch b[0] {
+	case '"':
+		if len(raw) < 2 || rawStringLen(raw) != uint64(len(raw)) || raw[len(raw)-1] != '"' {
+			return fmt.Errorf("offset %d: invalid string", offset)
+		}
+		maxStringSize, size, needCopy := uint64(len(b)), uint64(0), false
+		if !parseStringSimdValidateOnly(buf, &maxStringSize, &size, &needCopy) {
+			return fmt.Errorf("offset %d: invalid string", offset)
+		}
+		return nil
+	case 't':
+		if len(raw) != 4 || !isValidTrueAtom(buf) {
+			return v.literalError(offset, raw, "true")
+		}
+		return nil
+	case 'f':
+		if len(raw) != 5 || !isValidFalseAtom(buf) {
+			return v.literalError(offset, raw, "false")
+		}
+		return nil
+	case 'n':
+		if len(raw) != 4 || !isValidNullAtom(buf) {
+			return v.literalError(offset, raw, "null")
+		}
+		return nil
+	}
+	if c := b[0]; c != '-' && (c < '0' || c > '9') {
+		return fmt.Errorf("offset %d: invalid value", offset)
+	}
+	if tag, _ := parseNumber(buf); tag == 0 {
+		return fmt.Errorf("offset %d: %w", offset, numberError(buf))
+	}
+	return nil
+}
+
+// literalError returns the error for the invalid literal at offset.
+func (v *streamValidator) literalError(offset int64, raw []byte, want string) error {
+	err := literalError(raw, 0, want)
+	err.Offset = int(offset)
+	return err
+}
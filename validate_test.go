@@ -0,0 +1,166 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestValidateReader(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	tests := []struct {
+		name    string
+		input   string
+		max     int64
+		wantErr bool
+	}{
+		{name: "valid", input: demo_json},
+		{name: "valid-whitespace", input: " \n" + demo_json + "\r\n "},
+		{name: "valid-limit", input: demo_json, max: int64(len(demo_json))},
+		{name: "escaped-quote", input: `{"a\"}":"]\\"}`},
+		{name: "too-large", input: demo_json, max: int64(len(demo_json)) - 1, wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+		{name: "scalar", input: `"a"`, wantErr: true},
+		{name: "mismatched", input: `{"a":[1}`, wantErr: true},
+		{name: "unclosed", input: `{"a":[1]`, wantErr: true},
+		{name: "trailing", input: `{} {}`, wantErr: true},
+		{name: "invalid-value", input: `{"a":tru}`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Read one byte at a time to check incremental validation.
+			err := ValidateReader(iotest.OneByteReader(strings.NewReader(test.input)), test.max)
+			if (err != nil) != test.wantErr {
+				t.Errorf("want error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	// Reading stops at the first error.
+	// Input is checked in blocks of 64 bytes.
+	errRead := errors.New("read after error")
+	r := io.MultiReader(strings.NewReader(`{"a":[1}`+strings.Repeat(" ", 64)), iotest.ErrReader(errRead))
+	if err := ValidateReader(r, 0); err == nil || errors.Is(err, errRead) {
+		t.Errorf("want structure error, got %v", err)
+	}
+	r = io.MultiReader(strings.NewReader(demo_json), iotest.ErrReader(errRead))
+	if err := ValidateReader(r, 10); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("want %v, got %v", ErrInputTooLarge, err)
+	}
+	r = io.MultiReader(strings.NewReader(`{"a":`), iotest.ErrReader(errRead))
+	if err := ValidateReader(r, 0); !errors.Is(err, errRead) {
+		t.Errorf("want %v, got %v", errRead, err)
+	}
+
+	// Large input is read in several blocks.
+	large := loadCompressed(t, "twitter")
+	if err := ValidateReader(bytes.NewReader(large), 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateReaderParse(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	check := func(t *testing.T, in []byte) {
+		t.Helper()
+		_, want := Parse(in, nil)
+		got := ValidateReader(iotest.HalfReader(bytes.NewReader(in)), 0)
+		if (got == nil) != (want == nil) {
+			if len(in) > 100 {
+				in = in[:100]
+			}
+			t.Errorf("%q: want error %v, got %v", in, want, got)
+		}
+	}
+	rng := rand.New(rand.NewSource(0))
+	const chars = "{}[]:,\" \\0123456789.eE+-tfnul\x01\n"
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := loadCompressed(t, tt.name)
+			check(t, ref)
+			// Change single bytes, which may span blocks.
+			for i := 0; i < 10; i++ {
+				b := append([]byte{}, ref...)
+				b[rng.Intn(len(b))] = chars[rng.Intn(len(chars))]
+				check(t, b)
+				check(t, b[:rng.Intn(len(b))])
+			}
+		})
+	}
+	for _, in := range []string{`[1,2,]`, `{"a"}`, `{"a":}`, `[tru]`, `[true ]`, `[nul]`, `[01]`, `[-]`, `[.1]`, `[1e]`,
+		`["\x"]`, `["\u12"]`, `["\u1234"]`, `[1 2]`, `{"a" "b"}`, `{,}`, `[,1]`, `{"a":1,}`, `[[[]]]`,
+		`[{"a":[{"b":null}]}]`, `[] x`, `["a"b]`, `[1.5e+3]`, `[-0.0]`, `[falsex]`, `["a\\"]`, `["` + strings.Repeat("a\\n", 100) + `"]`} {
+		t.Run(in, func(t *testing.T) {
+			check(t, []byte(in))
+		})
+	}
+}
+
+// repeatReader will return a number of copies of b.
+type repeatReader struct {
+	b   []byte
+	n   int
+	off int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 && r.n > 0 {
+		c := copy(p, r.b[r.off:])
+		p = p[c:]
+		n += c
+		r.off += c
+		if r.off == len(r.b) {
+			r.off = 0
+			r.n--
+		}
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func TestValidateReaderMemory(t *testing.T) {
+	if !SupportedCPU() {
+		t.SkipNow()
+	}
+	const elem = `{"a":"xyz","b":[1.5,-2,true,null]},`
+	const n = 100000
+	r := io.MultiReader(strings.NewReader("["), &repeatReader{b: []byte(elem), n: n}, strings.NewReader("{}]"))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := ValidateReader(r, 0); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	// The input is not kept.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(len(elem)*n/10) {
+		t.Errorf("allocated %d bytes for %d bytes of input", allocated, len(elem)*n)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package simdjson
+
+import "fmt"
+
+// WarningCode identifies the kind of a Warning.
+type WarningCode uint8
+
+const (
+	// WarningDuplicateKey is reported for each repeated key in an object.
+	// The offset is the start of the object.
+	WarningDuplicateKey WarningCode = iota + 1
+
+	// WarningDeepNesting is reported once per parse, for the first object or array
+	// nested deeper than WarnNestingDepth.
+	WarningDeepNesting
+
+	// WarningPrecisionLoss is reported for integers that do not fit in an int64 or uint64,
+	// and are stored as a float.
+	// Numbers are not checked when parsed with WithLazyNumbers.
+	WarningPrecisionLoss
+
+	// WarningNonCanonicalEscape is reported for strings containing escapes of ASCII characters
+	// that do not need escaping, like `\/` or `A`,
+	// or that have a shorter escape, like `\u000a`.
+	// Each string is reported once.
+	WarningNonCanonicalEscape
+)
+
+// WarnNestingDepth is the nesting depth above which WarningDeepNesting is reported.
+const WarnNestingDepth = 64
+
+// maxWarnings is the maximum number of warnings collected per parse.
+const maxWarnings = 1000
+
+func (w WarningCode) String() string {
+	switch w {
+	case WarningDuplicateKey:
+		return "duplicate key"
+	case WarningDeepNesting:
+		return "deep nesting"
+	case WarningPrecisionLoss:
+		return "precision loss"
+	case WarningNonCanonicalEscape:
+		return "non-canonical escape"
+	}
+	return fmt.Sprintf("WarningCode(%d)", uint8(w))
+}
+
+// Warning is a non-fatal problem found in the input when parsing with WithCollectWarnings.
+type Warning struct {
+	Code WarningCode
+	// Message describes the problem.
+	Message string
+	// Offset is the byte offset in the input, not counting leading whitespace.
+	Offset int
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%v at offset %d: %s", w.Code, w.Offset, w.Message)
+}
+
+// Warnings returns the warnings collected when parsing with WithCollectWarnings,
+// in the order they were found, except duplicate keys which are reported last.
+// At most 1000 warnings are collected.
+func (pj *ParsedJson) Warnings() []Warning {
+	return pj.warnings
+}
+
+// addWarning will add a warning unless the maximum has been reached.
+func (pj *ParsedJson) addWarning(code WarningCode, offset int, format string, args ...interface{}) {
+	if len(pj.warnings) >= maxWarnings {
+		return
+	}
+	pj.warnings = append(pj.warnings, Warning{Code: code, Message: fmt.Sprintf(format, args...), Offset: offset})
+}
+
+// nonCanonicalEscape returns the first escape in the source of a string
+// that is not canonical, or nil if there is none.
+func nonCanonicalEscape(raw []byte) []byte {
+	for i := 0; i < len(raw)-1; i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+		switch raw[i+1] {
+		case '/':
+			return raw[i : i+2]
+		case 'u':
+			if i+6 > len(raw) {
+				return nil
+			}
+			var r rune
+			for _, c := range raw[i+2 : i+6] {
+				r <<= 4
+				switch {
+				case c >= '0' && c <= '9':
+					r |= rune(c - '0')
+				case c|0x20 >= 'a' && c|0x20 <= 'f':
+					r |= rune(c|0x20-'a') + 10
+				}
+			}
+			switch {
+			case r == '"', r == '\\', r == '\b', r == '\f', r == '\n', r == '\r', r == '\t':
+				// Has a short escape.
+				return raw[i : i+6]
+			case r >= 0x20 && r < 0x7f:
+				return raw[i : i+6]
+			}
+			i += 5
+			continue
+		}
+		i++
+	}
+	return nil
+}
+
+// warnDuplicateKeys will add a warning for each duplicate key in pj.
+// Source spans must have been recorded.
+func warnDuplicateKeys(pj *ParsedJson) {
+	c := duplicateChecker{warn: func(name []byte, obj *Iter) {
+		start, _, err := obj.sourceSpan()
+		if err != nil {
+			start = -1
+		}
+		pj.addWarning(WarningDuplicateKey, start, "duplicate key %q in object", name)
+	}}
+	i := pj.Iter()
+	var root Iter
+	for i.Advance() == TypeRoot {
+		typ, r, err := i.Root(&root)
+		if err != nil {
+			return
+		}
+		if typ == TypeObject || typ == TypeArray {
+			if c.check(r, 0) != nil {
+				return
+			}
+		}
+	}
+}